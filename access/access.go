@@ -0,0 +1,345 @@
+package access
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Ban struct {
+	Target  string    `json:"target"`
+	Reason  string    `json:"reason,omitempty"`
+	By      string    `json:"by,omitempty"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+}
+
+func (b Ban) Permanent() bool {
+	return b.Expires.IsZero()
+}
+
+func (b Ban) Active(now time.Time) bool {
+	return b.Permanent() || now.Before(b.Expires)
+}
+
+type fileState struct {
+	Allow    []string `json:"allow"`
+	Deny     []string `json:"deny"`
+	UserBans []Ban    `json:"user_bans"`
+	IPBans   []Ban    `json:"ip_bans"`
+}
+
+// Control holds the CIDR allow and deny lists and the user and IP bans. When
+// Path is set every change is written back to it and Reload re-reads it.
+type Control struct {
+	Path     string
+	allow    []*net.IPNet
+	deny     []*net.IPNet
+	userBans map[string]Ban
+	ipBans   map[string]Ban
+	mu       sync.RWMutex
+}
+
+func NewControl(path string) (*Control, error) {
+	c := &Control{
+		Path:     path,
+		userBans: make(map[string]Ban),
+		ipBans:   make(map[string]Ban),
+	}
+
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *Control) Reload() error {
+	if c.Path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(c.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read access list: %w", err)
+	}
+
+	var state fileState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to parse access list: %w", err)
+	}
+
+	allow, err := parseNetworks(state.Allow)
+	if err != nil {
+		return err
+	}
+	deny, err := parseNetworks(state.Deny)
+	if err != nil {
+		return err
+	}
+
+	userBans := make(map[string]Ban)
+	for _, ban := range state.UserBans {
+		userBans[ban.Target] = ban
+	}
+
+	ipBans := make(map[string]Ban)
+	for _, ban := range state.IPBans {
+		network, err := parseNetwork(ban.Target)
+		if err != nil {
+			return err
+		}
+		ban.Target = network.String()
+		ipBans[ban.Target] = ban
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.allow = allow
+	c.deny = deny
+	c.userBans = userBans
+	c.ipBans = ipBans
+
+	return nil
+}
+
+func (c *Control) CheckIP(ip net.IP) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, network := range c.deny {
+		if network.Contains(ip) {
+			return fmt.Errorf("address %s is denied", ip)
+		}
+	}
+
+	if len(c.allow) > 0 {
+		allowed := false
+		for _, network := range c.allow {
+			if network.Contains(ip) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("address %s is not allowed", ip)
+		}
+	}
+
+	now := time.Now()
+	for _, ban := range c.ipBans {
+		if !ban.Active(now) {
+			continue
+		}
+		network, err := parseNetwork(ban.Target)
+		if err == nil && network.Contains(ip) {
+			return banError(ban)
+		}
+	}
+
+	return nil
+}
+
+func (c *Control) CheckUser(username string) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ban, exists := c.userBans[username]
+	if exists && ban.Active(time.Now()) {
+		return banError(ban)
+	}
+	return nil
+}
+
+func (c *Control) BanUser(username, by, reason string, duration time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.userBans[username] = newBan(username, by, reason, duration)
+	return c.save()
+}
+
+func (c *Control) UnbanUser(username string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.userBans[username]; !exists {
+		return fmt.Errorf("user '%s' is not banned", username)
+	}
+
+	delete(c.userBans, username)
+	return c.save()
+}
+
+// BanIP bans a single address or a whole CIDR range and returns the
+// normalized network that was banned.
+func (c *Control) BanIP(target, by, reason string, duration time.Duration) (*net.IPNet, error) {
+	network, err := parseNetwork(target)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ipBans[network.String()] = newBan(network.String(), by, reason, duration)
+	return network, c.save()
+}
+
+func (c *Control) UnbanIP(target string) error {
+	network, err := parseNetwork(target)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.ipBans[network.String()]; !exists {
+		return fmt.Errorf("address %s is not banned", target)
+	}
+
+	delete(c.ipBans, network.String())
+	return c.save()
+}
+
+func (c *Control) UserBans() []Ban {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return activeBans(c.userBans)
+}
+
+func (c *Control) IPBans() []Ban {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return activeBans(c.ipBans)
+}
+
+// save must be called with c.mu held. Expired bans are dropped on the way out.
+func (c *Control) save() error {
+	if c.Path == "" {
+		return nil
+	}
+
+	state := fileState{
+		Allow:    networkStrings(c.allow),
+		Deny:     networkStrings(c.deny),
+		UserBans: activeBans(c.userBans),
+		IPBans:   activeBans(c.ipBans),
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.Path), ".access-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to save access list: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save access list: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save access list: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), c.Path); err != nil {
+		return fmt.Errorf("failed to save access list: %w", err)
+	}
+
+	return nil
+}
+
+func newBan(target, by, reason string, duration time.Duration) Ban {
+	now := time.Now()
+	ban := Ban{
+		Target:  target,
+		Reason:  reason,
+		By:      by,
+		Created: now,
+	}
+	if duration > 0 {
+		ban.Expires = now.Add(duration)
+	}
+	return ban
+}
+
+func banError(ban Ban) error {
+	msg := "banned"
+	if !ban.Permanent() {
+		msg += " until " + ban.Expires.Format(time.RFC1123)
+	}
+	if ban.Reason != "" {
+		msg += ": " + ban.Reason
+	}
+	return fmt.Errorf("%s", msg)
+}
+
+func activeBans(bans map[string]Ban) []Ban {
+	now := time.Now()
+	list := make([]Ban, 0, len(bans))
+	for _, ban := range bans {
+		if ban.Active(now) {
+			list = append(list, ban)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Target < list[j].Target
+	})
+	return list
+}
+
+func parseNetwork(target string) (*net.IPNet, error) {
+	if !strings.Contains(target, "/") {
+		ip := net.ParseIP(target)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address '%s'", target)
+		}
+		if ip.To4() != nil {
+			target += "/32"
+		} else {
+			target += "/128"
+		}
+	}
+
+	_, network, err := net.ParseCIDR(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR '%s'", target)
+	}
+	return network, nil
+}
+
+func parseNetworks(targets []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(targets))
+	for _, target := range targets {
+		network, err := parseNetwork(target)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func networkStrings(networks []*net.IPNet) []string {
+	list := make([]string, 0, len(networks))
+	for _, network := range networks {
+		list = append(list, network.String())
+	}
+	return list
+}
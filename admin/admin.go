@@ -0,0 +1,495 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imaneimrh/TCP-Chat_Server/access"
+	"github.com/imaneimrh/TCP-Chat_Server/audit"
+	"github.com/imaneimrh/TCP-Chat_Server/auth"
+	"github.com/imaneimrh/TCP-Chat_Server/client"
+	"github.com/imaneimrh/TCP-Chat_Server/logging"
+	"github.com/imaneimrh/TCP-Chat_Server/room"
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
+)
+
+// Server exposes a JSON API for operators. Every request must carry
+// "Authorization: Bearer <Token>".
+type Server struct {
+	Addr        string
+	Token       string
+	Handler     *client.Handler
+	RoomManager *room.Manager
+	AuthManager *auth.Manager
+	Access      *access.Control
+	Audit       *audit.Log
+}
+
+// actor is recorded in the audit log for changes made through the API, since
+// the bearer token does not identify a person.
+const actor = "admin-api"
+
+func NewServer(addr, token string, handler *client.Handler, roomManager *room.Manager, authManager *auth.Manager, accessControl *access.Control) *Server {
+	return &Server{
+		Addr:        addr,
+		Token:       token,
+		Handler:     handler,
+		RoomManager: roomManager,
+		AuthManager: authManager,
+		Access:      accessControl,
+	}
+}
+
+func (s *Server) Start() error {
+	if s.Token == "" {
+		return fmt.Errorf("admin API requires a token")
+	}
+
+	slog.Info("Admin API listening", "addr", s.Addr)
+	return http.ListenAndServe(s.Addr, s.Routes())
+}
+
+func (s *Server) Routes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /api/users", s.listUsers)
+	mux.HandleFunc("GET /api/clients", s.listClients)
+	mux.HandleFunc("POST /api/users/{name}/kick", s.kickUser)
+	mux.HandleFunc("POST /api/users/{name}/ban", s.banUser)
+	mux.HandleFunc("DELETE /api/users/{name}/ban", s.unbanUser)
+	mux.HandleFunc("PUT /api/users/{name}/role", s.setRole)
+
+	mux.HandleFunc("GET /api/rooms", s.listRooms)
+	mux.HandleFunc("GET /api/rooms/{name}", s.getRoom)
+	mux.HandleFunc("POST /api/rooms", s.createRoom)
+	mux.HandleFunc("DELETE /api/rooms/{name}", s.deleteRoom)
+
+	mux.HandleFunc("GET /api/transfers", s.listTransfers)
+
+	mux.HandleFunc("GET /api/bans", s.listBans)
+	mux.HandleFunc("POST /api/ipbans", s.banIP)
+	mux.HandleFunc("DELETE /api/ipbans", s.unbanIP)
+
+	mux.HandleFunc("POST /api/announce", s.announce)
+
+	mux.HandleFunc("GET /api/loglevel", s.getLogLevel)
+	mux.HandleFunc("PUT /api/loglevel", s.setLogLevel)
+
+	return s.audit(s.authenticate(mux))
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// audit records every API call, including ones rejected for a bad token.
+func (s *Server) audit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		caller := actor
+		if rec.status == http.StatusUnauthorized {
+			caller = ""
+		}
+		s.Audit.Record(audit.EventAdminAPI, caller, r.Method+" "+r.URL.Path, remoteHost(r), strconv.Itoa(rec.status))
+	})
+}
+
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="chat-admin"`)
+			writeError(w, http.StatusUnauthorized, "invalid or missing bearer token")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+type userInfo struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	Online   bool   `json:"online"`
+}
+
+func (s *Server) listUsers(w http.ResponseWriter, r *http.Request) {
+	online := make(map[string]bool)
+	for _, username := range s.Handler.OnlineUsers() {
+		online[username] = true
+	}
+
+	usernames := s.AuthManager.ListUsers()
+	sort.Strings(usernames)
+
+	users := make([]userInfo, 0, len(usernames))
+	for _, username := range usernames {
+		users = append(users, userInfo{
+			Username: username,
+			Role:     s.AuthManager.GetRole(username).String(),
+			Online:   online[username],
+		})
+	}
+
+	writeJSON(w, http.StatusOK, users)
+}
+
+func (s *Server) listClients(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Handler.OnlineClients())
+}
+
+type reasonRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (s *Server) kickUser(w http.ResponseWriter, r *http.Request) {
+	var req reasonRequest
+	if !readJSON(w, r, &req, true) {
+		return
+	}
+
+	message := shared.ReplyAdminKicked
+	if req.Reason != "" {
+		message += ": " + req.Reason
+	}
+
+	if !s.Handler.Kick(r.PathValue("name"), message) {
+		writeError(w, http.StatusNotFound, "user is not online")
+		return
+	}
+	s.Audit.Record(audit.EventKick, actor, r.PathValue("name"), remoteHost(r), req.Reason)
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "kicked"})
+}
+
+func (s *Server) setRole(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Role string `json:"role"`
+	}
+	if !readJSON(w, r, &req, false) {
+		return
+	}
+
+	role, err := auth.ParseRole(req.Role)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	username := r.PathValue("name")
+	previous := s.AuthManager.GetRole(username)
+	s.AuthManager.SetRole(username, role)
+
+	slog.Info("Role changed", "target", username, "from", previous.String(), "to", role.String())
+	s.Audit.Record(audit.EventRoleChange, actor, username, remoteHost(r), previous.String()+" -> "+role.String())
+	writeJSON(w, http.StatusOK, map[string]string{"username": username, "role": role.String()})
+}
+
+type banRequest struct {
+	Target   string `json:"target"`
+	Duration string `json:"duration"`
+	Reason   string `json:"reason"`
+}
+
+func (b banRequest) duration() (time.Duration, error) {
+	if b.Duration == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(b.Duration)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration '%s'", b.Duration)
+	}
+	return d, nil
+}
+
+func (b banRequest) detail() string {
+	detail := b.Duration
+	if detail == "" {
+		detail = "permanent"
+	}
+	if b.Reason != "" {
+		detail += ": " + b.Reason
+	}
+	return detail
+}
+
+func (s *Server) banUser(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAccess(w) {
+		return
+	}
+
+	var req banRequest
+	if !readJSON(w, r, &req, true) {
+		return
+	}
+
+	duration, err := req.duration()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	username := r.PathValue("name")
+	if err := s.Access.BanUser(username, actor, req.Reason, duration); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.Audit.Record(audit.EventBan, actor, username, remoteHost(r), req.detail())
+
+	message := shared.ReplyAdminBanned
+	if req.Reason != "" {
+		message += ": " + req.Reason
+	}
+	s.Handler.Kick(username, message)
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "banned"})
+}
+
+func (s *Server) unbanUser(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAccess(w) {
+		return
+	}
+
+	if err := s.Access.UnbanUser(r.PathValue("name")); err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	s.Audit.Record(audit.EventUnban, actor, r.PathValue("name"), remoteHost(r), "")
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "unbanned"})
+}
+
+func (s *Server) listBans(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAccess(w) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string][]access.Ban{
+		"users": s.Access.UserBans(),
+		"ips":   s.Access.IPBans(),
+	})
+}
+
+func (s *Server) banIP(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAccess(w) {
+		return
+	}
+
+	var req banRequest
+	if !readJSON(w, r, &req, false) {
+		return
+	}
+
+	duration, err := req.duration()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	network, err := s.Access.BanIP(req.Target, actor, req.Reason, duration)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.Audit.Record(audit.EventBan, actor, network.String(), remoteHost(r), req.detail())
+
+	kicked := s.Handler.KickNetwork(network, shared.ReplyAddressBanned)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":       "banned",
+		"target":       network.String(),
+		"disconnected": kicked,
+	})
+}
+
+func (s *Server) unbanIP(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAccess(w) {
+		return
+	}
+
+	if err := s.Access.UnbanIP(r.URL.Query().Get("target")); err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	s.Audit.Record(audit.EventUnban, actor, r.URL.Query().Get("target"), remoteHost(r), "")
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "unbanned"})
+}
+
+type roomInfo struct {
+	Name     string   `json:"name"`
+	Members  []string `json:"members"`
+	SlowMode string   `json:"slow_mode,omitempty"`
+	Topic    string   `json:"topic,omitempty"`
+}
+
+func newRoomInfo(r *room.Room) roomInfo {
+	info := roomInfo{
+		Name:    r.Name,
+		Members: r.Members(),
+		Topic:   r.Topic(),
+	}
+	if slowMode := r.SlowMode(); slowMode > 0 {
+		info.SlowMode = slowMode.String()
+	}
+	return info
+}
+
+func (s *Server) listRooms(w http.ResponseWriter, r *http.Request) {
+	names := s.RoomManager.ListRooms()
+	sort.Strings(names)
+
+	rooms := make([]roomInfo, 0, len(names))
+	for _, name := range names {
+		if rm, exists := s.RoomManager.GetRoom(name); exists {
+			rooms = append(rooms, newRoomInfo(rm))
+		}
+	}
+
+	writeJSON(w, http.StatusOK, rooms)
+}
+
+func (s *Server) getRoom(w http.ResponseWriter, r *http.Request) {
+	rm, exists := s.RoomManager.GetRoom(r.PathValue("name"))
+	if !exists {
+		writeError(w, http.StatusNotFound, "room does not exist")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newRoomInfo(rm))
+}
+
+func (s *Server) createRoom(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if !readJSON(w, r, &req, false) {
+		return
+	}
+
+	if req.Name == "" || strings.ContainsAny(req.Name, " \t\n") {
+		writeError(w, http.StatusBadRequest, "invalid room name")
+		return
+	}
+
+	rm, err := s.RoomManager.CreateRoom(req.Name)
+	if err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	s.Audit.Record(audit.EventRoomCreate, actor, req.Name, remoteHost(r), "")
+
+	writeJSON(w, http.StatusCreated, newRoomInfo(rm))
+}
+
+func (s *Server) deleteRoom(w http.ResponseWriter, r *http.Request) {
+	if err := s.RoomManager.DeleteRoom(r.PathValue("name")); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	s.Audit.Record(audit.EventRoomDelete, actor, r.PathValue("name"), remoteHost(r), "")
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+func (s *Server) listTransfers(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Handler.FileTransfer.Transfers())
+}
+
+func (s *Server) announce(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Room string `json:"room"`
+		Text string `json:"text"`
+	}
+	if !readJSON(w, r, &req, false) {
+		return
+	}
+
+	if strings.TrimSpace(req.Text) == "" {
+		writeError(w, http.StatusBadRequest, "text is required")
+		return
+	}
+
+	if err := s.Handler.Announce(req.Room, req.Text); err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "sent"})
+}
+
+func (s *Server) getLogLevel(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"level": logging.CurrentLevel()})
+}
+
+func (s *Server) setLogLevel(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Level string `json:"level"`
+	}
+	if !readJSON(w, r, &req, false) {
+		return
+	}
+
+	if err := logging.SetLevel(req.Level); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	slog.Info("Log level changed", "level", logging.CurrentLevel())
+	writeJSON(w, http.StatusOK, map[string]string{"level": logging.CurrentLevel()})
+}
+
+func (s *Server) requireAccess(w http.ResponseWriter) bool {
+	if s.Access == nil {
+		writeError(w, http.StatusNotImplemented, "access control is not enabled")
+		return false
+	}
+	return true
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}, optional bool) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, 64*1024)
+
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err != nil && !(optional && errors.Is(err, io.EOF)) {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
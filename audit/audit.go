@@ -0,0 +1,212 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	EventRegister      = "register"
+	EventLogin         = "login"
+	EventLoginFailure  = "login_failed"
+	EventLogout        = "logout"
+	EventRoomCreate    = "room_create"
+	EventRoomDelete    = "room_delete"
+	EventKick          = "kick"
+	EventBan           = "ban"
+	EventUnban         = "unban"
+	EventRoleChange    = "role_change"
+	EventAdminAPI      = "admin_api"
+	EventMessageDelete = "message_delete"
+)
+
+// genesisHash is the Prev value of the first entry in a log.
+const genesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+type Entry struct {
+	Seq    uint64    `json:"seq"`
+	Time   time.Time `json:"time"`
+	Event  string    `json:"event"`
+	Actor  string    `json:"actor,omitempty"`
+	Target string    `json:"target,omitempty"`
+	IP     string    `json:"ip,omitempty"`
+	Detail string    `json:"detail,omitempty"`
+	Prev   string    `json:"prev"`
+	Hash   string    `json:"hash"`
+}
+
+// computeHash covers every field except Hash itself, so changing, removing or
+// reordering any entry breaks the chain from that point on.
+func (e Entry) computeHash() (string, error) {
+	e.Hash = ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(append([]byte(e.Prev+"\n"), data...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Log is an append-only file of JSON lines in which every entry carries the
+// hash of the one before it.
+type Log struct {
+	Path string
+	file *os.File
+	seq  uint64
+	last string
+	mu   sync.Mutex
+}
+
+func Open(path string) (*Log, error) {
+	l := &Log{
+		Path: path,
+		last: genesisHash,
+	}
+
+	if existing, err := os.Open(path); err == nil {
+		count, last, err := verify(existing)
+		existing.Close()
+		if err != nil {
+			return nil, fmt.Errorf("existing audit log failed verification: %w", err)
+		}
+		l.seq = count
+		if count > 0 {
+			l.last = last
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	l.file = file
+
+	return l, nil
+}
+
+// Record appends an event. It is safe to call on a nil Log, which records
+// nothing, so callers do not need to check whether auditing is enabled.
+func (l *Log) Record(event, actor, target, ip, detail string) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry := Entry{
+		Seq:    l.seq + 1,
+		Time:   time.Now().UTC(),
+		Event:  event,
+		Actor:  actor,
+		Target: target,
+		IP:     ip,
+		Detail: detail,
+		Prev:   l.last,
+	}
+
+	hash, err := entry.computeHash()
+	if err != nil {
+		slog.Error("Failed to hash audit entry", "event", event, "error", err)
+		return
+	}
+	entry.Hash = hash
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		slog.Error("Failed to encode audit entry", "event", event, "error", err)
+		return
+	}
+
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		slog.Error("Failed to write audit entry", "event", event, "error", err)
+		return
+	}
+
+	l.seq = entry.Seq
+	l.last = entry.Hash
+}
+
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// Verify checks the hash chain of an audit log and returns the number of
+// entries that verified. Entries cut from the end of the file cannot be
+// detected this way; compare the count with an external record for that.
+func Verify(r io.Reader) (uint64, error) {
+	count, _, err := verify(r)
+	return count, err
+}
+
+func VerifyFile(path string) (uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	return Verify(file)
+}
+
+func verify(r io.Reader) (uint64, string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	prev := genesisHash
+	var count uint64
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return count, prev, fmt.Errorf("entry %d: malformed: %w", count+1, err)
+		}
+
+		if entry.Seq != count+1 {
+			return count, prev, fmt.Errorf("entry %d: unexpected sequence number %d", count+1, entry.Seq)
+		}
+
+		if entry.Prev != prev {
+			return count, prev, fmt.Errorf("entry %d: chain broken, previous hash does not match", entry.Seq)
+		}
+
+		hash, err := entry.computeHash()
+		if err != nil {
+			return count, prev, fmt.Errorf("entry %d: %w", entry.Seq, err)
+		}
+		if hash != entry.Hash {
+			return count, prev, fmt.Errorf("entry %d: hash mismatch, entry has been modified", entry.Seq)
+		}
+
+		prev = entry.Hash
+		count++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return count, prev, err
+	}
+
+	return count, prev, nil
+}
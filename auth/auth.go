@@ -2,9 +2,11 @@ package auth
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
+	"strings"
 	"sync"
 
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -15,22 +17,72 @@ type User struct {
 
 type Manager struct {
 	Users      map[string]User
+	Roles      map[string]Role
 	UsersMutex sync.RWMutex
+
+	// reserved holds the lower-cased names set aside with Reserve.
+	reserved map[string]bool
 }
 
 func NewManager() *Manager {
 	return &Manager{
-		Users: make(map[string]User),
+		Users:    make(map[string]User),
+		Roles:    make(map[string]Role),
+		reserved: make(map[string]bool),
+	}
+}
+
+// IsReserved reports whether username would pass for the server itself, in
+// any case, and so may not be registered, logged in with or used by a bot.
+func IsReserved(username string) bool {
+	return strings.EqualFold(username, shared.ServerName)
+}
+
+// Reserve sets username aside, in any case, for something the server posts
+// as, such as a webhook bot. It fails if a user has registered the name.
+func (am *Manager) Reserve(username string) error {
+	am.UsersMutex.Lock()
+	defer am.UsersMutex.Unlock()
+
+	for name := range am.Users {
+		if strings.EqualFold(name, username) {
+			return fmt.Errorf("username '%s' is already registered", name)
+		}
 	}
+	am.reserved[strings.ToLower(username)] = true
+	return nil
 }
 
+// Reserved reports whether username is the server's own or has been set
+// aside with Reserve. Such names can neither be registered nor logged in
+// with.
+func (am *Manager) Reserved(username string) bool {
+	if IsReserved(username) {
+		return true
+	}
+
+	am.UsersMutex.RLock()
+	defer am.UsersMutex.RUnlock()
+	return am.reserved[strings.ToLower(username)]
+}
+
+// Register creates a local account. Names that hold a role without an
+// account are refused: local accounts only live in memory, so after a
+// restart whoever registered an admin's name first would be admin.
 func (am *Manager) Register(username, password string) error {
+	if am.Reserved(username) {
+		return fmt.Errorf("username '%s' is reserved", username)
+	}
+
 	am.UsersMutex.Lock()
 	defer am.UsersMutex.Unlock()
 
 	if _, exists := am.Users[username]; exists {
 		return fmt.Errorf("username '%s' already exists", username)
 	}
+	if role, granted := am.Roles[username]; granted {
+		return fmt.Errorf("username '%s' holds the %s role and cannot be registered", username, role)
+	}
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
@@ -42,7 +94,7 @@ func (am *Manager) Register(username, password string) error {
 		PasswordHash: string(hashedPassword),
 	}
 
-	log.Printf("User '%s' registered successfully", username)
+	slog.Info("User registered", "user", username)
 	return nil
 }
 
@@ -52,12 +104,12 @@ func (am *Manager) Authenticate(username, password string) error {
 
 	user, exists := am.Users[username]
 	if !exists {
-		return fmt.Errorf("invalid username or password")
+		return ErrInvalidCredentials
 	}
 
 	err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
 	if err != nil {
-		return fmt.Errorf("invalid username or password")
+		return ErrInvalidCredentials
 	}
 
 	return nil
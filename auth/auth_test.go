@@ -0,0 +1,55 @@
+package auth
+
+import "testing"
+
+func TestReserve(t *testing.T) {
+	am := NewManager()
+	if err := am.Register("alice", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := am.Reserve("Alice"); err == nil {
+		t.Fatal("Reserve() accepted the name of a registered user")
+	}
+	if err := am.Reserve("deploy-bot"); err != nil {
+		t.Fatalf("Reserve() error = %v", err)
+	}
+
+	for _, name := range []string{"deploy-bot", "Deploy-Bot", "Server", "server"} {
+		if !am.Reserved(name) {
+			t.Errorf("Reserved(%q) = false", name)
+		}
+		if err := am.Register(name, "secret"); err == nil {
+			t.Errorf("Register(%q) succeeded", name)
+		}
+	}
+	if am.Reserved("alice") {
+		t.Error("Reserved(\"alice\") = true")
+	}
+}
+
+func TestRegisterRoleHolder(t *testing.T) {
+	am := NewManager()
+	am.SetRole("root", RoleAdmin)
+	am.SetRole("mod", RoleModerator)
+
+	for _, name := range []string{"root", "mod"} {
+		if err := am.Register(name, "secret"); err == nil {
+			t.Errorf("Register(%q) claimed a name that holds a role", name)
+		}
+	}
+
+	// A role given to an existing account stays with it.
+	if err := am.Register("alice", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	am.SetRole("alice", RoleModerator)
+	if err := am.Authenticate("alice", "secret"); err != nil || !am.IsModerator("alice") {
+		t.Fatalf("Authenticate() = %v, IsModerator() = %v", err, am.IsModerator("alice"))
+	}
+
+	am.SetRole("mod", RoleUser)
+	if err := am.Register("mod", "secret"); err != nil {
+		t.Fatalf("Register() after the role was taken back error = %v", err)
+	}
+}
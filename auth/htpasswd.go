@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// HtpasswdFile authenticates against a static file of "username:hash" lines
+// as produced by `htpasswd -B` (bcrypt) or `htpasswd -s` ({SHA}).
+type HtpasswdFile struct {
+	Path    string
+	entries map[string]string
+	mu      sync.RWMutex
+}
+
+func NewHtpasswdFile(path string) (*HtpasswdFile, error) {
+	h := &HtpasswdFile{
+		Path:    path,
+		entries: make(map[string]string),
+	}
+
+	if err := h.Reload(); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+func (h *HtpasswdFile) Reload() error {
+	file, err := os.Open(h.Path)
+	if err != nil {
+		return fmt.Errorf("failed to open htpasswd file: %w", err)
+	}
+	defer file.Close()
+
+	entries := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		username, hash, ok := strings.Cut(line, ":")
+		if !ok || username == "" || hash == "" {
+			return fmt.Errorf("malformed htpasswd entry on line %d", lineNum)
+		}
+
+		entries[username] = hash
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading htpasswd file: %w", err)
+	}
+
+	h.mu.Lock()
+	h.entries = entries
+	h.mu.Unlock()
+
+	return nil
+}
+
+func (h *HtpasswdFile) Authenticate(username, password string) error {
+	h.mu.RLock()
+	hash, exists := h.entries[username]
+	h.mu.RUnlock()
+
+	if !exists {
+		return ErrInvalidCredentials
+	}
+
+	switch {
+	case strings.HasPrefix(hash, "$2"):
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+			return ErrInvalidCredentials
+		}
+
+	case strings.HasPrefix(hash, "{SHA}"):
+		sum := sha1.Sum([]byte(password))
+		expected := "{SHA}" + base64.StdEncoding.EncodeToString(sum[:])
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(expected)) != 1 {
+			return ErrInvalidCredentials
+		}
+
+	default:
+		return fmt.Errorf("unsupported hash format for user '%s'", username)
+	}
+
+	return nil
+}
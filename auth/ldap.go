@@ -0,0 +1,266 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	ldapResultSuccess            = 0
+	ldapResultInvalidCredentials = 49
+
+	berTagInteger    = 0x02
+	berTagOctetStr   = 0x04
+	berTagEnumerated = 0x0a
+	berTagSequence   = 0x30
+
+	ldapTagBindRequest   = 0x60
+	ldapTagBindResponse  = 0x61
+	ldapTagUnbindRequest = 0x42
+	ldapTagSimpleAuth    = 0x80
+
+	maxLDAPResponseSize = 64 * 1024
+)
+
+// LDAPAuthenticator verifies credentials with an LDAPv3 simple bind. The
+// username is substituted into BindDN, e.g. "uid=%s,ou=people,dc=example,dc=com".
+type LDAPAuthenticator struct {
+	Addr      string
+	BindDN    string
+	UseTLS    bool
+	TLSConfig *tls.Config
+	Timeout   time.Duration
+}
+
+func NewLDAPAuthenticator(addr, bindDN string) *LDAPAuthenticator {
+	return &LDAPAuthenticator{
+		Addr:    addr,
+		BindDN:  bindDN,
+		Timeout: 5 * time.Second,
+	}
+}
+
+func (l *LDAPAuthenticator) Authenticate(username, password string) error {
+	// An empty password turns a simple bind into an unauthenticated bind,
+	// which most directories accept for any DN.
+	if username == "" || password == "" {
+		return ErrInvalidCredentials
+	}
+
+	conn, err := l.dial()
+	if err != nil {
+		return fmt.Errorf("failed to connect to LDAP server: %w", err)
+	}
+	defer conn.Close()
+
+	if l.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(l.Timeout))
+	}
+
+	dn := fmt.Sprintf(l.BindDN, escapeDN(username))
+
+	_, err = conn.Write(encodeBindRequest(1, dn, password))
+	if err != nil {
+		return fmt.Errorf("failed to send LDAP bind request: %w", err)
+	}
+
+	resultCode, diagnostic, err := readBindResponse(bufio.NewReader(conn), 1)
+	if err != nil {
+		return err
+	}
+
+	conn.Write(encodeUnbindRequest(2))
+
+	switch resultCode {
+	case ldapResultSuccess:
+		return nil
+	case ldapResultInvalidCredentials:
+		return ErrInvalidCredentials
+	default:
+		return fmt.Errorf("LDAP bind failed with result code %d: %s", resultCode, diagnostic)
+	}
+}
+
+func (l *LDAPAuthenticator) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: l.Timeout}
+	if l.UseTLS {
+		return tls.DialWithDialer(dialer, "tcp", l.Addr, l.TLSConfig)
+	}
+	return dialer.Dial("tcp", l.Addr)
+}
+
+// escapeDN escapes an attribute value for use inside a distinguished name
+// (RFC 4514) so that a username cannot change the structure of the bind DN.
+func escapeDN(value string) string {
+	var sb strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == ',' || c == '+' || c == '"' || c == '\\' || c == '<' || c == '>' || c == ';' || c == '=':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c == 0:
+			sb.WriteString("\\00")
+		case (c == ' ' || c == '#') && i == 0:
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case c == ' ' && i == len(value)-1:
+			sb.WriteString("\\ ")
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+func encodeBindRequest(messageID int, dn, password string) []byte {
+	bind := berInteger(3)
+	bind = append(bind, berTLV(berTagOctetStr, []byte(dn))...)
+	bind = append(bind, berTLV(ldapTagSimpleAuth, []byte(password))...)
+
+	msg := berInteger(messageID)
+	msg = append(msg, berTLV(ldapTagBindRequest, bind)...)
+
+	return berTLV(berTagSequence, msg)
+}
+
+func encodeUnbindRequest(messageID int) []byte {
+	msg := berInteger(messageID)
+	msg = append(msg, berTLV(ldapTagUnbindRequest, nil)...)
+
+	return berTLV(berTagSequence, msg)
+}
+
+func readBindResponse(reader *bufio.Reader, messageID int) (int, string, error) {
+	tag, body, err := readBERElement(reader)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to read LDAP response: %w", err)
+	}
+	if tag != berTagSequence {
+		return 0, "", fmt.Errorf("unexpected LDAP message tag 0x%02x", tag)
+	}
+
+	tag, idBytes, rest, err := parseBERElement(body)
+	if err != nil || tag != berTagInteger {
+		return 0, "", fmt.Errorf("malformed LDAP message ID")
+	}
+	if parseBERInteger(idBytes) != messageID {
+		return 0, "", fmt.Errorf("unexpected LDAP message ID %d", parseBERInteger(idBytes))
+	}
+
+	tag, op, _, err := parseBERElement(rest)
+	if err != nil || tag != ldapTagBindResponse {
+		return 0, "", fmt.Errorf("unexpected LDAP response operation")
+	}
+
+	tag, code, rest, err := parseBERElement(op)
+	if err != nil || tag != berTagEnumerated {
+		return 0, "", fmt.Errorf("malformed LDAP result code")
+	}
+
+	var diagnostic string
+	if _, _, rest, err = parseBERElement(rest); err == nil {
+		if tag, msg, _, err := parseBERElement(rest); err == nil && tag == berTagOctetStr {
+			diagnostic = string(msg)
+		}
+	}
+
+	return parseBERInteger(code), diagnostic, nil
+}
+
+func berTLV(tag byte, content []byte) []byte {
+	out := []byte{tag}
+	out = append(out, berLength(len(content))...)
+	return append(out, content...)
+}
+
+func berLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+
+	var buf []byte
+	for v := n; v > 0; v >>= 8 {
+		buf = append([]byte{byte(v)}, buf...)
+	}
+	return append([]byte{0x80 | byte(len(buf))}, buf...)
+}
+
+func berInteger(v int) []byte {
+	content := []byte{byte(v)}
+	for v > 0x7f || v < -0x80 {
+		v >>= 8
+		content = append([]byte{byte(v)}, content...)
+	}
+	return berTLV(berTagInteger, content)
+}
+
+func parseBERInteger(b []byte) int {
+	v := 0
+	for i, c := range b {
+		if i == 0 && c&0x80 != 0 {
+			v = -1
+		}
+		v = v<<8 | int(c)
+	}
+	return v
+}
+
+func readBERElement(reader *bufio.Reader) (byte, []byte, error) {
+	tag, err := reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	first, err := reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	length := int(first)
+	if first&0x80 != 0 {
+		numBytes := int(first & 0x7f)
+		if numBytes == 0 || numBytes > 4 {
+			return 0, nil, fmt.Errorf("unsupported BER length encoding")
+		}
+		length = 0
+		for i := 0; i < numBytes; i++ {
+			b, err := reader.ReadByte()
+			if err != nil {
+				return 0, nil, err
+			}
+			length = length<<8 | int(b)
+		}
+	}
+
+	if length > maxLDAPResponseSize {
+		return 0, nil, fmt.Errorf("LDAP response too large (%d bytes)", length)
+	}
+
+	content := make([]byte, length)
+	if _, err := io.ReadFull(reader, content); err != nil {
+		return 0, nil, err
+	}
+
+	return tag, content, nil
+}
+
+func parseBERElement(b []byte) (byte, []byte, []byte, error) {
+	tag, content, err := readBERElement(bufio.NewReader(bytes.NewReader(b)))
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("malformed BER element: %w", err)
+	}
+
+	headerLen := 2
+	if b[1]&0x80 != 0 {
+		headerLen += int(b[1] & 0x7f)
+	}
+
+	return tag, content, b[headerLen+len(content):], nil
+}
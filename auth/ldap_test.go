@@ -0,0 +1,215 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeLDAP accepts one connection, reads a bind request and writes whatever
+// respond returns for its DN and password.
+func fakeLDAP(t *testing.T, respond func(messageID int, dn, password string) []byte) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+		reader := bufio.NewReader(conn)
+		_, body, err := readBERElement(reader)
+		if err != nil {
+			return
+		}
+		_, id, rest, err := parseBERElement(body)
+		if err != nil {
+			return
+		}
+		_, bind, _, err := parseBERElement(rest)
+		if err != nil {
+			return
+		}
+		_, _, rest, err = parseBERElement(bind)
+		if err != nil {
+			return
+		}
+		_, dn, rest, err := parseBERElement(rest)
+		if err != nil {
+			return
+		}
+		_, password, _, err := parseBERElement(rest)
+		if err != nil {
+			return
+		}
+
+		conn.Write(respond(parseBERInteger(id), string(dn), string(password)))
+		// Wait for the unbind or for the client to hang up.
+		readBERElement(reader)
+	}()
+
+	return listener.Addr().String()
+}
+
+func bindResponse(messageID, resultCode int, diagnostic string) []byte {
+	result := berTLV(berTagEnumerated, []byte{byte(resultCode)})
+	result = append(result, berTLV(berTagOctetStr, nil)...)
+	result = append(result, berTLV(berTagOctetStr, []byte(diagnostic))...)
+
+	msg := berInteger(messageID)
+	msg = append(msg, berTLV(ldapTagBindResponse, result)...)
+	return berTLV(berTagSequence, msg)
+}
+
+func TestLDAPBind(t *testing.T) {
+	addr := fakeLDAP(t, func(messageID int, dn, password string) []byte {
+		if dn == "uid=alice,ou=people,dc=example,dc=com" && password == "secret" {
+			return bindResponse(messageID, ldapResultSuccess, "")
+		}
+		return bindResponse(messageID, ldapResultInvalidCredentials, "")
+	})
+
+	ldap := NewLDAPAuthenticator(addr, "uid=%s,ou=people,dc=example,dc=com")
+	if err := ldap.Authenticate("alice", "secret"); err != nil {
+		t.Fatalf("Authenticate() = %v, want success", err)
+	}
+}
+
+func TestLDAPBindFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		response func(messageID int) []byte
+		want     string
+	}{
+		{
+			name:     "invalid credentials",
+			response: func(id int) []byte { return bindResponse(id, ldapResultInvalidCredentials, "") },
+		},
+		{
+			name:     "other result code",
+			response: func(id int) []byte { return bindResponse(id, 53, "account locked") },
+			want:     "result code 53: account locked",
+		},
+		{
+			name:     "wrong message ID",
+			response: func(id int) []byte { return bindResponse(id+1, ldapResultSuccess, "") },
+			want:     "unexpected LDAP message ID",
+		},
+		{
+			name:     "not a sequence",
+			response: func(int) []byte { return berTLV(berTagOctetStr, []byte("hello")) },
+			want:     "unexpected LDAP message tag",
+		},
+		{
+			name: "not a bind response",
+			response: func(id int) []byte {
+				return berTLV(berTagSequence, append(berInteger(id), berTLV(0x65, nil)...))
+			},
+			want: "unexpected LDAP response operation",
+		},
+		{
+			name: "missing result code",
+			response: func(id int) []byte {
+				return berTLV(berTagSequence, append(berInteger(id), berTLV(ldapTagBindResponse, nil)...))
+			},
+			want: "malformed LDAP result code",
+		},
+		{
+			name:     "truncated",
+			response: func(id int) []byte { return bindResponse(id, ldapResultSuccess, "")[:6] },
+			want:     "failed to read LDAP response",
+		},
+		{
+			name:     "oversized",
+			response: func(int) []byte { return []byte{berTagSequence, 0x84, 0x7f, 0xff, 0xff, 0xff} },
+			want:     "too large",
+		},
+		{
+			name:     "unsupported length",
+			response: func(int) []byte { return []byte{berTagSequence, 0x80} },
+			want:     "unsupported BER length",
+		},
+		{
+			name: "element overruns message",
+			response: func(id int) []byte {
+				return berTLV(berTagSequence, []byte{berTagInteger, 0x05, byte(id)})
+			},
+			want: "malformed LDAP message ID",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := fakeLDAP(t, func(messageID int, _, _ string) []byte {
+				return tt.response(messageID)
+			})
+
+			ldap := NewLDAPAuthenticator(addr, "uid=%s,dc=example,dc=com")
+			ldap.Timeout = time.Second
+			err := ldap.Authenticate("alice", "secret")
+
+			switch {
+			case err == nil:
+				t.Fatal("Authenticate() succeeded, want an error")
+			case tt.want == "" && !errors.Is(err, ErrInvalidCredentials):
+				t.Fatalf("Authenticate() = %v, want ErrInvalidCredentials", err)
+			case tt.want != "" && !strings.Contains(err.Error(), tt.want):
+				t.Fatalf("Authenticate() = %v, want an error containing %q", err, tt.want)
+			case tt.want != "" && errors.Is(err, ErrInvalidCredentials):
+				t.Fatalf("Authenticate() = %v, a server fault must not look like a wrong password", err)
+			}
+		})
+	}
+}
+
+func TestLDAPEmptyPasswordIsNotSent(t *testing.T) {
+	// Nothing listens here, so reaching the network would fail differently.
+	ldap := NewLDAPAuthenticator("127.0.0.1:1", "uid=%s,dc=example,dc=com")
+	if err := ldap.Authenticate("alice", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Authenticate() = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestLDAPBindDNIsEscaped(t *testing.T) {
+	dns := make(chan string, 1)
+	addr := fakeLDAP(t, func(messageID int, dn, _ string) []byte {
+		dns <- dn
+		return bindResponse(messageID, ldapResultInvalidCredentials, "")
+	})
+
+	ldap := NewLDAPAuthenticator(addr, "uid=%s,dc=example,dc=com")
+	ldap.Authenticate("x,ou=admins", "secret")
+
+	if dn := <-dns; dn != `uid=x\,ou\=admins,dc=example,dc=com` {
+		t.Fatalf("bind DN = %q", dn)
+	}
+}
+
+func TestBERRoundTrip(t *testing.T) {
+	for _, v := range []int{0, 1, 127, 128, 255, 256, 65535, 1 << 20} {
+		tag, content, rest, err := parseBERElement(berInteger(v))
+		if err != nil || tag != berTagInteger || len(rest) != 0 || parseBERInteger(content) != v {
+			t.Errorf("integer %d: tag 0x%02x, value %d, rest %d, err %v", v, tag, parseBERInteger(content), len(rest), err)
+		}
+	}
+
+	for _, n := range []int{0, 127, 128, 300, 60000} {
+		content := bytes.Repeat([]byte{'a'}, n)
+		tag, got, _, err := parseBERElement(berTLV(berTagOctetStr, content))
+		if err != nil || tag != berTagOctetStr || !bytes.Equal(got, content) {
+			t.Errorf("octet string of %d bytes: tag 0x%02x, %d bytes, err %v", n, tag, len(got), err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"errors"
+	"log/slog"
+)
+
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+type Authenticator interface {
+	Authenticate(username, password string) error
+}
+
+type Chain struct {
+	providers []Authenticator
+	names     []string
+}
+
+func NewChain() *Chain {
+	return &Chain{}
+}
+
+func (c *Chain) Add(name string, provider Authenticator) {
+	c.providers = append(c.providers, provider)
+	c.names = append(c.names, name)
+}
+
+func (c *Chain) Len() int {
+	return len(c.providers)
+}
+
+// LocalOnly reports whether every provider in the chain is the local account
+// store. Only then do names registered with /register stay apart from
+// accounts that live elsewhere.
+func (c *Chain) LocalOnly() bool {
+	for _, provider := range c.providers {
+		if _, local := provider.(*Manager); !local {
+			return false
+		}
+	}
+	return len(c.providers) > 0
+}
+
+// Authenticate tries each provider in the order it was added and succeeds on
+// the first one that accepts the credentials. Provider failures other than a
+// plain credential mismatch are logged so that an unreachable directory does
+// not look like a wrong password.
+func (c *Chain) Authenticate(username, password string) error {
+	for i, provider := range c.providers {
+		err := provider.Authenticate(username, password)
+		if err == nil {
+			return nil
+		}
+
+		if !errors.Is(err, ErrInvalidCredentials) {
+			slog.Warn("Authentication provider failed", "provider", c.names[i], "user", username, "error", err)
+		}
+	}
+
+	return ErrInvalidCredentials
+}
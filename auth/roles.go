@@ -0,0 +1,63 @@
+package auth
+
+import "fmt"
+
+type Role int
+
+const (
+	RoleUser Role = iota
+	RoleModerator
+	RoleAdmin
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleModerator:
+		return "moderator"
+	case RoleAdmin:
+		return "admin"
+	default:
+		return "user"
+	}
+}
+
+func ParseRole(name string) (Role, error) {
+	switch name {
+	case "user":
+		return RoleUser, nil
+	case "moderator":
+		return RoleModerator, nil
+	case "admin":
+		return RoleAdmin, nil
+	default:
+		return RoleUser, fmt.Errorf("unknown role '%s'", name)
+	}
+}
+
+// Roles are kept separately from Users because accounts authenticated by an
+// external provider never appear in the local user store.
+func (am *Manager) SetRole(username string, role Role) {
+	am.UsersMutex.Lock()
+	defer am.UsersMutex.Unlock()
+
+	if role == RoleUser {
+		delete(am.Roles, username)
+		return
+	}
+	am.Roles[username] = role
+}
+
+func (am *Manager) GetRole(username string) Role {
+	am.UsersMutex.RLock()
+	defer am.UsersMutex.RUnlock()
+
+	return am.Roles[username]
+}
+
+func (am *Manager) IsAdmin(username string) bool {
+	return am.GetRole(username) == RoleAdmin
+}
+
+func (am *Manager) IsModerator(username string) bool {
+	return am.GetRole(username) >= RoleModerator
+}
@@ -0,0 +1,432 @@
+package chatclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
+)
+
+var (
+	ErrClosed       = errors.New("chatclient: client closed")
+	ErrDisconnected = errors.New("chatclient: disconnected from server")
+)
+
+// ServerError is a request refused by the server. Reply is the server's own
+// message, which is also delivered as an EventNotice.
+type ServerError struct {
+	Reply string
+}
+
+func (e *ServerError) Error() string {
+	return e.Reply
+}
+
+type Config struct {
+	DialTimeout  time.Duration
+	WriteTimeout time.Duration
+	// ReplyTimeout bounds how long a request waits for the server's answer
+	// when its context has no deadline of its own.
+	ReplyTimeout time.Duration
+	MaxFrameSize int
+	EventBuffer  int
+	// ChunkDelay paces file chunks the same way the interactive client
+	// always has.
+	ChunkDelay time.Duration
+
+	// Reconnect redials after the connection drops, logs in again with the
+	// last credentials that worked and rejoins the rooms the client was in.
+	Reconnect         bool
+	ReconnectMinDelay time.Duration
+	ReconnectMaxDelay time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		DialTimeout:       10 * time.Second,
+		WriteTimeout:      10 * time.Second,
+		ReplyTimeout:      10 * time.Second,
+		MaxFrameSize:      1 << 20,
+		EventBuffer:       100,
+		ChunkDelay:        10 * time.Millisecond,
+		ReconnectMinDelay: time.Second,
+		ReconnectMaxDelay: 30 * time.Second,
+	}
+}
+
+// waiter matches the server's reply to an outstanding request.
+type waiter struct {
+	match func(shared.Message) (bool, error)
+	done  chan error
+}
+
+type Client struct {
+	addr   string
+	config Config
+	events chan Event
+
+	conn    net.Conn
+	connMu  sync.Mutex
+	writeMu sync.Mutex
+
+	requestMu sync.Mutex
+	pending   *waiter
+	pendingMu sync.Mutex
+
+	username string
+	password string
+	rooms    map[string]bool
+	stateMu  sync.Mutex
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// Connect dials addr, which is "host:port", "tcp://host:port" or
+// "unix:///path/to/socket". ctx bounds the dial only; use Close to end the
+// session.
+func Connect(ctx context.Context, addr string, config Config) (*Client, error) {
+	c := &Client{
+		addr:   addr,
+		config: config,
+		events: make(chan Event, config.EventBuffer),
+		rooms:  make(map[string]bool),
+		closed: make(chan struct{}),
+	}
+
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+
+	go c.run(conn)
+	return c, nil
+}
+
+func splitAddress(addr string) (network, address string) {
+	if path, ok := strings.CutPrefix(addr, "unix://"); ok {
+		return "unix", path
+	}
+	return "tcp", strings.TrimPrefix(addr, "tcp://")
+}
+
+func (c *Client) dial(ctx context.Context) (net.Conn, error) {
+	network, address := splitAddress(c.addr)
+	dialer := net.Dialer{Timeout: c.config.DialTimeout}
+
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, fmt.Errorf("chatclient: connect to %s: %w", c.addr, err)
+	}
+	return conn, nil
+}
+
+// Events delivers everything the server sends. It must be drained: the
+// client stops reading from the server while the buffer is full. The channel
+// is closed once the client is closed or has given up reconnecting.
+func (c *Client) Events() <-chan Event {
+	return c.events
+}
+
+// Username is the account the client is logged in as, if any.
+func (c *Client) Username() string {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	return c.username
+}
+
+// Rooms lists the rooms the client has joined through Join.
+func (c *Client) Rooms() []string {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	rooms := make([]string, 0, len(c.rooms))
+	for roomName := range c.rooms {
+		rooms = append(rooms, roomName)
+	}
+	return rooms
+}
+
+func (c *Client) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.connMu.Lock()
+		err = c.conn.Close()
+		c.connMu.Unlock()
+	})
+	return err
+}
+
+func (c *Client) isClosed() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *Client) run(conn net.Conn) {
+	defer close(c.events)
+
+	for {
+		dropped, err := c.readLoop(conn)
+		c.failPending(ErrDisconnected)
+
+		if c.isClosed() {
+			return
+		}
+
+		c.emit(Event{Kind: EventDisconnected, Err: err})
+		if !c.config.Reconnect || dropped {
+			c.Close()
+			return
+		}
+
+		conn = c.redial()
+		if conn == nil {
+			return
+		}
+		go c.restore()
+	}
+}
+
+// redial retries with exponential backoff until it connects or the client
+// is closed.
+func (c *Client) redial() net.Conn {
+	delay := c.config.ReconnectMinDelay
+	for {
+		select {
+		case <-c.closed:
+			return nil
+		case <-time.After(delay):
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			select {
+			case <-c.closed:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		conn, err := c.dial(ctx)
+		cancel()
+
+		if err == nil {
+			c.connMu.Lock()
+			if c.isClosed() {
+				c.connMu.Unlock()
+				conn.Close()
+				return nil
+			}
+			c.conn = conn
+			c.connMu.Unlock()
+			return conn
+		}
+
+		delay *= 2
+		if delay > c.config.ReconnectMaxDelay {
+			delay = c.config.ReconnectMaxDelay
+		}
+	}
+}
+
+// restore logs in again and rejoins rooms after a reconnect.
+func (c *Client) restore() {
+	c.stateMu.Lock()
+	username, password := c.username, c.password
+	rooms := make([]string, 0, len(c.rooms))
+	for roomName := range c.rooms {
+		rooms = append(rooms, roomName)
+	}
+	c.stateMu.Unlock()
+
+	var err error
+	if username != "" {
+		err = c.Login(context.Background(), username, password)
+		for _, roomName := range rooms {
+			if err != nil {
+				break
+			}
+			err = c.Join(context.Background(), roomName)
+		}
+	}
+
+	c.emit(Event{Kind: EventReconnected, Err: err})
+}
+
+// droppedNotice recognises what the server says before it disconnects a
+// client on purpose. Reconnecting after one would undo a kick or ban.
+func droppedNotice(content string) bool {
+	notice, _, _ := strings.Cut(content, ": ")
+	return isReply(notice, shared.ReplyKicked, shared.ReplyAdminKicked,
+		shared.ReplyAdminBanned, shared.ReplyAddressBanned, shared.ReplyFlooding)
+}
+
+// readLoop reads until the connection fails and reports whether the server
+// dropped the client deliberately.
+func (c *Client) readLoop(conn net.Conn) (dropped bool, err error) {
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 4096), c.config.MaxFrameSize)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var msg shared.Message
+		if err := json.Unmarshal(line, &msg); err != nil {
+			msg = shared.Message{Type: shared.TextMessage, Content: string(line)}
+		}
+
+		switch msg.Type {
+		case shared.PingMessage:
+			c.send(shared.Message{Type: shared.PongMessage})
+			continue
+		case shared.PongMessage:
+			continue
+		}
+
+		switch {
+		case msg.Sender == shared.ServerName:
+			c.resolvePending(msg)
+			dropped = dropped || droppedNotice(msg.Content)
+		case msg.Type == shared.HistoryMessage, msg.Sender == c.Username():
+			// Replayed history answers a request, and the user's own edits,
+			// deletes and replies coming back from the room confirm one.
+			c.resolvePending(msg)
+		}
+		c.emit(newEvent(msg))
+	}
+
+	return dropped, scanner.Err()
+}
+
+func (c *Client) emit(event Event) {
+	select {
+	case c.events <- event:
+	case <-c.closed:
+	}
+}
+
+func (c *Client) resolvePending(msg shared.Message) {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+
+	if c.pending == nil {
+		return
+	}
+	if matched, err := c.pending.match(msg); matched {
+		c.pending.done <- err
+		c.pending = nil
+	}
+}
+
+func (c *Client) failPending(err error) {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+
+	if c.pending != nil {
+		c.pending.done <- err
+		c.pending = nil
+	}
+}
+
+// begin makes match the outstanding request. Only one request waits at a
+// time, since server replies carry no request identifier; end releases it.
+func (c *Client) begin(match func(shared.Message) (bool, error)) (*waiter, error) {
+	c.requestMu.Lock()
+	if c.isClosed() {
+		c.requestMu.Unlock()
+		return nil, ErrClosed
+	}
+
+	w := &waiter{match: match, done: make(chan error, 1)}
+	c.pendingMu.Lock()
+	c.pending = w
+	c.pendingMu.Unlock()
+	return w, nil
+}
+
+func (c *Client) end(w *waiter) {
+	c.pendingMu.Lock()
+	if c.pending == w {
+		c.pending = nil
+	}
+	c.pendingMu.Unlock()
+	c.requestMu.Unlock()
+}
+
+// wait blocks until w is answered, applying ReplyTimeout when ctx has no
+// deadline of its own.
+func (c *Client) wait(ctx context.Context, w *waiter) error {
+	if _, ok := ctx.Deadline(); !ok && c.config.ReplyTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.config.ReplyTimeout)
+		defer cancel()
+	}
+
+	select {
+	case err := <-w.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.closed:
+		return ErrClosed
+	}
+}
+
+// request sends line and waits until match recognises the server's answer.
+func (c *Client) request(ctx context.Context, line string, match func(shared.Message) (bool, error)) error {
+	w, err := c.begin(match)
+	if err != nil {
+		return err
+	}
+	defer c.end(w)
+
+	if err := c.sendLine(line); err != nil {
+		return err
+	}
+	return c.wait(ctx, w)
+}
+
+func (c *Client) write(data []byte) error {
+	c.connMu.Lock()
+	conn := c.conn
+	c.connMu.Unlock()
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.config.WriteTimeout > 0 {
+		conn.SetWriteDeadline(time.Now().Add(c.config.WriteTimeout))
+	}
+	_, err := conn.Write(data)
+	return err
+}
+
+func (c *Client) send(msg shared.Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return c.write(append(data, '\n'))
+}
+
+func (c *Client) sendLine(line string) error {
+	if strings.ContainsAny(line, "\r\n") {
+		return fmt.Errorf("chatclient: line contains a newline")
+	}
+	return c.write([]byte(line + "\n"))
+}
@@ -0,0 +1,481 @@
+package chatclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/imaneimrh/TCP-Chat_Server/auth"
+	"github.com/imaneimrh/TCP-Chat_Server/client"
+	"github.com/imaneimrh/TCP-Chat_Server/room"
+	"github.com/imaneimrh/TCP-Chat_Server/search"
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
+)
+
+// startServer runs a chat handler on a loopback port and returns its
+// address.
+func startServer(t *testing.T) (*client.Handler, string) {
+	t.Helper()
+
+	h := client.NewHandler(room.NewManager(), auth.NewManager())
+	// The tests send requests faster than a person types.
+	h.Limits = client.RateLimits{}
+	go h.Run()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			h.HandleClient(conn)
+		}
+	}()
+
+	return h, listener.Addr().String()
+}
+
+// connect dials addr and forwards the client's events to the returned
+// channel, which is closed with the client.
+func connect(t *testing.T, addr string) (*Client, <-chan Event) {
+	t.Helper()
+
+	config := DefaultConfig()
+	config.ReplyTimeout = 2 * time.Second
+	config.ChunkDelay = 0
+
+	c, err := Connect(context.Background(), addr, config)
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	events := make(chan Event, 100)
+	go func() {
+		defer close(events)
+		for event := range c.Events() {
+			select {
+			case events <- event:
+			default:
+			}
+		}
+	}()
+	return c, events
+}
+
+// login registers username and logs in as it.
+func login(t *testing.T, addr, username string) (*Client, <-chan Event) {
+	t.Helper()
+
+	c, events := connect(t, addr)
+	ctx := context.Background()
+	if err := c.Register(ctx, username, "secret"); err != nil {
+		t.Fatalf("Register(%s) error = %v", username, err)
+	}
+	if err := c.Login(ctx, username, "secret"); err != nil {
+		t.Fatalf("Login(%s) error = %v", username, err)
+	}
+	return c, events
+}
+
+func waitEvent(t *testing.T, events <-chan Event, match func(Event) bool) Event {
+	t.Helper()
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatal("client closed while waiting for an event")
+			}
+			if match(event) {
+				return event
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for an event")
+		}
+	}
+}
+
+func isRefusal(err error) bool {
+	var refused *ServerError
+	return errors.As(err, &refused)
+}
+
+func TestAccountRequests(t *testing.T) {
+	_, addr := startServer(t)
+	c, _ := connect(t, addr)
+	ctx := context.Background()
+
+	refusals := []struct {
+		name string
+		err  error
+	}{
+		{"short username", c.Register(ctx, "al", "secret")},
+		{"short password", c.Register(ctx, "alice", "pw")},
+		{"reserved name", c.Register(ctx, shared.ServerName, "secret")},
+		{"unknown user", c.Login(ctx, "nobody", "secret")},
+	}
+	for _, tt := range refusals {
+		if !isRefusal(tt.err) {
+			t.Errorf("%s: error = %v, want a ServerError", tt.name, tt.err)
+		}
+	}
+
+	if err := c.Register(ctx, "alice", "secret"); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := c.Register(ctx, "alice", "secret"); !isRefusal(err) {
+		t.Fatalf("duplicate Register() error = %v, want a ServerError", err)
+	}
+	if err := c.Login(ctx, "alice", "wrong"); !isRefusal(err) {
+		t.Fatalf("Login() with a wrong password error = %v, want a ServerError", err)
+	}
+	if err := c.Login(ctx, "alice", "secret"); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	if c.Username() != "alice" {
+		t.Fatalf("Username() = %q", c.Username())
+	}
+
+	other, _ := connect(t, addr)
+	if err := other.Login(ctx, "alice", "secret"); !isRefusal(err) {
+		t.Fatalf("second Login() error = %v, want a ServerError", err)
+	}
+}
+
+func TestRoomRequests(t *testing.T) {
+	_, addr := startServer(t)
+	alice, _ := login(t, addr, "alice")
+	bob, bobEvents := login(t, addr, "bob")
+	ctx := context.Background()
+
+	if err := alice.CreateRoom(ctx, "dev"); err != nil {
+		t.Fatalf("CreateRoom() error = %v", err)
+	}
+	if err := alice.CreateRoom(ctx, "dev"); !isRefusal(err) {
+		t.Fatalf("duplicate CreateRoom() error = %v, want a ServerError", err)
+	}
+	if err := alice.Join(ctx, "missing"); !isRefusal(err) {
+		t.Fatalf("Join() of a missing room error = %v, want a ServerError", err)
+	}
+	if err := alice.Join(ctx, "dev"); err != nil {
+		t.Fatalf("Join() error = %v", err)
+	}
+	if err := alice.Join(ctx, "dev"); err != nil {
+		t.Fatalf("repeated Join() error = %v", err)
+	}
+	if err := bob.Join(ctx, "dev"); err != nil {
+		t.Fatalf("Join() error = %v", err)
+	}
+	waitEvent(t, bobEvents, func(e Event) bool { return e.Kind == EventJoin && e.Room == "dev" && e.From == "bob" })
+
+	if err := alice.Leave(ctx, "dev"); err != nil {
+		t.Fatalf("Leave() error = %v", err)
+	}
+	waitEvent(t, bobEvents, func(e Event) bool { return e.Kind == EventLeave && e.Room == "dev" && e.From == "alice" })
+	if err := alice.Leave(ctx, "dev"); err != nil {
+		t.Fatalf("repeated Leave() error = %v", err)
+	}
+
+	if err := alice.DM(ctx, "bob", "hello"); err != nil {
+		t.Fatalf("DM() error = %v", err)
+	}
+	event := waitEvent(t, bobEvents, func(e Event) bool { return e.Kind == EventDirect })
+	if event.From != "alice" || event.Text != "hello" {
+		t.Fatalf("direct message event = %+v", event)
+	}
+	if err := alice.DM(ctx, "carol", "hello"); !isRefusal(err) {
+		t.Fatalf("DM() to an offline user error = %v, want a ServerError", err)
+	}
+}
+
+func TestMessageRequests(t *testing.T) {
+	_, addr := startServer(t)
+	alice, aliceEvents := login(t, addr, "alice")
+	bob, bobEvents := login(t, addr, "bob")
+	ctx := context.Background()
+
+	if err := alice.Send("general", "first post"); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	post := waitEvent(t, bobEvents, func(e Event) bool { return e.Kind == EventMessage && e.Text == "first post" })
+	if post.ID == "" {
+		t.Fatal("room message has no ID")
+	}
+
+	if err := alice.Edit(ctx, post.ID, "edited post"); err != nil {
+		t.Fatalf("Edit() error = %v", err)
+	}
+	if err := bob.Edit(ctx, post.ID, "not mine"); !isRefusal(err) {
+		t.Fatalf("Edit() of another user's post error = %v, want a ServerError", err)
+	}
+
+	replyID, err := bob.Reply(ctx, post.ID, "a reply")
+	if err != nil || replyID == "" {
+		t.Fatalf("Reply() = %q, %v", replyID, err)
+	}
+	waitEvent(t, aliceEvents, func(e Event) bool { return e.Kind == EventMessage && e.ID == replyID && e.Parent == post.ID })
+
+	// Thread activity is announced to participants outside the room.
+	if err := alice.Leave(ctx, "general"); err != nil {
+		t.Fatalf("Leave() error = %v", err)
+	}
+	if _, err := bob.Reply(ctx, replyID, "another reply"); err != nil {
+		t.Fatalf("Reply() error = %v", err)
+	}
+	activity := waitEvent(t, aliceEvents, func(e Event) bool { return e.Kind == EventThreadReply })
+	if activity.From != "bob" || activity.Room != "general" || activity.Parent != post.ID || activity.Text != "another reply" {
+		t.Fatalf("thread reply event = %+v", activity)
+	}
+	if _, err := alice.Thread(ctx, replyID); !isRefusal(err) {
+		t.Fatalf("Thread() outside the room error = %v, want a ServerError", err)
+	}
+	if err := alice.Join(ctx, "general"); err != nil {
+		t.Fatalf("Join() error = %v", err)
+	}
+
+	posts, err := alice.Thread(ctx, replyID)
+	if err != nil {
+		t.Fatalf("Thread() error = %v", err)
+	}
+	if len(posts) != 3 || posts[0].ID != post.ID || posts[0].Text != "edited post" || posts[1].ID != replyID {
+		t.Fatalf("Thread() = %+v", posts)
+	}
+
+	if err := bob.React(ctx, post.ID, "👍"); err != nil {
+		t.Fatalf("React() error = %v", err)
+	}
+	if err := bob.React(ctx, post.ID, "👍"); !isRefusal(err) {
+		t.Fatalf("repeated React() error = %v, want a ServerError", err)
+	}
+	if err := bob.Unreact(ctx, post.ID, "👍"); err != nil {
+		t.Fatalf("Unreact() error = %v", err)
+	}
+	if err := bob.Unreact(ctx, post.ID, "👍"); !isRefusal(err) {
+		t.Fatalf("repeated Unreact() error = %v, want a ServerError", err)
+	}
+
+	if err := bob.Delete(ctx, post.ID); !isRefusal(err) {
+		t.Fatalf("Delete() of another user's post error = %v, want a ServerError", err)
+	}
+	if err := alice.Delete(ctx, post.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	for name, err := range map[string]error{
+		"Edit":    alice.Edit(ctx, "999999", "text"),
+		"Delete":  alice.Delete(ctx, "999999"),
+		"React":   alice.React(ctx, "999999", "👍"),
+		"Unreact": alice.Unreact(ctx, "999999", "👍"),
+	} {
+		if !isRefusal(err) {
+			t.Errorf("%s() of a missing message error = %v, want a ServerError", name, err)
+		}
+	}
+	if _, err := alice.Reply(ctx, "999999", "text"); !isRefusal(err) {
+		t.Errorf("Reply() to a missing message error = %v, want a ServerError", err)
+	}
+	if _, err := alice.Thread(ctx, "999999"); !isRefusal(err) {
+		t.Errorf("Thread() of a missing message error = %v, want a ServerError", err)
+	}
+}
+
+func TestReadRequests(t *testing.T) {
+	_, addr := startServer(t)
+	alice, _ := login(t, addr, "alice")
+	bob, bobEvents := login(t, addr, "bob")
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if err := alice.Send("general", fmt.Sprintf("post %d", i)); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+	waitEvent(t, bobEvents, func(e Event) bool { return e.Kind == EventMessage && e.Text == "post 2" })
+
+	unread, err := bob.Unread(ctx)
+	if err != nil {
+		t.Fatalf("Unread() error = %v", err)
+	}
+	if unread["general"] != 3 {
+		t.Fatalf("Unread() = %v, want 3 in general", unread)
+	}
+
+	if err := bob.MarkRead(ctx, "general", ""); err != nil {
+		t.Fatalf("MarkRead() error = %v", err)
+	}
+	if err := bob.MarkRead(ctx, "missing", ""); !isRefusal(err) {
+		t.Fatalf("MarkRead() of a missing room error = %v, want a ServerError", err)
+	}
+	if err := bob.MarkRead(ctx, "", ""); err != nil {
+		t.Fatalf("MarkRead() of everything error = %v", err)
+	}
+
+	unread, err = bob.Unread(ctx)
+	if err != nil || len(unread) != 0 {
+		t.Fatalf("Unread() after MarkRead() = %v, %v", unread, err)
+	}
+}
+
+func TestSearchDisabled(t *testing.T) {
+	_, addr := startServer(t)
+	alice, _ := login(t, addr, "alice")
+
+	if _, err := alice.Search(context.Background(), "anything"); !isRefusal(err) {
+		t.Fatalf("Search() error = %v, want a ServerError", err)
+	}
+}
+
+func TestSearchRequests(t *testing.T) {
+	h, addr := startServer(t)
+	h.Search = search.NewIndex(search.DefaultConfig())
+	h.RoomManager.AddObserver(h.Search)
+
+	alice, _ := login(t, addr, "alice")
+	_, bobEvents := login(t, addr, "bob")
+	ctx := context.Background()
+
+	for _, text := range []string{"morning", "the build is red", "fixed it"} {
+		if err := alice.Send("general", text); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		waitEvent(t, bobEvents, func(e Event) bool { return e.Kind == EventMessage && e.Text == text })
+	}
+
+	results, err := alice.Search(ctx, "build in:general")
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(results.Posts) != 1 || results.Posts[0].Text != "the build is red" || results.Total != 1 || results.Pages != 1 {
+		t.Fatalf("Search() = %+v", results)
+	}
+
+	var texts []string
+	for _, post := range results.Context[results.Posts[0].ID] {
+		texts = append(texts, post.Text)
+	}
+	if want := []string{"morning", "the build is red", "fixed it"}; !slices.Equal(texts, want) {
+		t.Fatalf("context = %q, want %q", texts, want)
+	}
+
+	if _, err := alice.Search(ctx, "build page:99999999999999999999"); !isRefusal(err) {
+		t.Fatalf("Search() with a huge page error = %v, want a ServerError", err)
+	}
+	if results, err := alice.Search(ctx, "build page:5"); err != nil || len(results.Posts) != 0 || results.Page != 5 {
+		t.Fatalf("Search() past the last page = %+v, %v", results, err)
+	}
+}
+
+func TestSendFile(t *testing.T) {
+	// The server saves received files under downloads/ in its working
+	// directory.
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	_, addr := startServer(t)
+	alice, _ := login(t, addr, "alice")
+	_, bobEvents := login(t, addr, "bob")
+
+	path := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(path, make([]byte, 3*chunkSize+100), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var sent int64
+	err = alice.SendFile(context.Background(), "bob", path, func(n, total int64) { sent = n })
+	if err != nil {
+		t.Fatalf("SendFile() error = %v", err)
+	}
+	if sent != 3*chunkSize+100 {
+		t.Fatalf("progress reported %d bytes sent", sent)
+	}
+
+	event := waitEvent(t, bobEvents, func(e Event) bool { return e.Kind == EventFileReceived })
+	if event.From != "alice" || event.File != "notes.txt" {
+		t.Fatalf("file event = %+v", event)
+	}
+}
+
+func TestKickEndsSession(t *testing.T) {
+	h, addr := startServer(t)
+	alice, events := login(t, addr, "alice")
+
+	if !h.Kick("alice", fmt.Sprintf(shared.ReplyKicked, "kicked", "mod")+": spam") {
+		t.Fatal("Kick() found no session")
+	}
+
+	event := waitEvent(t, events, func(e Event) bool { return e.Kind == EventDisconnected })
+	if event.Err != nil {
+		t.Fatalf("disconnect after a kick reported %v", event.Err)
+	}
+	if err := alice.Join(context.Background(), "general"); err == nil {
+		t.Fatal("request after a kick succeeded")
+	}
+}
+
+func TestDroppedNotice(t *testing.T) {
+	tests := []struct {
+		content string
+		want    bool
+	}{
+		{fmt.Sprintf(shared.ReplyKicked, "kicked", "mod"), true},
+		{fmt.Sprintf(shared.ReplyKicked, "banned", "admin") + ": spam: again", true},
+		{shared.ReplyAdminKicked + ": maintenance", true},
+		{shared.ReplyAdminBanned, true},
+		{shared.ReplyAddressBanned, true},
+		{shared.ReplyFlooding, true},
+		{fmt.Sprintf(shared.ReplyMuted, time.Minute), false},
+		{fmt.Sprintf(shared.ReplyJoined, "general"), false},
+	}
+
+	for _, tt := range tests {
+		if got := droppedNotice(tt.content); got != tt.want {
+			t.Errorf("droppedNotice(%q) = %v, want %v", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestParseReply(t *testing.T) {
+	box := fmt.Sprintf(shared.ReplyFileSent, "report.pdf", 12.5, "bob")
+	values, ok := parseReply(box, shared.ReplyFileSent)
+	if !ok || values[0] != "report.pdf" || values[1] != "12.50" || values[2] != "bob" {
+		t.Fatalf("parseReply() = %q, %v", values, ok)
+	}
+	if _, ok := parseReply(box, shared.ReplyFileReceived); ok {
+		t.Fatal("sent box parsed as a received one")
+	}
+
+	page := fmt.Sprintf(shared.ReplySearchPage, 25, "results", 1, 3, " (add page:2 for more)")
+	values, ok = parseReply(page, shared.ReplySearchPage)
+	if !ok || values[0] != "25" || values[2] != "1" || values[3] != "3" {
+		t.Fatalf("parseReply() = %q, %v", values, ok)
+	}
+
+	if !matchReply("You have joined room: dev", shared.ReplyJoined, "dev") ||
+		matchReply("You have joined room: dev2", shared.ReplyJoined, "dev") {
+		t.Fatal("matchReply() compared the room name wrongly")
+	}
+	if isReply("100% sure", "100%% sure") != true {
+		t.Fatal("isReply() did not treat %% as a literal percent sign")
+	}
+}
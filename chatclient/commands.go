@@ -0,0 +1,561 @@
+package chatclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
+)
+
+// chunkSize matches the chunks the server writes to disk.
+const chunkSize = 8192
+
+// genericRefusal recognises replies the server gives to any command it will
+// not run.
+func genericRefusal(content string) bool {
+	return isReply(content, shared.ReplyLoginRequired, shared.ReplyLoginRequiredFor,
+		shared.ReplyUsage, shared.ReplyMuted)
+}
+
+// replyMatcher builds a matcher from a test for the reply that means success
+// and the reply formats that mean failure.
+func replyMatcher(success func(string) bool, failure ...string) func(shared.Message) (bool, error) {
+	return func(msg shared.Message) (bool, error) {
+		if msg.Sender != shared.ServerName {
+			return false, nil
+		}
+		if success(msg.Content) {
+			return true, nil
+		}
+		if isReply(msg.Content, failure...) || genericRefusal(msg.Content) {
+			return true, &ServerError{Reply: msg.Content}
+		}
+		return false, nil
+	}
+}
+
+// checkArgs rejects arguments the server would split on.
+func checkArgs(args ...string) error {
+	for _, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\r\n") {
+			return fmt.Errorf("chatclient: invalid argument %q", arg)
+		}
+	}
+	return nil
+}
+
+func (c *Client) Register(ctx context.Context, username, password string) error {
+	if err := checkArgs(username, password); err != nil {
+		return err
+	}
+	return c.request(ctx, "/register "+username+" "+password,
+		replyMatcher(reply(shared.ReplyRegistered, username),
+			shared.ReplyRegisterFailed, shared.ReplyRegistrationClosed,
+			shared.ReplyUsernameTooShort, shared.ReplyPasswordTooShort))
+}
+
+// Login authenticates the session. The credentials are kept so that a
+// reconnecting client can log in again.
+func (c *Client) Login(ctx context.Context, username, password string) error {
+	if err := checkArgs(username, password); err != nil {
+		return err
+	}
+
+	err := c.request(ctx, "/login "+username+" "+password,
+		replyMatcher(reply(shared.ReplyLoggedIn, username),
+			shared.ReplyLoginFailed, shared.ReplyAlreadyLoggedIn))
+	if err != nil {
+		return err
+	}
+
+	c.stateMu.Lock()
+	if c.username != username {
+		c.rooms = map[string]bool{"general": true}
+	}
+	c.username, c.password = username, password
+	c.stateMu.Unlock()
+	return nil
+}
+
+func (c *Client) CreateRoom(ctx context.Context, roomName string) error {
+	if err := checkArgs(roomName); err != nil {
+		return err
+	}
+	return c.request(ctx, "/create "+roomName,
+		replyMatcher(reply(shared.ReplyRoomCreated, roomName), shared.ReplyCreateFailed))
+}
+
+func (c *Client) Join(ctx context.Context, roomName string) error {
+	if err := checkArgs(roomName); err != nil {
+		return err
+	}
+
+	err := c.request(ctx, "/join "+roomName,
+		replyMatcher(func(content string) bool {
+			return matchReply(content, shared.ReplyJoined, roomName) ||
+				matchReply(content, shared.ReplyAlreadyInRoom, roomName)
+		}, shared.ReplyJoinFailed))
+	if err != nil {
+		return err
+	}
+
+	c.stateMu.Lock()
+	c.rooms[roomName] = true
+	c.stateMu.Unlock()
+	return nil
+}
+
+func (c *Client) Leave(ctx context.Context, roomName string) error {
+	if err := checkArgs(roomName); err != nil {
+		return err
+	}
+
+	err := c.request(ctx, "/leave "+roomName,
+		replyMatcher(func(content string) bool {
+			return matchReply(content, shared.ReplyLeft, roomName) ||
+				matchReply(content, shared.ReplyNotMember, roomName)
+		}, shared.ReplyLeaveFailed))
+	if err != nil {
+		return err
+	}
+
+	c.stateMu.Lock()
+	delete(c.rooms, roomName)
+	c.stateMu.Unlock()
+	return nil
+}
+
+// Send posts text to a room the client has joined. The server does not
+// acknowledge room messages; they come back as an EventMessage like
+// everyone else's. Text starting with "/" is run as a command.
+func (c *Client) Send(roomName, text string) error {
+	if c.isClosed() {
+		return ErrClosed
+	}
+	return c.send(shared.Message{
+		Type:     shared.TextMessage,
+		RoomName: roomName,
+		Content:  text,
+	})
+}
+
+// DM sends a direct message and waits for the server to confirm delivery.
+func (c *Client) DM(ctx context.Context, username, text string) error {
+	if err := checkArgs(username); err != nil {
+		return err
+	}
+	if strings.ContainsAny(text, "\r\n") {
+		return fmt.Errorf("chatclient: direct messages cannot contain newlines")
+	}
+
+	return c.request(ctx, "/msg "+username+" "+text, func(msg shared.Message) (bool, error) {
+		switch {
+		case msg.Recipient == username && matchReply(msg.Content, shared.ReplyDirectSent, username):
+			return true, nil
+		case matchReply(msg.Content, shared.ReplyNotOnline, username), genericRefusal(msg.Content):
+			return true, &ServerError{Reply: msg.Content}
+		}
+		return false, nil
+	})
+}
+
+// Edit replaces the text of one of the user's room messages and waits for
+// the edit to come back from the room.
+func (c *Client) Edit(ctx context.Context, id, text string) error {
+	if err := checkArgs(id); err != nil {
+		return err
+	}
+	if strings.TrimSpace(text) == "" || strings.ContainsAny(text, "\r\n") {
+		return fmt.Errorf("chatclient: invalid message text")
+	}
+
+	return c.request(ctx, "/edit "+id+" "+text, func(msg shared.Message) (bool, error) {
+		switch {
+		case msg.Type == shared.EditMessage && msg.ID == id:
+			return true, nil
+		case msg.Sender != shared.ServerName:
+			return false, nil
+		case isReply(msg.Content, shared.ReplyEditFailed, shared.ReplyNotInRoom),
+			genericRefusal(msg.Content):
+			return true, &ServerError{Reply: msg.Content}
+		}
+		return false, nil
+	})
+}
+
+// Delete removes a room message. Users may delete their own messages and
+// moderators anyone's.
+func (c *Client) Delete(ctx context.Context, id string) error {
+	if err := checkArgs(id); err != nil {
+		return err
+	}
+
+	return c.request(ctx, "/delete "+id, func(msg shared.Message) (bool, error) {
+		switch {
+		case msg.Type == shared.DeleteMessage && msg.ID == id:
+			return true, nil
+		case msg.Sender != shared.ServerName:
+			return false, nil
+		case matchReply(msg.Content, shared.ReplyDeleted, id):
+			return true, nil
+		case isReply(msg.Content, shared.ReplyDeleteFailed), genericRefusal(msg.Content):
+			return true, &ServerError{Reply: msg.Content}
+		}
+		return false, nil
+	})
+}
+
+// Reply posts text to the thread of message parentID and returns the ID of
+// the reply. Replying to a reply continues the same thread.
+func (c *Client) Reply(ctx context.Context, parentID, text string) (string, error) {
+	if err := checkArgs(parentID); err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(text) == "" || strings.ContainsAny(text, "\r\n") {
+		return "", fmt.Errorf("chatclient: invalid message text")
+	}
+
+	var id string
+	err := c.request(ctx, "/reply "+parentID+" "+text, func(msg shared.Message) (bool, error) {
+		switch {
+		case msg.Type == shared.TextMessage && msg.RoomName != "" && msg.ParentID != "" && msg.Sender == c.Username():
+			id = msg.ID
+			return true, nil
+		case msg.Sender != shared.ServerName:
+			return false, nil
+		case isReply(msg.Content, shared.ReplyReplyFailed, shared.ReplyNotSent,
+			shared.ReplyNotInRoom, shared.ReplySlowMode),
+			genericRefusal(msg.Content):
+			return true, &ServerError{Reply: msg.Content}
+		}
+		return false, nil
+	})
+	return id, err
+}
+
+// Post is a stored message, as returned by Thread and Search. Text is empty
+// for deleted messages. Reactions counts the users who gave each reaction.
+// Direct messages have no Room but a To.
+type Post struct {
+	ID        string
+	Parent    string
+	Room      string
+	From      string
+	To        string
+	Text      string
+	Reactions map[string]int
+}
+
+// Thread fetches the message that started the thread containing id,
+// followed by its replies.
+func (c *Client) Thread(ctx context.Context, id string) ([]Post, error) {
+	if err := checkArgs(id); err != nil {
+		return nil, err
+	}
+
+	var posts []Post
+	err := c.request(ctx, "/thread "+id, func(msg shared.Message) (bool, error) {
+		switch {
+		case msg.Type == shared.HistoryMessage:
+			posts = append(posts, newPost(msg))
+			return false, nil
+		case msg.Sender != shared.ServerName:
+			return false, nil
+		case isReply(msg.Content, shared.ReplyThread):
+			return true, nil
+		case isReply(msg.Content, shared.ReplyThreadFailed, shared.ReplyNotInRoom), genericRefusal(msg.Content):
+			return true, &ServerError{Reply: msg.Content}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+func newPost(msg shared.Message) Post {
+	return Post{
+		ID:        msg.ID,
+		Parent:    msg.ParentID,
+		Room:      msg.RoomName,
+		From:      msg.Sender,
+		To:        msg.Recipient,
+		Text:      msg.Content,
+		Reactions: msg.Reactions,
+	}
+}
+
+// MarkRead moves the user's read marker in a room, or in a direct
+// conversation given as "@user", to message id, or to the newest message
+// when id is empty. An empty conversation marks everything read. The
+// server answers with the new marker, which also arrives as an EventRead.
+func (c *Client) MarkRead(ctx context.Context, conversation, id string) error {
+	line := "/read"
+	if conversation != "" {
+		if err := checkArgs(conversation); err != nil {
+			return err
+		}
+		line += " " + conversation
+	}
+	if id != "" {
+		if err := checkArgs(id); err != nil || conversation == "" {
+			return fmt.Errorf("chatclient: invalid message ID %q", id)
+		}
+		line += " " + id
+	}
+
+	return c.request(ctx, line, func(msg shared.Message) (bool, error) {
+		switch {
+		case msg.Type == shared.ReadMessage:
+			return conversation != "" && readConversation(msg) == conversation, nil
+		case msg.Sender != shared.ServerName:
+			return false, nil
+		case conversation == "" && msg.Content == shared.ReplyAllRead:
+			return true, nil
+		case isReply(msg.Content, shared.ReplyReadFailed), genericRefusal(msg.Content):
+			return true, &ServerError{Reply: msg.Content}
+		}
+		return false, nil
+	})
+}
+
+// Unread returns the number of unread messages in each room and direct
+// conversation ("@user") that has any.
+func (c *Client) Unread(ctx context.Context) (map[string]int, error) {
+	unread := make(map[string]int)
+	err := c.request(ctx, "/unread", func(msg shared.Message) (bool, error) {
+		switch {
+		case msg.Type == shared.ReadMessage:
+			unread[readConversation(msg)] = msg.Unread
+			return false, nil
+		case msg.Sender != shared.ServerName:
+			return false, nil
+		case isReply(msg.Content, shared.ReplyNoUnread, shared.ReplyUnread):
+			return true, nil
+		case genericRefusal(msg.Content):
+			return true, &ServerError{Reply: msg.Content}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return unread, nil
+}
+
+// readConversation names the conversation of a ReadMessage frame.
+func readConversation(msg shared.Message) string {
+	if msg.RoomName != "" {
+		return msg.RoomName
+	}
+	return "@" + msg.Recipient
+}
+
+// SearchResults is one page of search results, newest first. Context holds
+// each result with the messages around it, oldest first, by the result's ID.
+type SearchResults struct {
+	Posts   []Post
+	Context map[string][]Post
+	Total   int
+	Page    int
+	Pages   int
+}
+
+// Search looks through the messages of the user's rooms and their direct
+// messages. query takes the same words and in:, from:, before:, after: and
+// page: filters as /search.
+func (c *Client) Search(ctx context.Context, query string) (SearchResults, error) {
+	if strings.TrimSpace(query) == "" || strings.ContainsAny(query, "\r\n") {
+		return SearchResults{}, fmt.Errorf("chatclient: invalid search query")
+	}
+
+	results := SearchResults{Context: make(map[string][]Post)}
+	err := c.request(ctx, "/search "+query, func(msg shared.Message) (bool, error) {
+		switch {
+		case msg.Type == shared.HistoryMessage && msg.Context != "":
+			results.Context[msg.Context] = append(results.Context[msg.Context], newPost(msg))
+			return false, nil
+		case msg.Type == shared.HistoryMessage:
+			post := newPost(msg)
+			results.Posts = append(results.Posts, post)
+			results.Context[post.ID] = append(results.Context[post.ID], post)
+			return false, nil
+		case msg.Sender != shared.ServerName:
+			return false, nil
+		case msg.Content == shared.ReplyNoResults:
+			return true, nil
+		case isReply(msg.Content, shared.ReplySearchPage):
+			m, _ := parseReply(msg.Content, shared.ReplySearchPage)
+			results.Total, _ = strconv.Atoi(m[0])
+			results.Page, _ = strconv.Atoi(m[2])
+			results.Pages, _ = strconv.Atoi(m[3])
+			return true, nil
+		case isReply(msg.Content, shared.ReplySearchFailed, shared.ReplySearchDisabled),
+			genericRefusal(msg.Content):
+			return true, &ServerError{Reply: msg.Content}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return SearchResults{}, err
+	}
+	return results, nil
+}
+
+// React adds a reaction such as an emoji to a room message. Each user can
+// give each reaction once per message.
+func (c *Client) React(ctx context.Context, id, emoji string) error {
+	return c.reaction(ctx, "/react", "+", shared.ReplyReactFailed, id, emoji)
+}
+
+// Unreact takes back a reaction given with React.
+func (c *Client) Unreact(ctx context.Context, id, emoji string) error {
+	return c.reaction(ctx, "/unreact", "-", shared.ReplyUnreactFailed, id, emoji)
+}
+
+func (c *Client) reaction(ctx context.Context, command, change, refusal, id, emoji string) error {
+	if err := checkArgs(id, emoji); err != nil {
+		return err
+	}
+
+	return c.request(ctx, command+" "+id+" "+emoji, func(msg shared.Message) (bool, error) {
+		switch {
+		case msg.Type == shared.ReactionMessage && msg.ID == id && msg.Content == change+emoji:
+			return true, nil
+		case msg.Sender != shared.ServerName:
+			return false, nil
+		case isReply(msg.Content, refusal, shared.ReplyNotInRoom),
+			genericRefusal(msg.Content):
+			return true, &ServerError{Reply: msg.Content}
+		}
+		return false, nil
+	})
+}
+
+// Command sends a raw command line such as "/topic general hello" without
+// waiting for a reply; the answer arrives as an EventNotice.
+func (c *Client) Command(line string) error {
+	if c.isClosed() {
+		return ErrClosed
+	}
+	return c.sendLine(line)
+}
+
+// Progress is called after each chunk of a file transfer.
+type Progress func(sent, total int64)
+
+// SendFile uploads path for recipient in chunks and waits for the server to
+// confirm the transfer. progress may be nil. Other requests wait until the
+// transfer has finished.
+func (c *Client) SendFile(ctx context.Context, recipient, path string, progress Progress) error {
+	if err := checkArgs(recipient); err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("chatclient: %s is a directory", path)
+	}
+
+	fileName := filepath.Base(path)
+	total := info.Size()
+
+	w, err := c.begin(func(msg shared.Message) (bool, error) {
+		if msg.Sender != shared.ServerName {
+			return false, nil
+		}
+		if matchReply(msg.Content, shared.ReplyFileSent, fileName) {
+			return true, nil
+		}
+		if isReply(msg.Content, shared.ReplyProtocolError) || genericRefusal(msg.Content) {
+			return true, &ServerError{Reply: msg.Content}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return err
+	}
+	defer c.end(w)
+
+	err = c.send(shared.Message{
+		Type:      shared.FileTransferRequest,
+		Recipient: recipient,
+		FileName:  fileName,
+		FileSize:  int(total),
+	})
+	if err != nil {
+		return err
+	}
+
+	buffer := make([]byte, chunkSize)
+	var offset int64
+
+	for {
+		n, err := file.Read(buffer)
+		if n > 0 {
+			err := c.send(shared.Message{
+				Type:       shared.FileTransferData,
+				Recipient:  recipient,
+				FileName:   fileName,
+				FileSize:   int(total),
+				FileData:   buffer[:n],
+				FileOffset: int(offset),
+			})
+			if err != nil {
+				return err
+			}
+
+			offset += int64(n)
+			if progress != nil {
+				progress(offset, total)
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-w.done:
+			if err == nil {
+				err = fmt.Errorf("chatclient: transfer confirmed before it finished")
+			}
+			return err
+		case <-time.After(c.config.ChunkDelay):
+		}
+	}
+
+	err = c.send(shared.Message{
+		Type:       shared.FileTransferComplete,
+		Recipient:  recipient,
+		FileName:   fileName,
+		FileSize:   int(total),
+		FileOffset: int(offset),
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.wait(ctx, w)
+}
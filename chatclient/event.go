@@ -0,0 +1,190 @@
+package chatclient
+
+import (
+	"strings"
+
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
+)
+
+type EventKind int
+
+const (
+	// EventNotice is any other message from the server: command replies,
+	// welcome banners, announcements and errors.
+	EventNotice EventKind = iota
+	EventMessage
+	EventDirect
+	EventJoin
+	EventLeave
+	EventTopic
+	// EventFileReceived reports a file another user sent to this client. The
+	// server keeps the file in its downloads directory.
+	EventFileReceived
+	EventDisconnected
+	// EventReconnected follows a successful redial. Err is set when the
+	// session could not be fully restored.
+	EventReconnected
+	// EventEdit replaces the text of message ID in Room with Text.
+	EventEdit
+	// EventDelete removes message ID from Room.
+	EventDelete
+	// EventHistory is a stored message replayed in answer to a request such
+	// as /thread, not a new post.
+	EventHistory
+	// EventThreadReply tells the user that From replied to a thread they
+	// took part in while they were not in its room.
+	EventThreadReply
+	// EventReaction reports that From gave reaction Text to message ID.
+	// Reactions holds the message's counts after the change.
+	EventReaction
+	// EventUnreaction reports that From took back reaction Text.
+	EventUnreaction
+	// EventMention reports that From mentioned the user in message ID in
+	// Room, directly or with @room or @here. Mentions made while the user
+	// was offline arrive after login.
+	EventMention
+	// EventRead reports the user's read marker in Room, or in the direct
+	// conversation with From, after it moved on any of their connections
+	// or when unread messages are listed. Unread messages remain after
+	// message ID.
+	EventRead
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventNotice:
+		return "notice"
+	case EventMessage:
+		return "message"
+	case EventDirect:
+		return "direct"
+	case EventJoin:
+		return "join"
+	case EventLeave:
+		return "leave"
+	case EventTopic:
+		return "topic"
+	case EventFileReceived:
+		return "file_received"
+	case EventDisconnected:
+		return "disconnected"
+	case EventReconnected:
+		return "reconnected"
+	case EventEdit:
+		return "edit"
+	case EventDelete:
+		return "delete"
+	case EventHistory:
+		return "history"
+	case EventThreadReply:
+		return "thread_reply"
+	case EventReaction:
+		return "reaction"
+	case EventUnreaction:
+		return "unreaction"
+	case EventMention:
+		return "mention"
+	case EventRead:
+		return "read"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is one thing that happened on the connection. Message holds the raw
+// frame for events that came from the server. ID is the server's ID of the
+// room message an event is about, and Parent the ID of the message that
+// started its thread. Reactions counts the reactions on that message.
+type Event struct {
+	Kind      EventKind
+	Room      string
+	From      string
+	Text      string
+	File      string
+	ID        string
+	Parent    string
+	Reactions map[string]int
+	Unread    int
+	Err       error
+
+	Message shared.Message
+}
+
+func newEvent(msg shared.Message) Event {
+	event := Event{
+		Kind:      EventNotice,
+		Room:      msg.RoomName,
+		From:      msg.Sender,
+		Text:      msg.Content,
+		ID:        msg.ID,
+		Parent:    msg.ParentID,
+		Reactions: msg.Reactions,
+		Unread:    msg.Unread,
+		Message:   msg,
+	}
+
+	switch msg.Type {
+	case shared.EditMessage:
+		event.Kind = EventEdit
+		return event
+	case shared.DeleteMessage:
+		event.Kind = EventDelete
+		return event
+	case shared.HistoryMessage:
+		event.Kind = EventHistory
+		return event
+	case shared.MentionMessage:
+		event.Kind = EventMention
+		return event
+	case shared.ReadMessage:
+		event.Kind, event.From = EventRead, msg.Recipient
+		return event
+	case shared.ReactionMessage:
+		event.Kind = EventReaction
+		if text, removed := strings.CutPrefix(msg.Content, "-"); removed {
+			event.Kind, event.Text = EventUnreaction, text
+		} else {
+			event.Text = strings.TrimPrefix(msg.Content, "+")
+		}
+		return event
+	}
+
+	if msg.Sender != shared.ServerName {
+		switch {
+		case msg.RoomName != "":
+			event.Kind = EventMessage
+		case msg.Recipient != "":
+			event.Kind = EventDirect
+		}
+		return event
+	}
+
+	if msg.RoomName != "" {
+		if n := msg.Notice; n != nil {
+			switch n.Kind {
+			case shared.NoticeJoined:
+				event.Kind, event.From = EventJoin, n.User
+			case shared.NoticeLeft:
+				event.Kind, event.From = EventLeave, n.User
+			case shared.NoticeTopic:
+				event.Kind, event.From, event.Text = EventTopic, n.User, n.Topic
+			}
+		}
+		return event
+	}
+
+	if msg.ParentID != "" {
+		if v, ok := parseReply(msg.Content, shared.ReplyThreadActivity); ok && v[1] == msg.ParentID {
+			event.Kind = EventThreadReply
+			event.From, event.Room, event.Text = v[0], v[2], v[3]
+		}
+		return event
+	}
+
+	if v, ok := parseReply(msg.Content, shared.ReplyFileReceived); ok {
+		event.Kind = EventFileReceived
+		event.From, event.File = v[2], v[0]
+	}
+
+	return event
+}
@@ -0,0 +1,89 @@
+package chatclient
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// The server builds its replies from the formats in the shared package. A
+// format is recognised by turning it into a pattern in which each verb
+// captures the value formatted into it.
+var (
+	formatVerb    = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+	replyPatterns sync.Map
+)
+
+func replyPattern(format string) *regexp.Regexp {
+	if re, ok := replyPatterns.Load(format); ok {
+		return re.(*regexp.Regexp)
+	}
+
+	var expr strings.Builder
+	expr.WriteString(`(?s)^`)
+	last := 0
+	for _, loc := range formatVerb.FindAllStringIndex(format, -1) {
+		expr.WriteString(regexp.QuoteMeta(format[last:loc[0]]))
+		switch format[loc[1]-1] {
+		case '%':
+			expr.WriteString(`%`)
+		case 'd':
+			expr.WriteString(`\s*(-?\d+)\s*`)
+		default:
+			expr.WriteString(`(.*?)`)
+		}
+		last = loc[1]
+	}
+	expr.WriteString(regexp.QuoteMeta(format[last:]))
+	expr.WriteString(`$`)
+
+	re := regexp.MustCompile(expr.String())
+	replyPatterns.Store(format, re)
+	return re
+}
+
+// parseReply matches content against a reply format and returns the values
+// of its verbs without their padding.
+func parseReply(content, format string) ([]string, bool) {
+	m := replyPattern(format).FindStringSubmatch(content)
+	if m == nil {
+		return nil, false
+	}
+	values := m[1:]
+	for i := range values {
+		values[i] = strings.TrimSpace(values[i])
+	}
+	return values, true
+}
+
+// isReply reports whether content was built from any of formats.
+func isReply(content string, formats ...string) bool {
+	for _, format := range formats {
+		if replyPattern(format).MatchString(content) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchReply reports whether content was built from format with values as
+// its leading arguments.
+func matchReply(content, format string, values ...string) bool {
+	got, ok := parseReply(content, format)
+	if !ok || len(got) < len(values) {
+		return false
+	}
+	for i, value := range values {
+		if got[i] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// reply is matchReply as a success test for replyMatcher.
+func reply(format string, values ...string) func(string) bool {
+	return func(content string) bool {
+		return matchReply(content, format, values...)
+	}
+}
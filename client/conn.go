@@ -0,0 +1,51 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"sync"
+)
+
+// LineConn frames a stream connection as newline-delimited lines, the native
+// TCP protocol.
+type LineConn struct {
+	net.Conn
+	reader *bufio.Reader
+	mu     sync.Mutex
+}
+
+func NewLineConn(conn net.Conn) *LineConn {
+	return &LineConn{
+		Conn:   conn,
+		reader: bufio.NewReader(conn),
+	}
+}
+
+func (c *LineConn) ReadFrame(maxSize int) ([]byte, error) {
+	return ReadFrame(c.reader, maxSize)
+}
+
+func (c *LineConn) WriteFrame(frame []byte) error {
+	if !bytes.HasSuffix(frame, []byte("\n")) {
+		frame = append(frame[:len(frame):len(frame)], '\n')
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, err := c.Conn.Write(frame)
+	return err
+}
+
+// frameWriter lets code written against io.Writer send whole frames.
+type frameWriter struct {
+	conn interface{ WriteFrame([]byte) error }
+}
+
+func (w frameWriter) Write(p []byte) (int, error) {
+	if err := w.conn.WriteFrame(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
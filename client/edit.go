@@ -0,0 +1,92 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/imaneimrh/TCP-Chat_Server/audit"
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
+)
+
+// handleEdit replaces the text of one of the caller's messages. line is the
+// raw command so that the new text keeps its spacing.
+func (h *Handler) handleEdit(client *shared.Client, command []string, line string) {
+	if client.Username == "" {
+		client.Send <- serverNotice(fmt.Sprintf(shared.ReplyLoginRequiredFor, "/edit"))
+		return
+	}
+
+	text := commandText(line, 2)
+	if len(command) < 3 || text == "" {
+		client.Send <- usageNotice("/edit <id> <text>")
+		return
+	}
+	if len(text) > h.Protocol.MaxContentSize {
+		client.Send <- serverNotice("Message is too long")
+		return
+	}
+
+	r, _, exists := h.RoomManager.FindPost(command[1])
+	if !exists {
+		client.Send <- serverNotice(fmt.Sprintf(shared.ReplyEditFailed, noSuchMessage(command[1])))
+		return
+	}
+	if !client.IsInRoom(r.Name) {
+		client.Send <- serverNotice(fmt.Sprintf(shared.ReplyNotInRoom, r.Name, r.Name))
+		return
+	}
+
+	if err := r.Edit(command[1], client.Username, text); err != nil {
+		client.Send <- serverNotice(fmt.Sprintf(shared.ReplyEditFailed, err))
+		return
+	}
+	client.Log.Info("Message edited", "room", r.Name, "message", command[1])
+}
+
+// handleDelete removes a message, leaving a tombstone in the history.
+// Authors may delete their own messages and moderators any message.
+func (h *Handler) handleDelete(client *shared.Client, command []string) {
+	if client.Username == "" {
+		client.Send <- serverNotice(fmt.Sprintf(shared.ReplyLoginRequiredFor, "/delete"))
+		return
+	}
+
+	if len(command) != 2 {
+		client.Send <- usageNotice("/delete <id>")
+		return
+	}
+
+	r, _, exists := h.RoomManager.FindPost(command[1])
+	if !exists {
+		client.Send <- serverNotice(fmt.Sprintf(shared.ReplyDeleteFailed, noSuchMessage(command[1])))
+		return
+	}
+
+	moderator := h.AuthManager.IsModerator(client.Username)
+	author, err := r.Delete(command[1], client.Username, moderator)
+	if err != nil {
+		client.Send <- serverNotice(fmt.Sprintf(shared.ReplyDeleteFailed, err))
+		return
+	}
+
+	client.Log.Info("Message deleted", "room", r.Name, "message", command[1], "author", author)
+	if !client.IsInRoom(r.Name) {
+		client.Send <- serverNotice(fmt.Sprintf(shared.ReplyDeleted, command[1], r.Name))
+	}
+	if author != client.Username {
+		h.Audit.Record(audit.EventMessageDelete, client.Username, author, remoteHost(client.Conn), r.Name+" #"+command[1])
+	}
+}
+
+// commandText returns what follows the first n words of a command line.
+func commandText(line string, n int) string {
+	rest := strings.TrimSpace(line)
+	for i := 0; i < n; i++ {
+		end := strings.IndexAny(rest, " \t")
+		if end < 0 {
+			return ""
+		}
+		rest = strings.TrimSpace(rest[end:])
+	}
+	return rest
+}
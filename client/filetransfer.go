@@ -32,6 +32,10 @@ type FileTransferInfo struct {
 	IsComplete bool
 }
 
+func TransferID(sender, recipient, fileName string) string {
+	return fmt.Sprintf("%s-%s-%s", sender, recipient, fileName)
+}
+
 func NewFileTransfer() *FileTransfer {
 	return &FileTransfer{
 		pendingTransfers: make(map[string]*FileTransferInfo),
@@ -53,7 +57,7 @@ func (ft *FileTransfer) InitiateTransfer(sender, recipient, filePath string) (*s
 
 	fileName := filepath.Base(filePath)
 
-	transferID := fmt.Sprintf("%s-%s-%s", sender, recipient, fileName)
+	transferID := TransferID(sender, recipient, fileName)
 
 	ft.mu.Lock()
 	transfer := &FileTransferInfo{
@@ -183,6 +187,7 @@ func (ft *FileTransfer) ReceiveChunk(msg shared.Message) error {
 	}
 
 	transfer.BytesRead += int64(len(msg.FileData))
+	fileTransferBytes.Add(float64(len(msg.FileData)))
 
 	if msg.Type == shared.FileTransferComplete {
 		transfer.File.Close()
@@ -212,3 +217,48 @@ func (ft *FileTransfer) GetTransferProgress(sender, fileName string) (int, error
 
 	return int((transfer.BytesRead * 100) / transfer.FileSize), nil
 }
+
+func (ft *FileTransfer) ActiveCount() int {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+
+	count := 0
+	for _, transfers := range ft.activeTransfers {
+		for _, transfer := range transfers {
+			if !transfer.IsComplete {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+type TransferStatus struct {
+	Sender    string `json:"sender"`
+	Recipient string `json:"recipient"`
+	FileName  string `json:"file_name"`
+	FileSize  int64  `json:"file_size"`
+	BytesRead int64  `json:"bytes_received"`
+}
+
+func (ft *FileTransfer) Transfers() []TransferStatus {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+
+	transfers := []TransferStatus{}
+	for _, senderTransfers := range ft.activeTransfers {
+		for _, transfer := range senderTransfers {
+			if transfer.IsComplete {
+				continue
+			}
+			transfers = append(transfers, TransferStatus{
+				Sender:    transfer.Sender,
+				Recipient: transfer.Recipient,
+				FileName:  transfer.FileName,
+				FileSize:  transfer.FileSize,
+				BytesRead: transfer.BytesRead,
+			})
+		}
+	}
+	return transfers
+}
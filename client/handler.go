@@ -1,47 +1,85 @@
 package client
 
 import (
-	"bufio"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/imaneimrh/TCP-Chat_Server/access"
+	"github.com/imaneimrh/TCP-Chat_Server/audit"
 	"github.com/imaneimrh/TCP-Chat_Server/auth"
+	"github.com/imaneimrh/TCP-Chat_Server/logging"
+	"github.com/imaneimrh/TCP-Chat_Server/plugin"
 	"github.com/imaneimrh/TCP-Chat_Server/room"
+	"github.com/imaneimrh/TCP-Chat_Server/search"
 	"github.com/imaneimrh/TCP-Chat_Server/shared"
 )
 
 type Handler struct {
-	Clients      map[string]*shared.Client
-	RoomManager  *room.Manager
-	FileTransfer *FileTransfer
-	AuthManager  *auth.Manager
-	Register     chan *shared.Client
-	Unregister   chan *shared.Client
-	Broadcast    chan shared.Message
-	DirectMsg    chan shared.Message
-	mu           sync.RWMutex
+	Clients       map[string]*shared.Client
+	RoomManager   *room.Manager
+	FileTransfer  *FileTransfer
+	AuthManager   *auth.Manager
+	Authenticator auth.Authenticator
+	Access        *access.Control
+	Audit         *audit.Log
+	Plugins       *plugin.Manager
+	Search        *search.Index
+	Register      chan *shared.Client
+	Unregister    chan *shared.Client
+	Broadcast     chan shared.Message
+	DirectMsg     chan shared.Message
+	Limits        RateLimits
+	Protocol      ProtocolLimits
+	Timeouts      Timeouts
+	Mentions      MentionLimits
+	// OpenRegistration allows /register. It is off when logins are also
+	// checked against providers other than the local account store.
+	OpenRegistration bool
+	limiters         userLimiters
+	mentions         *mentionQueue
+	markers          *readMarkers
+	probe            chan chan struct{}
+	running          atomic.Bool
+	mu               sync.RWMutex
 }
 
 func NewHandler(roomManager *room.Manager, authManager *auth.Manager) *Handler {
-	return &Handler{
-		Clients:      make(map[string]*shared.Client),
-		RoomManager:  roomManager,
-		FileTransfer: NewFileTransfer(),
-		AuthManager:  authManager,
-		Register:     make(chan *shared.Client),
-		Unregister:   make(chan *shared.Client),
-		Broadcast:    make(chan shared.Message),
-		DirectMsg:    make(chan shared.Message),
+	h := &Handler{
+		Clients:          make(map[string]*shared.Client),
+		RoomManager:      roomManager,
+		FileTransfer:     NewFileTransfer(),
+		AuthManager:      authManager,
+		Authenticator:    authManager,
+		Register:         make(chan *shared.Client),
+		Unregister:       make(chan *shared.Client),
+		Broadcast:        make(chan shared.Message),
+		DirectMsg:        make(chan shared.Message),
+		Limits:           DefaultRateLimits(),
+		Protocol:         DefaultProtocolLimits(),
+		Timeouts:         DefaultTimeouts(),
+		Mentions:         DefaultMentionLimits(),
+		OpenRegistration: true,
+		limiters:         userLimiters{sets: make(map[string]*limiterSet)},
+		mentions:         newMentionQueue(),
+		markers:          newReadMarkers(),
+		probe:            make(chan chan struct{}),
 	}
+
+	roomManager.AddObserver(h)
+	return h
 }
 
 func (h *Handler) Run() {
+	h.running.Store(true)
+	defer h.running.Store(false)
+
 	for {
 		select {
 		case client := <-h.Register:
@@ -52,48 +90,92 @@ func (h *Handler) Run() {
 			h.broadcastMessage(message)
 		case message := <-h.DirectMsg:
 			h.sendDirectMessage(message)
+		case done := <-h.probe:
+			close(done)
 		}
 	}
 }
 
+func (h *Handler) Running() bool {
+	return h.running.Load()
+}
+
+// Probe checks that Run is still draining its channels by asking it to
+// acknowledge a request within timeout.
+func (h *Handler) Probe(timeout time.Duration) error {
+	done := make(chan struct{})
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case h.probe <- done:
+	case <-timer.C:
+		return fmt.Errorf("client handler is not responding")
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("client handler is not responding")
+	}
+}
+
 func (h *Handler) registerClient(client *shared.Client) {
 	h.mu.Lock()
-	defer h.mu.Unlock()
-
 	h.Clients[client.Username] = client
 
-	if client.Username != "" {
-		h.RoomManager.JoinRoom("general", client)
+	if client.Username == "" {
+		h.mu.Unlock()
+		return
+	}
 
-		welcomeMsg := shared.Message{
-			Type:    shared.TextMessage,
-			Sender:  "Server",
-			Content: fmt.Sprintf("Welcome to the chat server, %s! You've been added to the 'general' room.", client.Username),
-		}
+	h.RoomManager.JoinRoom("general", client)
+	h.startReading(client.Username, "general")
+	h.Plugins.LoggedIn(client.Username)
+	h.Plugins.Joined("general", client.Username)
 
-		client.Send <- welcomeMsg
+	welcomeMsg := shared.Message{
+		Type:    shared.TextMessage,
+		Sender:  "Server",
+		Content: fmt.Sprintf("Welcome to the chat server, %s! You've been added to the 'general' room.", client.Username),
+	}
 
-		joinMsg := shared.Message{
-			Type:     shared.TextMessage,
-			Sender:   "Server",
-			RoomName: "general",
-			Content:  fmt.Sprintf("%s has joined the server.", client.Username),
-		}
+	frames := append([]shared.Message{welcomeMsg}, h.pendingMentions(client.Username)...)
+	frames = append(frames, h.unreadFrames(client.Username, true)...)
+	h.mu.Unlock()
 
-		h.RoomManager.BroadcastToRoom("general", joinMsg)
+	// The frames are sent without h.mu so that a client that is slow to
+	// read cannot hold up the room goroutines that wait for it.
+	for _, frame := range frames {
+		client.Send <- frame
 	}
+
+	joinMsg := shared.Message{
+		Type:     shared.TextMessage,
+		Sender:   "Server",
+		RoomName: "general",
+		Content:  fmt.Sprintf("%s has joined the server.", client.Username),
+	}
+
+	h.RoomManager.BroadcastToRoom("general", joinMsg)
 }
 
 func (h *Handler) unregisterClient(client *shared.Client) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	if _, ok := h.Clients[client.Username]; ok {
-		for roomName := range client.Rooms {
+	if key, ok := h.clientKey(client); ok {
+		for _, roomName := range client.RoomList() {
 			h.RoomManager.LeaveRoom(roomName, client)
+			if client.Username != "" {
+				h.Plugins.Left(roomName, client.Username)
+			}
 		}
 
 		if client.Username != "" {
+			h.Plugins.LoggedOut(client.Username)
+
 			leaveMsg := shared.Message{
 				Type:    shared.TextMessage,
 				Sender:  "Server",
@@ -103,17 +185,49 @@ func (h *Handler) unregisterClient(client *shared.Client) {
 			h.RoomManager.BroadcastToRoom("general", leaveMsg)
 		}
 
-		delete(h.Clients, client.Username)
+		delete(h.Clients, key)
 		close(client.Send)
 	}
 }
 
+// clientKey finds the key a client is stored under. Clients that have not
+// logged in yet are stored under a temporary ID rather than their username.
+func (h *Handler) clientKey(client *shared.Client) (string, bool) {
+	if c, ok := h.Clients[client.Username]; ok && c == client {
+		return client.Username, true
+	}
+
+	for id, c := range h.Clients {
+		if c == client {
+			return id, true
+		}
+	}
+	return "", false
+}
+
 func (h *Handler) broadcastMessage(message shared.Message) {
 	if message.RoomName == "" {
 		message.RoomName = "general"
 	}
 
-	h.RoomManager.BroadcastToRoom(message.RoomName, message)
+	if err := h.checkMentions(message); err != nil {
+		h.mu.RLock()
+		sender, exists := h.Clients[message.Sender]
+		h.mu.RUnlock()
+
+		if exists {
+			sender.Send <- serverNotice(fmt.Sprintf(shared.ReplyNotSent, err))
+		}
+		return
+	}
+
+	if h.RoomManager.BroadcastToRoom(message.RoomName, message) == nil {
+		h.Plugins.Message(plugin.Message{
+			Room: message.RoomName,
+			From: message.Sender,
+			Text: message.Content,
+		})
+	}
 }
 
 func (h *Handler) sendDirectMessage(message shared.Message) {
@@ -130,7 +244,7 @@ func (h *Handler) sendDirectMessage(message shared.Message) {
 			errorMsg := shared.Message{
 				Type:    shared.TextMessage,
 				Sender:  "Server",
-				Content: fmt.Sprintf("User %s is not online.", message.Recipient),
+				Content: fmt.Sprintf(shared.ReplyNotOnline, message.Recipient),
 			}
 			sender.Send <- errorMsg
 		}
@@ -142,9 +256,22 @@ func (h *Handler) sendDirectMessage(message shared.Message) {
 		Sender:    message.Sender,
 		Recipient: message.Recipient,
 		Content:   message.Content,
+		ID:        h.RoomManager.NewID(),
 	}
 	recipient.Send <- directMsg
 
+	id := messageNumber(directMsg.ID)
+	h.markers.received(directMsg.Recipient, "@"+directMsg.Sender, id)
+	h.markers.advance(directMsg.Sender, "@"+directMsg.Recipient, id)
+
+	h.Search.Add(search.Document{
+		ID:   directMsg.ID,
+		From: directMsg.Sender,
+		To:   directMsg.Recipient,
+		Text: directMsg.Content,
+		Time: time.Now(),
+	})
+
 	serverMsgToRecipient := shared.Message{
 		Type:      shared.TextMessage,
 		Sender:    "Server",
@@ -159,15 +286,16 @@ func (h *Handler) sendDirectMessage(message shared.Message) {
 
 	if senderExists {
 		confirmMsg := shared.Message{
-			Type:    shared.TextMessage,
-			Sender:  "Server",
-			Content: fmt.Sprintf("(To %s): %s", message.Recipient, message.Content),
+			Type:      shared.TextMessage,
+			Sender:    "Server",
+			Recipient: message.Recipient,
+			Content:   fmt.Sprintf(shared.ReplyDirectSent, message.Recipient, message.Content),
 		}
 		sender.Send <- confirmMsg
 	}
 }
 
-func (h *Handler) getOnlineUsers() []string {
+func (h *Handler) OnlineUsers() []string {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
@@ -180,13 +308,140 @@ func (h *Handler) getOnlineUsers() []string {
 	return users
 }
 
+type ClientInfo struct {
+	Username   string   `json:"username"`
+	RemoteAddr string   `json:"remote_addr"`
+	Rooms      []string `json:"rooms"`
+}
+
+func (h *Handler) OnlineClients() []ClientInfo {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	clients := []ClientInfo{}
+	for _, client := range h.Clients {
+		if client.Username == "" {
+			continue
+		}
+
+		rooms := client.RoomList()
+		sort.Strings(rooms)
+
+		clients = append(clients, ClientInfo{
+			Username:   client.Username,
+			RemoteAddr: client.Conn.RemoteAddr().String(),
+			Rooms:      rooms,
+		})
+	}
+
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].Username < clients[j].Username
+	})
+	return clients
+}
+
+// Announce posts a server message to one room, or to every room when
+// roomName is empty.
+func (h *Handler) Announce(roomName, text string) error {
+	rooms := []string{roomName}
+	if roomName == "" {
+		rooms = h.RoomManager.ListRooms()
+	}
+
+	for _, name := range rooms {
+		err := h.RoomManager.BroadcastToRoom(name, shared.Message{
+			Type:     shared.TextMessage,
+			Sender:   "Server",
+			RoomName: name,
+			Content:  "[Announcement] " + text,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleTopic shows a room's topic, or sets it when text follows the room
+// name. Only members of the room may change it.
+func (h *Handler) handleTopic(client *shared.Client, command []string) {
+	if client.Username == "" {
+		client.Send <- serverNotice(fmt.Sprintf(shared.ReplyLoginRequiredFor, "/topic"))
+		return
+	}
+
+	if len(command) < 2 {
+		client.Send <- usageNotice("/topic <room> [text]")
+		return
+	}
+
+	r, exists := h.RoomManager.GetRoom(command[1])
+	if !exists {
+		client.Send <- serverNotice(fmt.Sprintf("Room %s does not exist", command[1]))
+		return
+	}
+
+	if len(command) == 2 {
+		if topic := r.Topic(); topic != "" {
+			client.Send <- serverNotice(fmt.Sprintf("Topic for %s: %s", r.Name, topic))
+		} else {
+			client.Send <- serverNotice(fmt.Sprintf("No topic is set for %s", r.Name))
+		}
+		return
+	}
+
+	if !client.IsInRoom(r.Name) {
+		client.Send <- serverNotice(fmt.Sprintf("You must join %s to change its topic", r.Name))
+		return
+	}
+
+	topic := strings.Join(command[2:], " ")
+	if len(topic) > h.Protocol.MaxContentSize {
+		client.Send <- serverNotice("Topic is too long")
+		return
+	}
+
+	r.SetTopic(topic)
+	client.Log.Info("Topic changed", "room", r.Name)
+	h.RoomManager.BroadcastToRoom(r.Name, room.TopicNotice(r.Name, client.Username, topic))
+}
+
+var tempIDs atomic.Uint64
+
+// newTempID names a client that has not logged in yet. Unix socket peers all
+// share an empty address, so a counter keeps the IDs unique.
+func newTempID(conn shared.MessageConn) string {
+	return fmt.Sprintf("%s-%d", conn.RemoteAddr(), tempIDs.Add(1))
+}
+
+func notifyLoginFailed(conn shared.MessageConn, reason string) {
+	if observer, ok := conn.(shared.LoginObserver); ok {
+		observer.LoginFailed(reason)
+	}
+}
+
 func (h *Handler) HandleClient(conn net.Conn) {
+	h.ServeConn(NewLineConn(conn))
+}
+
+// ServeConn runs a client session over any framed transport. It returns once
+// the session's goroutines have started.
+func (h *Handler) ServeConn(conn shared.MessageConn) {
 	client := shared.NewClient(conn)
-	tempID := conn.RemoteAddr().String()
+	tempID := newTempID(conn)
 
-	h.mu.Lock()
-	h.Clients[tempID] = client
-	h.mu.Unlock()
+	if peer, ok := conn.(shared.PeerIdentifier); ok {
+		if err := h.peerLogin(client, peer.PeerUsername()); err != nil {
+			client.Log.Warn("Peer login refused", "user", peer.PeerUsername(), "error", err)
+			writeDirect(conn, fmt.Sprintf("Login failed: %v. Disconnecting.", err))
+			conn.Close()
+			return
+		}
+	} else {
+		h.mu.Lock()
+		h.Clients[tempID] = client
+		h.mu.Unlock()
+	}
 
 	welcomeMsg := shared.Message{
 		Type:   shared.TextMessage,
@@ -202,26 +457,90 @@ func (h *Handler) HandleClient(conn net.Conn) {
 			"╚══════════════════════════════════════════════╝",
 	}
 
-	client.Send <- welcomeMsg
+	if client.Username == "" {
+		client.Send <- welcomeMsg
+	}
+
+	connLimiter := newLimiterSet(h.Limits)
+	flood := &floodControl{}
 
-	reader := bufio.NewReader(conn)
+	var missedPongs atomic.Int32
+	lastActivity := time.Now()
+
+	var loginTimer *time.Timer
+	if h.Timeouts.LoginTimeout > 0 && client.Username == "" {
+		loginTimer = time.AfterFunc(h.Timeouts.LoginTimeout, func() {
+			client.Log.Info("Login timed out, disconnecting", "timeout", h.Timeouts.LoginTimeout)
+			writeDirect(conn, "Login timed out. Disconnecting.")
+			conn.Close()
+		})
+	}
 
 	go func() {
 		defer func() {
+			if loginTimer != nil {
+				loginTimer.Stop()
+			}
+			if client.Username != "" {
+				h.Audit.Record(audit.EventLogout, client.Username, client.Username, remoteHost(conn), "connection closed")
+			}
 			h.Unregister <- client
 			conn.Close()
 		}()
 
+		if client.Username != "" {
+			h.Register <- client
+		}
+
 		for {
-			line, err := reader.ReadBytes('\n')
+			conn.SetReadDeadline(h.Timeouts.readDeadline(lastActivity))
+
+			line, err := conn.ReadFrame(h.Protocol.MaxFrameSize)
 			if err != nil {
-				if err != io.EOF {
-					log.Printf("Error reading from client %s: %v", client.Username, err)
+				if err == ErrFrameTooLarge {
+					client.Log.Warn("Oversized frame, disconnecting", "max_frame", h.Protocol.MaxFrameSize)
+					writeDirect(conn, fmt.Sprintf(shared.ReplyProtocolError, fmt.Sprintf("line exceeds %d bytes", h.Protocol.MaxFrameSize)))
+				} else if isTimeout(err) {
+					client.Log.Info("Read timed out, disconnecting")
+					writeDirect(conn, "Connection timed out. Disconnecting.")
+				} else if err != io.EOF {
+					client.Log.Warn("Error reading from client", "error", err)
 				}
 				break
 			}
 
 			line = []byte(strings.TrimSpace(string(line)))
+			messagesReceived.With(frameLabel(line)).Inc()
+
+			if len(line) > 0 && line[0] == '/' {
+				client.Log.Debug("Command received", "command", logging.RedactCommand(string(line)))
+			}
+
+			if msgType, ok := frameType(line); ok {
+				switch msgType {
+				case shared.PongMessage:
+					missedPongs.Store(0)
+					continue
+				case shared.PingMessage:
+					client.Send <- shared.Message{Type: shared.PongMessage, Sender: "Server"}
+					continue
+				}
+			}
+
+			lastActivity = time.Now()
+
+			switch h.checkRateLimits(client, connLimiter, flood, line) {
+			case rateDrop:
+				continue
+			case rateDisconnect:
+				client.Log.Warn("Disconnecting client for flooding")
+				writeDirect(conn, shared.ReplyFlooding)
+				return
+			}
+
+			if client.Username != "" && len(line) > 0 && line[0] == '/' {
+				h.Plugins.CommandRun(client.Username, logging.RedactCommand(string(line)))
+			}
 
 			if len(line) > 0 && line[0] == '/' {
 				command := strings.Fields(string(line))
@@ -229,11 +548,20 @@ func (h *Handler) HandleClient(conn net.Conn) {
 				if len(command) > 0 {
 					switch command[0] {
 					case "/register":
+						if !h.OpenRegistration {
+							client.Send <- shared.Message{
+								Type:    shared.TextMessage,
+								Sender:  "Server",
+								Content: shared.ReplyRegistrationClosed,
+							}
+							continue
+						}
+
 						if len(command) < 3 {
 							client.Send <- shared.Message{
 								Type:    shared.TextMessage,
 								Sender:  "Server",
-								Content: "Usage: /register <username> <password>",
+								Content: fmt.Sprintf(shared.ReplyUsage, "/register <username> <password>"),
 							}
 							continue
 						}
@@ -245,7 +573,7 @@ func (h *Handler) HandleClient(conn net.Conn) {
 							client.Send <- shared.Message{
 								Type:    shared.TextMessage,
 								Sender:  "Server",
-								Content: "Username must be at least 3 characters long",
+								Content: shared.ReplyUsernameTooShort,
 							}
 							continue
 						}
@@ -254,31 +582,25 @@ func (h *Handler) HandleClient(conn net.Conn) {
 							client.Send <- shared.Message{
 								Type:    shared.TextMessage,
 								Sender:  "Server",
-								Content: "Password must be at least 4 characters long",
+								Content: shared.ReplyPasswordTooShort,
 							}
 							continue
 						}
 
 						err := h.AuthManager.Register(username, password)
 						if err != nil {
+							h.Audit.Record(audit.EventRegister, username, username, remoteHost(conn), "failed: "+err.Error())
 							client.Send <- shared.Message{
 								Type:    shared.TextMessage,
 								Sender:  "Server",
-								Content: fmt.Sprintf("Registration failed: %v", err),
+								Content: fmt.Sprintf(shared.ReplyRegisterFailed, err),
 							}
 						} else {
+							h.Audit.Record(audit.EventRegister, username, username, remoteHost(conn), "")
 							client.Send <- shared.Message{
-								Type:   shared.TextMessage,
-								Sender: "Server",
-								Content: fmt.Sprintf("╔═════════════════════════════════════════╗\n"+
-									"║ Registration Successful!                 ║\n"+
-									"╠═════════════════════════════════════════╣\n"+
-									"║ Username: %-30s ║\n"+
-									"║                                         ║\n"+
-									"║ You can now login with:                 ║\n"+
-									"║ /login %s <password>                   ║\n"+
-									"╚═════════════════════════════════════════╝",
-									username, username),
+								Type:    shared.TextMessage,
+								Sender:  "Server",
+								Content: fmt.Sprintf(shared.ReplyRegistered, username, username),
 							}
 						}
 						continue
@@ -288,7 +610,7 @@ func (h *Handler) HandleClient(conn net.Conn) {
 							client.Send <- shared.Message{
 								Type:    shared.TextMessage,
 								Sender:  "Server",
-								Content: "Usage: /login <username> <password>",
+								Content: fmt.Sprintf(shared.ReplyUsage, "/login <username> <password>"),
 							}
 							continue
 						}
@@ -296,31 +618,55 @@ func (h *Handler) HandleClient(conn net.Conn) {
 						username := command[1]
 						password := command[2]
 
-						err := h.AuthManager.Authenticate(username, password)
+						err := h.Authenticator.Authenticate(username, password)
 						if err != nil {
+							authFailures.With("invalid_credentials").Inc()
+							client.Log.Warn("Login failed", "user", username, "error", err)
+							h.Audit.Record(audit.EventLoginFailure, username, username, remoteHost(conn), err.Error())
+							notifyLoginFailed(conn, fmt.Sprintf(shared.ReplyLoginFailed, err))
 							client.Send <- shared.Message{
 								Type:    shared.TextMessage,
 								Sender:  "Server",
-								Content: fmt.Sprintf("Login failed: %v", err),
+								Content: fmt.Sprintf(shared.ReplyLoginFailed, err),
 							}
 							continue
 						}
 
-						isLoggedIn := false
-						h.mu.RLock()
-						for _, c := range h.Clients {
-							if c.Username == username && c != client {
-								isLoggedIn = true
-								break
+						// Other providers do not know that the name is reserved.
+						if h.AuthManager.Reserved(username) {
+							authFailures.With("reserved").Inc()
+							client.Log.Warn("Login refused for reserved name", "user", username)
+							h.Audit.Record(audit.EventLoginFailure, username, username, remoteHost(conn), "reserved name")
+							notifyLoginFailed(conn, fmt.Sprintf(shared.ReplyLoginFailed, fmt.Errorf("username '%s' is reserved", username)))
+							client.Send <- shared.Message{
+								Type:    shared.TextMessage,
+								Sender:  "Server",
+								Content: fmt.Sprintf(shared.ReplyLoginFailed, fmt.Errorf("username '%s' is reserved", username)),
+							}
+							continue
+						}
+
+						if h.Access != nil {
+							if err := h.Access.CheckUser(username); err != nil {
+								authFailures.With("banned").Inc()
+								client.Log.Warn("Login refused for banned user", "user", username)
+								h.Audit.Record(audit.EventLoginFailure, username, username, remoteHost(conn), err.Error())
+								notifyLoginFailed(conn, fmt.Sprintf(shared.ReplyLoginFailed, fmt.Errorf("you are %v", err)))
+								client.Send <- shared.Message{
+									Type:    shared.TextMessage,
+									Sender:  "Server",
+									Content: fmt.Sprintf(shared.ReplyLoginFailed, fmt.Errorf("you are %v", err)),
+								}
+								continue
 							}
 						}
-						h.mu.RUnlock()
 
-						if isLoggedIn {
+						if h.isLoggedIn(username, client) {
+							notifyLoginFailed(conn, fmt.Sprintf(shared.ReplyAlreadyLoggedIn, username))
 							client.Send <- shared.Message{
 								Type:    shared.TextMessage,
 								Sender:  "Server",
-								Content: fmt.Sprintf("User '%s' is already logged in", username),
+								Content: fmt.Sprintf(shared.ReplyAlreadyLoggedIn, username),
 							}
 							continue
 						}
@@ -329,22 +675,25 @@ func (h *Handler) HandleClient(conn net.Conn) {
 						delete(h.Clients, tempID)
 						h.mu.Unlock()
 
+						if loginTimer != nil {
+							loginTimer.Stop()
+						}
+
 						client.Username = username
+						client.Log = client.Log.With("user", username)
+						client.Log.Info("User logged in")
+						h.Audit.Record(audit.EventLogin, username, username, remoteHost(conn), "")
+
+						if observer, ok := conn.(shared.LoginObserver); ok {
+							observer.LoginSucceeded(username)
+						}
 
 						h.Register <- client
 
 						client.Send <- shared.Message{
-							Type:   shared.TextMessage,
-							Sender: "Server",
-							Content: fmt.Sprintf("╔═════════════════════════════════════════╗\n"+
-								"║           Login Successful!               ║\n"+
-								"╠═════════════════════════════════════════╣\n"+
-								"║ Welcome back, %-27s ║\n"+
-								"║                                         ║\n"+
-								"║ You've been added to the 'general' room  ║\n"+
-								"║ Type /help to see available commands     ║\n"+
-								"╚═════════════════════════════════════════╝",
-								username),
+							Type:    shared.TextMessage,
+							Sender:  "Server",
+							Content: fmt.Sprintf(shared.ReplyLoggedIn, username),
 						}
 						continue
 
@@ -359,16 +708,22 @@ func (h *Handler) HandleClient(conn net.Conn) {
 						}
 
 						oldUsername := client.Username
+						client.Log.Info("User logged out")
+						h.Audit.Record(audit.EventLogout, oldUsername, oldUsername, remoteHost(conn), "")
 
 						h.Unregister <- client
 
 						client = shared.NewClient(conn)
-						tempID = conn.RemoteAddr().String() + "-" + fmt.Sprintf("%d", time.Now().UnixNano())
+						tempID = newTempID(conn)
 
 						h.mu.Lock()
 						h.Clients[tempID] = client
 						h.mu.Unlock()
 
+						if loginTimer != nil {
+							loginTimer.Reset(h.Timeouts.LoginTimeout)
+						}
+
 						client.Send <- shared.Message{
 							Type:    shared.TextMessage,
 							Sender:  "Server",
@@ -402,7 +757,7 @@ func (h *Handler) HandleClient(conn net.Conn) {
 							continue
 						}
 
-						users := h.getOnlineUsers()
+						users := h.OnlineUsers()
 						if len(users) == 0 {
 							client.Send <- shared.Message{
 								Type:    shared.TextMessage,
@@ -434,6 +789,46 @@ func (h *Handler) HandleClient(conn net.Conn) {
 						}
 						continue
 
+					case "/topic":
+						h.handleTopic(client, command)
+						continue
+
+					case "/edit":
+						h.handleEdit(client, command, string(line))
+						continue
+
+					case "/delete":
+						h.handleDelete(client, command)
+						continue
+
+					case "/reply":
+						h.handleReply(client, command, string(line))
+						continue
+
+					case "/thread":
+						h.handleThread(client, command)
+						continue
+
+					case "/react", "/unreact":
+						h.handleReaction(client, command)
+						continue
+
+					case "/search":
+						h.handleSearch(client, string(line))
+						continue
+
+					case "/read":
+						h.handleRead(client, command)
+						continue
+
+					case "/unread":
+						h.handleUnread(client)
+						continue
+
+					case "/slowmode", "/kick", "/ban", "/unban", "/banip", "/unbanip", "/bans", "/reloadacl", "/loglevel", "/role":
+						h.handleModerationCommand(client, command)
+						continue
+
 					case "/help":
 						helpMsg := "╔══════════════════════════════════════════════════════════════╗\n" +
 							"║                    Available Commands                         ║\n" +
@@ -449,15 +844,37 @@ func (h *Handler) HandleClient(conn net.Conn) {
 							"║   /leave <room>                   - Leave a chat room         ║\n" +
 							"║   /create <room>                  - Create a new room         ║\n" +
 							"║   /list                           - List available rooms      ║\n" +
+							"║   /topic <room> [text]            - Show or set a room topic  ║\n" +
 							"║                                                              ║\n" +
 							"║ Messaging:                                                   ║\n" +
 							"║   /msg <username> <message>       - Send a direct message     ║\n" +
 							"║   /room <roomname> <message>      - Send to specific room     ║\n" +
 							"║   /users                          - Show online users         ║\n" +
+							"║   /edit <id> <text>               - Edit one of your messages ║\n" +
+							"║   /delete <id>                    - Delete a message          ║\n" +
+							"║   /reply <id> <text>              - Reply in a thread         ║\n" +
+							"║   /thread <id>                    - Show a whole thread       ║\n" +
+							"║   /react <id> <emoji>             - React to a message        ║\n" +
+							"║   /unreact <id> <emoji>           - Take back a reaction      ║\n" +
+							"║   /search <words> [filters]       - Search message history    ║\n" +
+							"║   /unread                         - List unread conversations ║\n" +
+							"║   /read [room|@user] [id]         - Mark messages read        ║\n" +
 							"║                                                              ║\n" +
 							"║ File Transfer:                                               ║\n" +
 							"║   /file <username> <filepath>     - Send a file to a user     ║\n" +
 							"║                                                              ║\n" +
+							"║ Moderation:                                                  ║\n" +
+							"║   /slowmode <room> <seconds>      - Limit posting rate        ║\n" +
+							"║   /kick <username> [reason]       - Disconnect a user         ║\n" +
+							"║   /ban <username> [dur] [reason]  - Ban a user (admin)        ║\n" +
+							"║   /unban <username>               - Lift a user ban (admin)   ║\n" +
+							"║   /banip <ip|cidr> [dur] [reason] - Ban an address (admin)    ║\n" +
+							"║   /unbanip <ip|cidr>              - Lift an address ban       ║\n" +
+							"║   /bans                           - List active bans (admin)  ║\n" +
+							"║   /reloadacl                      - Reload the access list    ║\n" +
+							"║   /loglevel [level]               - Show or set log level     ║\n" +
+							"║   /role <user> <role>             - Set a user's role         ║\n" +
+							"║                                                              ║\n" +
 							"║ Other:                                                       ║\n" +
 							"║   /help                           - Show this help message    ║\n" +
 							"║   /quit                           - Exit the chat client      ║\n" +
@@ -468,6 +885,9 @@ func (h *Handler) HandleClient(conn net.Conn) {
 							Sender:  "Server",
 							Content: helpMsg,
 						}
+						if pluginHelp := h.pluginHelp(); pluginHelp != "" {
+							client.Send <- serverNotice(pluginHelp)
+						}
 						continue
 					}
 				}
@@ -483,18 +903,28 @@ func (h *Handler) HandleClient(conn net.Conn) {
 				}
 			}
 
+			if err := ValidateMessage(msg, h.Protocol); err != nil {
+				client.Log.Warn("Protocol violation, disconnecting", "error", err)
+				writeDirect(conn, fmt.Sprintf(shared.ReplyProtocolError, err.(*ProtocolError).Reason))
+				return
+			}
+
 			msg.Sender = client.Username
 
 			if client.Username == "" {
 				client.Send <- shared.Message{
 					Type:    shared.TextMessage,
 					Sender:  "Server",
-					Content: "You must login first. Use /login <username> <password> or register with /register <username> <password>",
+					Content: shared.ReplyLoginRequired,
 				}
 				continue
 			}
 
 			if IsCommand(msg.Content) {
+				if len(line) > 0 && line[0] != '/' {
+					h.Plugins.CommandRun(client.Username, logging.RedactCommand(msg.Content))
+				}
+
 				cmdMsg := ProcessCommand(msg.Content)
 				cmdMsg.Sender = client.Username
 
@@ -505,7 +935,7 @@ func (h *Handler) HandleClient(conn net.Conn) {
 						client.Send <- shared.Message{
 							Type:    shared.TextMessage,
 							Sender:  "Server",
-							Content: fmt.Sprintf("You are already in room: %s", cmdMsg.RoomName),
+							Content: fmt.Sprintf(shared.ReplyAlreadyInRoom, cmdMsg.RoomName),
 						}
 						continue
 					}
@@ -515,21 +945,16 @@ func (h *Handler) HandleClient(conn net.Conn) {
 						client.Send <- shared.Message{
 							Type:    shared.TextMessage,
 							Sender:  "Server",
-							Content: fmt.Sprintf("Error joining room: %v", err),
+							Content: fmt.Sprintf(shared.ReplyJoinFailed, err),
 						}
 					} else {
+						h.Plugins.Joined(cmdMsg.RoomName, client.Username)
+						h.startReading(client.Username, cmdMsg.RoomName)
 						client.Send <- shared.Message{
 							Type:    shared.TextMessage,
 							Sender:  "Server",
-							Content: fmt.Sprintf("You have joined room: %s", cmdMsg.RoomName),
+							Content: fmt.Sprintf(shared.ReplyJoined, cmdMsg.RoomName),
 						}
-
-						h.RoomManager.BroadcastToRoom(cmdMsg.RoomName, shared.Message{
-							Type:     shared.TextMessage,
-							Sender:   "Server",
-							RoomName: cmdMsg.RoomName,
-							Content:  fmt.Sprintf("%s has joined the room", client.Username),
-						})
 					}
 
 				case shared.LeaveRoomMessage:
@@ -537,30 +962,24 @@ func (h *Handler) HandleClient(conn net.Conn) {
 						client.Send <- shared.Message{
 							Type:    shared.TextMessage,
 							Sender:  "Server",
-							Content: fmt.Sprintf("You are not in room: %s", cmdMsg.RoomName),
+							Content: fmt.Sprintf(shared.ReplyNotMember, cmdMsg.RoomName),
 						}
 						continue
 					}
 
-					h.RoomManager.BroadcastToRoom(cmdMsg.RoomName, shared.Message{
-						Type:     shared.TextMessage,
-						Sender:   "Server",
-						RoomName: cmdMsg.RoomName,
-						Content:  fmt.Sprintf("%s has left the room", client.Username),
-					})
-
 					err := h.RoomManager.LeaveRoom(cmdMsg.RoomName, client)
 					if err != nil {
 						client.Send <- shared.Message{
 							Type:    shared.TextMessage,
 							Sender:  "Server",
-							Content: fmt.Sprintf("Error leaving room: %v", err),
+							Content: fmt.Sprintf(shared.ReplyLeaveFailed, err),
 						}
 					} else {
+						h.Plugins.Left(cmdMsg.RoomName, client.Username)
 						client.Send <- shared.Message{
 							Type:    shared.TextMessage,
 							Sender:  "Server",
-							Content: fmt.Sprintf("You have left room: %s", cmdMsg.RoomName),
+							Content: fmt.Sprintf(shared.ReplyLeft, cmdMsg.RoomName),
 						}
 					}
 
@@ -570,13 +989,14 @@ func (h *Handler) HandleClient(conn net.Conn) {
 						client.Send <- shared.Message{
 							Type:    shared.TextMessage,
 							Sender:  "Server",
-							Content: fmt.Sprintf("Error creating room: %v", err),
+							Content: fmt.Sprintf(shared.ReplyCreateFailed, err),
 						}
 					} else {
+						h.Audit.Record(audit.EventRoomCreate, client.Username, cmdMsg.RoomName, remoteHost(conn), "")
 						client.Send <- shared.Message{
 							Type:    shared.TextMessage,
 							Sender:  "Server",
-							Content: fmt.Sprintf("Room created: %s", cmdMsg.RoomName),
+							Content: fmt.Sprintf(shared.ReplyRoomCreated, cmdMsg.RoomName),
 						}
 					}
 
@@ -615,15 +1035,27 @@ func (h *Handler) HandleClient(conn net.Conn) {
 							client.Send <- shared.Message{
 								Type:   shared.TextMessage,
 								Sender: "Server",
-								Content: fmt.Sprintf("You are not in room %s. Join it first with /join %s",
+								Content: fmt.Sprintf(shared.ReplyNotInRoom,
 									cmdMsg.RoomName, cmdMsg.RoomName),
 							}
 							continue
 						}
 
+						if !h.checkSlowMode(client, cmdMsg.RoomName) {
+							continue
+						}
+
 						h.Broadcast <- cmdMsg
 					} else {
-						client.Send <- cmdMsg
+						// Plugins may post to the room a command came from, so
+						// only pass on rooms the user is actually in.
+						commandRoom := msg.RoomName
+						if !client.IsInRoom(commandRoom) {
+							commandRoom = ""
+						}
+						if !h.Plugins.RunCommand(client.Username, commandRoom, cmdMsg.Content) {
+							client.Send <- cmdMsg
+						}
 					}
 				}
 			} else if msg.Type == shared.FileTransferRequest ||
@@ -633,7 +1065,7 @@ func (h *Handler) HandleClient(conn net.Conn) {
 
 					err := h.FileTransfer.ReceiveChunk(msg)
 					if err != nil {
-						log.Printf("Error receiving final file chunk: %v", err)
+						client.Log.Error("Error receiving final file chunk", "transfer", TransferID(msg.Sender, msg.Recipient, msg.FileName), "error", err)
 						continue
 					}
 
@@ -643,46 +1075,32 @@ func (h *Handler) HandleClient(conn net.Conn) {
 
 					if exists {
 						recipientMsg := shared.Message{
-							Type:   shared.TextMessage,
-							Sender: "Server",
-							Content: fmt.Sprintf("╔════════════════════════════════════════════════════╗\n"+
-								"║           File Transfer Complete                   ║\n"+
-								"╠════════════════════════════════════════════════════╣\n"+
-								"║ File: %-43s ║\n"+
-								"║ Size: %-7.2f KB                                 ║\n"+
-								"║ From: %-43s ║\n"+
-								"║                                                ║\n"+
-								"║ File saved to: downloads/%-27s ║\n"+
-								"╚════════════════════════════════════════════════════╝",
-								msg.FileName, float64(msg.FileSize)/1024, msg.Sender, msg.FileName),
+							Type:    shared.TextMessage,
+							Sender:  "Server",
+							Content: fmt.Sprintf(shared.ReplyFileReceived, msg.FileName, float64(msg.FileSize)/1024, msg.Sender, msg.FileName),
 						}
 						recipient.Send <- recipientMsg
 					}
 
 					confirmMsg := shared.Message{
-						Type:   shared.TextMessage,
-						Sender: "Server",
-						Content: fmt.Sprintf("╔════════════════════════════════════════════════════╗\n"+
-							"║           File Transfer Complete                   ║\n"+
-							"╠════════════════════════════════════════════════════╣\n"+
-							"║ File: %-43s ║\n"+
-							"║ Size: %-7.2f KB                                 ║\n"+
-							"║ To:   %-43s ║\n"+
-							"║                                                ║\n"+
-							"║ File successfully transferred                   ║\n"+
-							"╚════════════════════════════════════════════════════╝",
-							msg.FileName, float64(msg.FileSize)/1024, msg.Recipient),
+						Type:    shared.TextMessage,
+						Sender:  "Server",
+						Content: fmt.Sprintf(shared.ReplyFileSent, msg.FileName, float64(msg.FileSize)/1024, msg.Recipient),
 					}
 					client.Send <- confirmMsg
+					client.Log.Info("File transfer complete", "transfer", TransferID(msg.Sender, msg.Recipient, msg.FileName), "bytes", msg.FileSize)
 				} else {
+					if msg.Type == shared.FileTransferRequest {
+						client.Log.Info("File transfer started", "transfer", TransferID(msg.Sender, msg.Recipient, msg.FileName), "bytes", msg.FileSize)
+					}
 
-					HandleFileTransfer(msg, conn, h.FileTransfer)
+					HandleFileTransfer(msg, frameWriter{conn}, h.FileTransfer)
 				}
 			} else {
 
 				if msg.RoomName == "" {
 					var activeRoom string
-					for room := range client.Rooms {
+					for _, room := range client.RoomList() {
 						activeRoom = room
 						break
 					}
@@ -698,23 +1116,57 @@ func (h *Handler) HandleClient(conn net.Conn) {
 					client.Send <- shared.Message{
 						Type:   shared.TextMessage,
 						Sender: "Server",
-						Content: fmt.Sprintf("You are not in room %s. Join it first with /join %s",
+						Content: fmt.Sprintf(shared.ReplyNotInRoom,
 							msg.RoomName, msg.RoomName),
 					}
 					continue
 				}
 
+				if msg.ParentID != "" {
+					r, exists := h.RoomManager.GetRoom(msg.RoomName)
+					if !exists {
+						continue
+					}
+					root, err := threadRoot(r, msg.ParentID)
+					if err != nil {
+						client.Send <- serverNotice(fmt.Sprintf(shared.ReplyReplyFailed, err))
+						continue
+					}
+					msg.ParentID = root
+				}
+
+				if !h.checkSlowMode(client, msg.RoomName) {
+					continue
+				}
+
 				h.Broadcast <- msg
 			}
 		}
 	}()
 
 	go func() {
-		ticker := time.NewTicker(60 * time.Second)
-		defer func() {
-			ticker.Stop()
-			conn.Close()
-		}()
+		var pings <-chan time.Time
+		if h.Timeouts.PingInterval > 0 {
+			ticker := time.NewTicker(h.Timeouts.PingInterval)
+			defer ticker.Stop()
+			pings = ticker.C
+		}
+		defer conn.Close()
+
+		write := func(message shared.Message) error {
+			data, err := FormatMessage(message)
+			if err != nil {
+				return err
+			}
+			if h.Timeouts.WriteTimeout > 0 {
+				conn.SetWriteDeadline(time.Now().Add(h.Timeouts.WriteTimeout))
+			}
+			err = conn.WriteFrame(data)
+			if err == nil {
+				messagesSent.With(message.Type.String()).Inc()
+			}
+			return err
+		}
 
 		for {
 			select {
@@ -722,34 +1174,22 @@ func (h *Handler) HandleClient(conn net.Conn) {
 				if !ok {
 					return
 				}
-				data, err := FormatMessage(message)
-				if err != nil {
-					log.Printf("Error formatting message: %v", err)
-					return
-				}
-				_, err = conn.Write(data)
+				err := write(message)
 				if err != nil {
-					log.Printf("Error writing to client %s: %v", client.Username, err)
+					client.Log.Warn("Error writing to client", "error", err)
 					return
 				}
 
-			case <-ticker.C:
-
-				pingMsg := shared.Message{
-					Type:    shared.TextMessage,
-					Sender:  "Server",
-					Content: "PING",
-				}
-
-				data, err := FormatMessage(pingMsg)
-				if err != nil {
-					log.Printf("Error formatting ping message: %v", err)
+			case <-pings:
+				if int(missedPongs.Load()) >= h.Timeouts.MaxMissedPongs {
+					client.Log.Info("Client missed pongs, disconnecting", "missed", h.Timeouts.MaxMissedPongs)
 					return
 				}
+				missedPongs.Add(1)
 
-				_, err = conn.Write(data)
+				err := write(shared.Message{Type: shared.PingMessage, Sender: "Server"})
 				if err != nil {
-					log.Printf("Error writing ping to client %s: %v", client.Username, err)
+					client.Log.Warn("Error writing ping to client", "error", err)
 					return
 				}
 			}
@@ -0,0 +1,233 @@
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/imaneimrh/TCP-Chat_Server/auth"
+	"github.com/imaneimrh/TCP-Chat_Server/room"
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
+)
+
+func newTestHandler() *Handler {
+	h := NewHandler(room.NewManager(), auth.NewManager())
+	h.Protocol = ProtocolLimits{
+		MaxFrameSize:    1024,
+		MaxContentSize:  256,
+		MaxFileNameSize: 32,
+		MaxFileDataSize: 128,
+	}
+	go h.Run()
+	return h
+}
+
+func connectPipe(t *testing.T, h *Handler) (net.Conn, *bufio.Reader) {
+	t.Helper()
+
+	serverConn, clientConn := net.Pipe()
+	h.HandleClient(serverConn)
+	t.Cleanup(func() { clientConn.Close() })
+
+	reader := bufio.NewReader(clientConn)
+	readMessage(t, clientConn, reader)
+
+	return clientConn, reader
+}
+
+func readMessage(t *testing.T, conn net.Conn, reader *bufio.Reader) shared.Message {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("failed to read message: %v", err)
+	}
+
+	var msg shared.Message
+	if err := json.Unmarshal(line, &msg); err != nil {
+		t.Fatalf("failed to decode message %q: %v", line, err)
+	}
+	return msg
+}
+
+// expectProtocolError reads until the server closes the connection and fails
+// unless a protocol error was reported before the close.
+func expectProtocolError(t *testing.T, conn net.Conn, reader *bufio.Reader) {
+	t.Helper()
+
+	sawError := false
+	for {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				t.Fatal("connection was not closed after protocol error")
+			}
+			break
+		}
+
+		var msg shared.Message
+		if json.Unmarshal(line, &msg) == nil && strings.HasPrefix(msg.Content, "Protocol error") {
+			sawError = true
+		}
+	}
+
+	if !sawError {
+		t.Fatal("expected a protocol error before disconnect")
+	}
+}
+
+func TestOversizedFrameDisconnects(t *testing.T) {
+	h := newTestHandler()
+	conn, reader := connectPipe(t, h)
+
+	go conn.Write([]byte(strings.Repeat("a", 4*h.Protocol.MaxFrameSize)))
+
+	expectProtocolError(t, conn, reader)
+}
+
+func TestInvalidMessagesDisconnect(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  shared.Message
+	}{
+		{
+			name: "oversized content",
+			msg:  shared.Message{Type: shared.TextMessage, Content: strings.Repeat("x", 300)},
+		},
+		{
+			name: "oversized file name",
+			msg:  shared.Message{Type: shared.FileTransferRequest, Recipient: "bob", FileName: strings.Repeat("f", 40) + ".txt", FileSize: 10},
+		},
+		{
+			name: "oversized file chunk",
+			msg:  shared.Message{Type: shared.FileTransferData, Recipient: "bob", FileName: "a.txt", FileData: make([]byte, 200), FileSize: 1000},
+		},
+		{
+			name: "spoofed notice",
+			msg:  shared.Message{Type: shared.TextMessage, RoomName: "general", Content: "x has left the room.", Notice: &shared.Notice{Kind: shared.NoticeLeft, User: "x"}},
+		},
+		{
+			name: "path traversal in file name",
+			msg:  shared.Message{Type: shared.FileTransferData, Recipient: "bob", FileName: "../../etc/passwd", FileData: []byte("x"), FileSize: 1},
+		},
+		{
+			name: "negative file offset",
+			msg:  shared.Message{Type: shared.FileTransferData, Recipient: "bob", FileName: "a.txt", FileData: []byte("x"), FileSize: 1, FileOffset: -5},
+		},
+		{
+			name: "chunk beyond file size",
+			msg:  shared.Message{Type: shared.FileTransferData, Recipient: "bob", FileName: "a.txt", FileData: []byte("xyz"), FileSize: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandler()
+			conn, reader := connectPipe(t, h)
+
+			data, err := FormatMessage(tt.msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			go conn.Write(data)
+
+			expectProtocolError(t, conn, reader)
+		})
+	}
+}
+
+func TestOversizedPlainTextDisconnects(t *testing.T) {
+	h := newTestHandler()
+	conn, reader := connectPipe(t, h)
+
+	go conn.Write([]byte(strings.Repeat("y", 300) + "\n"))
+
+	expectProtocolError(t, conn, reader)
+}
+
+func TestValidInputKeepsConnection(t *testing.T) {
+	h := newTestHandler()
+	conn, reader := connectPipe(t, h)
+
+	go conn.Write([]byte("/whoami\n"))
+
+	msg := readMessage(t, conn, reader)
+	if msg.Content != "You are not logged in" {
+		t.Fatalf("unexpected reply: %q", msg.Content)
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	reader := bufio.NewReaderSize(strings.NewReader("short\n"+strings.Repeat("z", 100)+"\n"), 16)
+
+	frame, err := ReadFrame(reader, 50)
+	if err != nil || string(frame) != "short\n" {
+		t.Fatalf("ReadFrame() = %q, %v", frame, err)
+	}
+
+	_, err = ReadFrame(reader, 50)
+	if err != ErrFrameTooLarge {
+		t.Fatalf("ReadFrame() error = %v, want ErrFrameTooLarge", err)
+	}
+}
+
+func TestRegisterDisabled(t *testing.T) {
+	h := newTestHandler()
+	h.OpenRegistration = false
+	conn, reader := connectPipe(t, h)
+
+	go conn.Write([]byte("/register alice secret\n"))
+
+	msg := readMessage(t, conn, reader)
+	if !strings.HasPrefix(msg.Content, "Registration is disabled") {
+		t.Fatalf("unexpected reply: %q", msg.Content)
+	}
+	if _, exists := h.AuthManager.GetUser("alice"); exists {
+		t.Fatal("user was registered while registration is disabled")
+	}
+}
+
+func TestRegisterReservedName(t *testing.T) {
+	h := newTestHandler()
+	if err := h.AuthManager.Reserve("deploy-bot"); err != nil {
+		t.Fatal(err)
+	}
+	conn, reader := connectPipe(t, h)
+
+	for _, name := range []string{"Server", "server", "SERVER", "deploy-bot", "Deploy-Bot"} {
+		go conn.Write([]byte("/register " + name + " secret\n"))
+
+		msg := readMessage(t, conn, reader)
+		if !strings.Contains(msg.Content, "reserved") {
+			t.Fatalf("/register %s: unexpected reply %q", name, msg.Content)
+		}
+	}
+}
+
+// acceptAll stands in for a directory that knows every name.
+type acceptAll struct{}
+
+func (acceptAll) Authenticate(username, password string) error { return nil }
+
+func TestLoginReservedName(t *testing.T) {
+	h := newTestHandler()
+	h.Authenticator = acceptAll{}
+	if err := h.AuthManager.Reserve("deploy-bot"); err != nil {
+		t.Fatal(err)
+	}
+	conn, reader := connectPipe(t, h)
+
+	for _, name := range []string{"Server", "deploy-bot"} {
+		go conn.Write([]byte("/login " + name + " secret\n"))
+
+		msg := readMessage(t, conn, reader)
+		if !strings.Contains(msg.Content, "reserved") {
+			t.Fatalf("/login %s: unexpected reply %q", name, msg.Content)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package client
+
+import (
+	"encoding/json"
+	"net"
+	"time"
+
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
+)
+
+type Timeouts struct {
+	PingInterval   time.Duration
+	MaxMissedPongs int
+	LoginTimeout   time.Duration
+	IdleTimeout    time.Duration
+	WriteTimeout   time.Duration
+}
+
+func DefaultTimeouts() Timeouts {
+	return Timeouts{
+		PingInterval:   30 * time.Second,
+		MaxMissedPongs: 3,
+		LoginTimeout:   60 * time.Second,
+		IdleTimeout:    0,
+		WriteTimeout:   10 * time.Second,
+	}
+}
+
+// readDeadline returns when the next read must complete: a client that
+// answers no pings for MaxMissedPongs intervals, or sends nothing but pongs
+// for IdleTimeout, is considered gone.
+func (t Timeouts) readDeadline(lastActivity time.Time) time.Time {
+	var deadline time.Time
+
+	if t.PingInterval > 0 {
+		deadline = time.Now().Add(t.PingInterval * time.Duration(t.MaxMissedPongs+1))
+	}
+
+	if t.IdleTimeout > 0 {
+		idle := lastActivity.Add(t.IdleTimeout)
+		if deadline.IsZero() || idle.Before(deadline) {
+			deadline = idle
+		}
+	}
+
+	return deadline
+}
+
+func frameType(line []byte) (shared.MessageType, bool) {
+	if len(line) == 0 || line[0] != '{' {
+		return 0, false
+	}
+
+	var frame struct {
+		Type shared.MessageType
+	}
+	if json.Unmarshal(line, &frame) != nil {
+		return 0, false
+	}
+
+	return frame.Type, true
+}
+
+func isTimeout(err error) bool {
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
@@ -0,0 +1,170 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/imaneimrh/TCP-Chat_Server/room"
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
+)
+
+type MentionLimits struct {
+	// BroadcastRoomSize is the largest room in which anyone may use @room
+	// and @here. In bigger rooms only moderators may (0 disables).
+	BroadcastRoomSize int
+	// QueueSize caps the mentions kept for a user who is offline. The
+	// oldest are dropped first.
+	QueueSize int
+}
+
+func DefaultMentionLimits() MentionLimits {
+	return MentionLimits{
+		BroadcastRoomSize: 20,
+		QueueSize:         50,
+	}
+}
+
+// mentionQueue keeps mentions of offline users until they log in. Only users
+// the server knows of are queued for, so a typo cannot grow the queue.
+type mentionQueue struct {
+	mu      sync.Mutex
+	known   map[string]bool
+	pending map[string][]shared.Message
+}
+
+func newMentionQueue() *mentionQueue {
+	return &mentionQueue{
+		known:   make(map[string]bool),
+		pending: make(map[string][]shared.Message),
+	}
+}
+
+// take marks username as known and returns the mentions queued for them.
+func (q *mentionQueue) take(username string) []shared.Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.known[username] = true
+	pending := q.pending[username]
+	delete(q.pending, username)
+	return pending
+}
+
+func (q *mentionQueue) add(username string, message shared.Message, size int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	pending := append(q.pending[username], message)
+	if size > 0 && len(pending) > size {
+		mentionsRouted.With("dropped").Add(float64(len(pending) - size))
+		pending = pending[len(pending)-size:]
+	}
+	q.pending[username] = pending
+}
+
+func (q *mentionQueue) isKnown(username string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.known[username]
+}
+
+// mayMentionAll reports whether sender may use @room and @here in r.
+func (h *Handler) mayMentionAll(sender string, r *room.Room) bool {
+	limit := h.Mentions.BroadcastRoomSize
+	return limit <= 0 || r.GetClientCount() <= limit || h.AuthManager.IsModerator(sender)
+}
+
+// checkMentions refuses @room and @here from users who may not use them in
+// the room a message is for.
+func (h *Handler) checkMentions(message shared.Message) error {
+	if message.Sender == shared.ServerName || !strings.Contains(message.Content, "@") {
+		return nil
+	}
+
+	_, everyone, here := shared.ParseMentions(message.Content)
+	if !everyone && !here {
+		return nil
+	}
+
+	r, exists := h.RoomManager.GetRoom(message.RoomName)
+	if !exists || h.mayMentionAll(message.Sender, r) {
+		return nil
+	}
+	return fmt.Errorf("only moderators can use @room and @here in rooms with more than %d members", h.Mentions.BroadcastRoomSize)
+}
+
+// notifyMentions sends a MentionMessage to everyone a room message mentions,
+// whether or not they are in the room, and queues it for known users who are
+// offline. @here reaches the members of the room and @room also reaches
+// everyone who has posted in its history.
+func (h *Handler) notifyMentions(roomName string, message shared.Message) {
+	r, exists := h.RoomManager.GetRoom(roomName)
+	if !exists {
+		return
+	}
+
+	users, everyone, here := shared.ParseMentions(message.Content)
+	targets := make(map[string]bool)
+	for _, username := range users {
+		targets[username] = true
+	}
+	if (everyone || here) && h.mayMentionAll(message.Sender, r) {
+		for _, username := range r.Members() {
+			targets[username] = true
+		}
+		if everyone {
+			for _, post := range r.History(0) {
+				targets[post.Sender] = true
+			}
+		}
+	}
+	delete(targets, message.Sender)
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for username := range targets {
+		mention := shared.Message{
+			Type:      shared.MentionMessage,
+			Sender:    message.Sender,
+			Recipient: username,
+			RoomName:  roomName,
+			Content:   message.Content,
+			ID:        message.ID,
+			ParentID:  message.ParentID,
+		}
+
+		if target, online := h.Clients[username]; online && target.Username == username {
+			// Skip a full queue rather than hold h.mu for a slow client.
+			select {
+			case target.Send <- mention:
+				mentionsRouted.With("delivered").Inc()
+			default:
+				mentionsRouted.With("dropped").Inc()
+			}
+			continue
+		}
+
+		if _, registered := h.AuthManager.GetUser(username); registered || h.mentions.isKnown(username) {
+			h.mentions.add(username, mention, h.Mentions.QueueSize)
+			mentionsRouted.With("queued").Inc()
+		}
+	}
+}
+
+// pendingMentions takes the mentions queued for a user while they were
+// offline, preceded by a notice, so that they can be sent at login.
+func (h *Handler) pendingMentions(username string) []shared.Message {
+	pending := h.mentions.take(username)
+	if len(pending) == 0 {
+		return nil
+	}
+
+	count := fmt.Sprintf("%d times", len(pending))
+	if len(pending) == 1 {
+		count = "once"
+	}
+	notice := serverNotice(fmt.Sprintf("You were mentioned %s while you were away:", count))
+	return append([]shared.Message{notice}, pending...)
+}
@@ -0,0 +1,100 @@
+package client
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/imaneimrh/TCP-Chat_Server/auth"
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
+)
+
+// joinGeneral puts n stand-in members into the general room.
+func joinGeneral(t *testing.T, h *Handler, n int) {
+	t.Helper()
+
+	r, _ := h.RoomManager.GetRoom("general")
+	for i := 0; i < n; i++ {
+		r.Register <- &shared.Client{
+			Username: string(rune('a' + i)),
+			Rooms:    make(map[string]bool),
+			Send:     make(chan shared.Message, 100),
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for r.GetClientCount() < n {
+		if time.Now().After(deadline) {
+			t.Fatal("members did not join")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPostAsChecksMentions(t *testing.T) {
+	h := newTestHandler()
+	h.Mentions.BroadcastRoomSize = 2
+	joinGeneral(t, h, 3)
+
+	if err := h.PostAs("deploy-bot", "general", "@room deploy finished"); err == nil {
+		t.Fatal("PostAs() allowed @room in a room over the limit")
+	}
+	if err := h.PostAs("deploy-bot", "general", "@alice deploy finished"); err != nil {
+		t.Fatalf("PostAs() error = %v", err)
+	}
+
+	h.AuthManager.SetRole("deploy-bot", auth.RoleModerator)
+	if err := h.PostAs("deploy-bot", "general", "@here deploy finished"); err != nil {
+		t.Fatalf("PostAs() as a moderator error = %v", err)
+	}
+}
+
+func TestQueuedMentionsAtLogin(t *testing.T) {
+	h := newTestHandler()
+	h.mentions.add("bob", shared.Message{Type: shared.MentionMessage, Sender: "alice", Recipient: "bob", RoomName: "general", Content: "@bob ping", ID: "1"}, 0)
+
+	// An unbuffered queue holds up every send until the test reads it.
+	bob := &shared.Client{Username: "bob", Rooms: make(map[string]bool), Send: make(chan shared.Message)}
+	h.Register <- bob
+
+	// next skips the general room's own notices, which come from its
+	// goroutine in no particular order.
+	next := func() shared.Message {
+		t.Helper()
+		for {
+			select {
+			case msg := <-bob.Send:
+				if msg.Type == shared.TextMessage && msg.RoomName != "" {
+					continue
+				}
+				return msg
+			case <-time.After(2 * time.Second):
+				t.Fatal("no message arrived")
+				return shared.Message{}
+			}
+		}
+	}
+
+	if welcome := next(); welcome.Sender != shared.ServerName || !strings.HasPrefix(welcome.Content, "Welcome") {
+		t.Fatalf("first message = %+v, want the welcome", welcome)
+	}
+
+	locked := make(chan struct{})
+	go func() {
+		h.mu.RLock()
+		h.mu.RUnlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("h.mu is held while waiting for a slow client")
+	}
+
+	if notice := next(); notice.Content != "You were mentioned once while you were away:" {
+		t.Fatalf("notice = %+v", notice)
+	}
+	if mention := next(); mention.Type != shared.MentionMessage || mention.Content != "@bob ping" {
+		t.Fatalf("mention = %+v", mention)
+	}
+}
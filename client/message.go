@@ -6,7 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
 	"strings"
 
@@ -48,6 +48,31 @@ func ReadMessage(reader *bufio.Reader) (shared.Message, error) {
 	return msg, nil
 }
 
+var ErrFrameTooLarge = shared.ErrFrameTooLarge
+
+// ReadFrame reads up to the next newline but gives up with ErrFrameTooLarge
+// once more than maxSize bytes have been buffered without one.
+func ReadFrame(reader *bufio.Reader, maxSize int) ([]byte, error) {
+	var frame []byte
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if maxSize > 0 && len(frame)+len(chunk) > maxSize {
+			return nil, ErrFrameTooLarge
+		}
+
+		frame = append(frame, chunk...)
+
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return frame, nil
+	}
+}
+
 func IsCommand(content string) bool {
 	return len(content) > 0 && content[0] == '/'
 }
@@ -66,7 +91,7 @@ func ProcessCommand(content string) shared.Message {
 			return shared.Message{
 				Type:    shared.TextMessage,
 				Sender:  "Server",
-				Content: "Usage: /join <room>",
+				Content: fmt.Sprintf(shared.ReplyUsage, "/join <room>"),
 			}
 		}
 		roomName := parts[1]
@@ -80,7 +105,7 @@ func ProcessCommand(content string) shared.Message {
 			return shared.Message{
 				Type:    shared.TextMessage,
 				Sender:  "Server",
-				Content: "Usage: /leave <room>",
+				Content: fmt.Sprintf(shared.ReplyUsage, "/leave <room>"),
 			}
 		}
 		roomName := parts[1]
@@ -94,7 +119,7 @@ func ProcessCommand(content string) shared.Message {
 			return shared.Message{
 				Type:    shared.TextMessage,
 				Sender:  "Server",
-				Content: "Usage: /create <room>",
+				Content: fmt.Sprintf(shared.ReplyUsage, "/create <room>"),
 			}
 		}
 		roomName := parts[1]
@@ -113,7 +138,7 @@ func ProcessCommand(content string) shared.Message {
 			return shared.Message{
 				Type:    shared.TextMessage,
 				Sender:  "Server",
-				Content: "Usage: /msg <username> <message>",
+				Content: fmt.Sprintf(shared.ReplyUsage, "/msg <username> <message>"),
 			}
 		}
 		recipient := parts[1]
@@ -129,7 +154,7 @@ func ProcessCommand(content string) shared.Message {
 			return shared.Message{
 				Type:    shared.TextMessage,
 				Sender:  "Server",
-				Content: "Usage: /room <roomname> <message>",
+				Content: fmt.Sprintf(shared.ReplyUsage, "/room <roomname> <message>"),
 			}
 		}
 		roomName := parts[1]
@@ -173,15 +198,37 @@ func ProcessCommand(content string) shared.Message {
 			"║   /leave <room>                   - Leave a chat room         ║\n" +
 			"║   /create <room>                  - Create a new room         ║\n" +
 			"║   /list                           - List available rooms      ║\n" +
+			"║   /topic <room> [text]            - Show or set a room topic  ║\n" +
 			"║                                                              ║\n" +
 			"║ Messaging:                                                   ║\n" +
 			"║   /msg <username> <message>       - Send a direct message     ║\n" +
 			"║   /room <roomname> <message>      - Send to specific room     ║\n" +
 			"║   /users                          - Show online users         ║\n" +
+			"║   /edit <id> <text>               - Edit one of your messages ║\n" +
+			"║   /delete <id>                    - Delete a message          ║\n" +
+			"║   /reply <id> <text>              - Reply in a thread         ║\n" +
+			"║   /thread <id>                    - Show a whole thread       ║\n" +
+			"║   /react <id> <emoji>             - React to a message        ║\n" +
+			"║   /unreact <id> <emoji>           - Take back a reaction      ║\n" +
+			"║   /search <words> [filters]       - Search message history    ║\n" +
+			"║   /unread                         - List unread conversations ║\n" +
+			"║   /read [room|@user] [id]         - Mark messages read        ║\n" +
 			"║                                                              ║\n" +
 			"║ File Transfer:                                               ║\n" +
 			"║   /file <username> <filepath>     - Send a file to a user     ║\n" +
 			"║                                                              ║\n" +
+			"║ Moderation:                                                  ║\n" +
+			"║   /slowmode <room> <seconds>      - Limit posting rate        ║\n" +
+			"║   /kick <username> [reason]       - Disconnect a user         ║\n" +
+			"║   /ban <username> [dur] [reason]  - Ban a user (admin)        ║\n" +
+			"║   /unban <username>               - Lift a user ban (admin)   ║\n" +
+			"║   /banip <ip|cidr> [dur] [reason] - Ban an address (admin)    ║\n" +
+			"║   /unbanip <ip|cidr>              - Lift an address ban       ║\n" +
+			"║   /bans                           - List active bans (admin)  ║\n" +
+			"║   /reloadacl                      - Reload the access list    ║\n" +
+			"║   /loglevel [level]               - Show or set log level     ║\n" +
+			"║   /role <user> <role>             - Set a user's role         ║\n" +
+			"║                                                              ║\n" +
 			"║ Other:                                                       ║\n" +
 			"║   /help                           - Show this help message    ║\n" +
 			"║   /quit                           - Exit the chat client      ║\n" +
@@ -243,7 +290,7 @@ func HandleFileTransfer(msg shared.Message, writer io.Writer, ft *FileTransfer)
 
 		progress, err := ft.GetTransferProgress(msg.Sender, msg.FileName)
 		if err != nil {
-			log.Printf("Error getting transfer progress: %v", err)
+			slog.Warn("Error getting transfer progress", "transfer", TransferID(msg.Sender, msg.Recipient, msg.FileName), "error", err)
 		} else {
 			progressBar := generateProgressBar(progress, 40)
 
@@ -275,16 +322,7 @@ func HandleFileTransfer(msg shared.Message, writer io.Writer, ft *FileTransfer)
 			Type:      shared.TextMessage,
 			Sender:    "Server",
 			Recipient: msg.Recipient,
-			Content: fmt.Sprintf("╔════════════════════════════════════════════════════╗\n"+
-				"║           File Transfer Complete                   ║\n"+
-				"╠════════════════════════════════════════════════════╣\n"+
-				"║ File: %-43s ║\n"+
-				"║ Size: %-7.2f KB                                 ║\n"+
-				"║ From: %-43s ║\n"+
-				"║                                                ║\n"+
-				"║ File saved to: downloads/%-27s ║\n"+
-				"╚════════════════════════════════════════════════════╝",
-				msg.FileName, float64(msg.FileSize)/1024, msg.Sender, msg.FileName),
+			Content:   fmt.Sprintf(shared.ReplyFileReceived, msg.FileName, float64(msg.FileSize)/1024, msg.Sender, msg.FileName),
 		}
 
 		data, err := FormatMessage(recipientMsg)
@@ -301,16 +339,7 @@ func HandleFileTransfer(msg shared.Message, writer io.Writer, ft *FileTransfer)
 			Type:      shared.TextMessage,
 			Sender:    "Server",
 			Recipient: msg.Sender,
-			Content: fmt.Sprintf("╔════════════════════════════════════════════════════╗\n"+
-				"║           File Transfer Complete                   ║\n"+
-				"╠════════════════════════════════════════════════════╣\n"+
-				"║ File: %-43s ║\n"+
-				"║ Size: %-7.2f KB                                 ║\n"+
-				"║ To:   %-43s ║\n"+
-				"║                                                ║\n"+
-				"║ File successfully transferred                   ║\n"+
-				"╚════════════════════════════════════════════════════╝",
-				msg.FileName, float64(msg.FileSize)/1024, msg.Recipient),
+			Content:   fmt.Sprintf(shared.ReplyFileSent, msg.FileName, float64(msg.FileSize)/1024, msg.Recipient),
 		}
 
 		data, err = FormatMessage(senderMsg)
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"github.com/imaneimrh/TCP-Chat_Server/metrics"
+)
+
+var (
+	messagesReceived  = metrics.NewCounterVec("chat_messages_received_total", "Frames received from clients by message type.", "type")
+	messagesSent      = metrics.NewCounterVec("chat_messages_sent_total", "Messages written to clients by message type.", "type")
+	authFailures      = metrics.NewCounterVec("chat_auth_failures_total", "Failed login attempts by reason.", "reason")
+	fileTransferBytes = metrics.NewCounter("chat_file_transfer_bytes_total", "File data bytes received by the server.")
+	mentionsRouted    = metrics.NewCounterVec("chat_mentions_total", "Mention notifications by outcome.", "result")
+)
+
+func (h *Handler) RegisterMetrics() {
+	metrics.NewGaugeFunc("chat_authenticated_users", "Number of logged-in users.", func() float64 {
+		return float64(len(h.OnlineUsers()))
+	})
+
+	metrics.NewGaugeFunc("chat_send_queue_depth", "Messages waiting in all client send queues.", func() float64 {
+		total, _ := h.sendQueueDepth()
+		return float64(total)
+	})
+
+	metrics.NewGaugeFunc("chat_send_queue_depth_max", "Messages waiting in the fullest client send queue.", func() float64 {
+		_, max := h.sendQueueDepth()
+		return float64(max)
+	})
+
+	metrics.NewGaugeFunc("chat_file_transfers_active", "File transfers in progress.", func() float64 {
+		return float64(h.FileTransfer.ActiveCount())
+	})
+
+	metrics.NewGaugeFunc("chat_search_documents", "Messages held in the search index.", func() float64 {
+		return float64(h.Search.Len())
+	})
+}
+
+func (h *Handler) sendQueueDepth() (int, int) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	total, max := 0, 0
+	for _, client := range h.Clients {
+		depth := len(client.Send)
+		total += depth
+		if depth > max {
+			max = depth
+		}
+	}
+	return total, max
+}
+
+// frameLabel classifies a raw frame for the received-messages counter.
+func frameLabel(line []byte) string {
+	if len(line) > 0 && line[0] == '/' {
+		return "command"
+	}
+	if msgType, ok := frameType(line); ok {
+		return msgType.String()
+	}
+	return "text"
+}
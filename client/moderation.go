@@ -0,0 +1,321 @@
+package client
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imaneimrh/TCP-Chat_Server/audit"
+	"github.com/imaneimrh/TCP-Chat_Server/auth"
+	"github.com/imaneimrh/TCP-Chat_Server/logging"
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
+)
+
+func serverNotice(content string) shared.Message {
+	return shared.Message{
+		Type:    shared.TextMessage,
+		Sender:  shared.ServerName,
+		Content: content,
+	}
+}
+
+// usageNotice shows the syntax of a command that was called wrongly.
+func usageNotice(syntax string) shared.Message {
+	return serverNotice(fmt.Sprintf(shared.ReplyUsage, syntax))
+}
+
+// noSuchMessage is the reason given when a command names an unknown message.
+func noSuchMessage(id string) error {
+	return fmt.Errorf("message %s does not exist", id)
+}
+
+func (h *Handler) handleModerationCommand(client *shared.Client, command []string) {
+	if client.Username == "" || !h.AuthManager.IsModerator(client.Username) {
+		client.Send <- serverNotice("You do not have permission to use " + command[0])
+		return
+	}
+
+	adminOnly := command[0] != "/kick" && command[0] != "/slowmode"
+	if adminOnly && !h.AuthManager.IsAdmin(client.Username) {
+		client.Send <- serverNotice("Only admins can use " + command[0])
+		return
+	}
+
+	switch command[0] {
+	case "/loglevel":
+		h.handleLogLevel(client, command)
+		return
+	case "/role":
+		h.handleRole(client, command)
+		return
+	}
+
+	if h.Access == nil && command[0] != "/kick" && command[0] != "/slowmode" {
+		client.Send <- serverNotice("Access control is not enabled on this server")
+		return
+	}
+
+	switch command[0] {
+	case "/slowmode":
+		h.handleSlowMode(client, command)
+
+	case "/kick":
+		if len(command) < 2 {
+			client.Send <- usageNotice("/kick <username> [reason]")
+			return
+		}
+
+		reason := strings.Join(command[2:], " ")
+		if !h.Kick(command[1], kickMessage("kicked", client.Username, reason)) {
+			client.Send <- serverNotice(fmt.Sprintf("User %s is not online", command[1]))
+			return
+		}
+		h.Audit.Record(audit.EventKick, client.Username, command[1], remoteHost(client.Conn), reason)
+		client.Send <- serverNotice(fmt.Sprintf("Kicked %s", command[1]))
+
+	case "/ban":
+		if len(command) < 2 {
+			client.Send <- usageNotice("/ban <username> [duration] [reason]")
+			return
+		}
+
+		duration, reason := parseBanArgs(command[2:])
+		if err := h.Access.BanUser(command[1], client.Username, reason, duration); err != nil {
+			client.Send <- serverNotice(fmt.Sprintf("Error banning %s: %v", command[1], err))
+			return
+		}
+
+		client.Log.Info("User banned", "target", command[1], "duration", formatDuration(duration), "reason", reason)
+		h.Audit.Record(audit.EventBan, client.Username, command[1], remoteHost(client.Conn), banDetail(duration, reason))
+		h.Kick(command[1], kickMessage("banned", client.Username, reason))
+		client.Send <- serverNotice(fmt.Sprintf("Banned %s (%s)", command[1], formatDuration(duration)))
+
+	case "/unban":
+		if len(command) < 2 {
+			client.Send <- usageNotice("/unban <username>")
+			return
+		}
+
+		if err := h.Access.UnbanUser(command[1]); err != nil {
+			client.Send <- serverNotice(fmt.Sprintf("Error unbanning %s: %v", command[1], err))
+			return
+		}
+		client.Log.Info("User unbanned", "target", command[1])
+		h.Audit.Record(audit.EventUnban, client.Username, command[1], remoteHost(client.Conn), "")
+		client.Send <- serverNotice(fmt.Sprintf("Unbanned %s", command[1]))
+
+	case "/banip":
+		if len(command) < 2 {
+			client.Send <- usageNotice("/banip <ip|cidr> [duration] [reason]")
+			return
+		}
+
+		duration, reason := parseBanArgs(command[2:])
+		network, err := h.Access.BanIP(command[1], client.Username, reason, duration)
+		if err != nil {
+			client.Send <- serverNotice(fmt.Sprintf("Error banning %s: %v", command[1], err))
+			return
+		}
+
+		client.Log.Info("Address banned", "target", network.String(), "duration", formatDuration(duration), "reason", reason)
+		h.Audit.Record(audit.EventBan, client.Username, network.String(), remoteHost(client.Conn), banDetail(duration, reason))
+		kicked := h.KickNetwork(network, kickMessage("banned", client.Username, reason))
+		client.Send <- serverNotice(fmt.Sprintf("Banned %s (%s), disconnected %d client(s)", network, formatDuration(duration), kicked))
+
+	case "/unbanip":
+		if len(command) < 2 {
+			client.Send <- usageNotice("/unbanip <ip|cidr>")
+			return
+		}
+
+		if err := h.Access.UnbanIP(command[1]); err != nil {
+			client.Send <- serverNotice(fmt.Sprintf("Error unbanning %s: %v", command[1], err))
+			return
+		}
+		client.Log.Info("Address unbanned", "target", command[1])
+		h.Audit.Record(audit.EventUnban, client.Username, command[1], remoteHost(client.Conn), "")
+		client.Send <- serverNotice(fmt.Sprintf("Unbanned %s", command[1]))
+
+	case "/bans":
+		var sb strings.Builder
+		sb.WriteString("Active bans:")
+		for _, ban := range h.Access.UserBans() {
+			sb.WriteString(fmt.Sprintf("\n  user %s", describeBan(ban.Target, ban.Expires, ban.Reason)))
+		}
+		for _, ban := range h.Access.IPBans() {
+			sb.WriteString(fmt.Sprintf("\n  ip   %s", describeBan(ban.Target, ban.Expires, ban.Reason)))
+		}
+		client.Send <- serverNotice(sb.String())
+
+	case "/reloadacl":
+		if err := h.Access.Reload(); err != nil {
+			client.Send <- serverNotice(fmt.Sprintf("Error reloading access list: %v", err))
+			return
+		}
+		client.Log.Info("Access list reloaded")
+		client.Send <- serverNotice("Access list reloaded")
+	}
+}
+
+func (h *Handler) handleLogLevel(client *shared.Client, command []string) {
+	if len(command) < 2 {
+		client.Send <- serverNotice("Log level is " + logging.CurrentLevel())
+		return
+	}
+
+	if err := logging.SetLevel(command[1]); err != nil {
+		client.Send <- serverNotice(fmt.Sprintf("Error changing log level: %v", err))
+		return
+	}
+
+	client.Log.Info("Log level changed", "level", logging.CurrentLevel())
+	client.Send <- serverNotice("Log level set to " + logging.CurrentLevel())
+}
+
+func (h *Handler) handleRole(client *shared.Client, command []string) {
+	if len(command) < 3 {
+		client.Send <- usageNotice("/role <username> <user|moderator|admin>")
+		return
+	}
+
+	role, err := auth.ParseRole(command[2])
+	if err != nil {
+		client.Send <- serverNotice(fmt.Sprintf("Error changing role: %v", err))
+		return
+	}
+
+	if command[1] == client.Username && role != auth.RoleAdmin {
+		client.Send <- serverNotice("You cannot remove your own admin role")
+		return
+	}
+
+	previous := h.AuthManager.GetRole(command[1])
+	h.AuthManager.SetRole(command[1], role)
+
+	client.Log.Info("Role changed", "target", command[1], "from", previous.String(), "to", role.String())
+	h.Audit.Record(audit.EventRoleChange, client.Username, command[1], remoteHost(client.Conn), previous.String()+" -> "+role.String())
+	client.Send <- serverNotice(fmt.Sprintf("%s is now %s", command[1], role))
+}
+
+func (h *Handler) handleSlowMode(client *shared.Client, command []string) {
+	if len(command) < 3 {
+		client.Send <- usageNotice("/slowmode <room> <seconds>")
+		return
+	}
+
+	seconds, err := strconv.Atoi(command[2])
+	if err != nil || seconds < 0 {
+		client.Send <- serverNotice("Slow mode interval must be a non-negative number of seconds")
+		return
+	}
+
+	r, exists := h.RoomManager.GetRoom(command[1])
+	if !exists {
+		client.Send <- serverNotice(fmt.Sprintf("Room %s does not exist", command[1]))
+		return
+	}
+
+	r.SetSlowMode(time.Duration(seconds) * time.Second)
+
+	announcement := fmt.Sprintf("Slow mode disabled by %s", client.Username)
+	if seconds > 0 {
+		announcement = fmt.Sprintf("Slow mode enabled by %s: one message every %d seconds", client.Username, seconds)
+	}
+
+	h.RoomManager.BroadcastToRoom(r.Name, shared.Message{
+		Type:     shared.TextMessage,
+		Sender:   "Server",
+		RoomName: r.Name,
+		Content:  announcement,
+	})
+}
+
+// Kick disconnects a logged-in user after telling them why.
+func (h *Handler) Kick(username, reason string) bool {
+	h.mu.RLock()
+	target, exists := h.Clients[username]
+	h.mu.RUnlock()
+
+	if !exists || target.Username == "" {
+		return false
+	}
+
+	target.Log.Info("Kicking user", "reason", reason)
+	writeDirect(target.Conn, reason)
+	target.Conn.Close()
+	return true
+}
+
+// KickNetwork disconnects every client, logged in or not, whose remote
+// address falls inside network.
+func (h *Handler) KickNetwork(network *net.IPNet, reason string) int {
+	h.mu.RLock()
+	var targets []*shared.Client
+	for _, c := range h.Clients {
+		host, _, err := net.SplitHostPort(c.Conn.RemoteAddr().String())
+		if err != nil {
+			continue
+		}
+		if ip := net.ParseIP(host); ip != nil && network.Contains(ip) {
+			targets = append(targets, c)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, c := range targets {
+		writeDirect(c.Conn, reason)
+		c.Conn.Close()
+	}
+	return len(targets)
+}
+
+func kickMessage(action, by, reason string) string {
+	msg := fmt.Sprintf(shared.ReplyKicked, action, by)
+	if reason != "" {
+		msg += ": " + reason
+	}
+	return msg
+}
+
+// parseBanArgs treats a leading Go duration ("30m", "24h") as the ban length
+// and everything else as the reason. Without a duration the ban is permanent.
+func parseBanArgs(args []string) (time.Duration, string) {
+	if len(args) == 0 {
+		return 0, ""
+	}
+
+	duration, err := time.ParseDuration(args[0])
+	if err != nil || duration < 0 {
+		return 0, strings.Join(args, " ")
+	}
+	return duration, strings.Join(args[1:], " ")
+}
+
+func formatDuration(d time.Duration) string {
+	if d <= 0 {
+		return "permanent"
+	}
+	return d.String()
+}
+
+func banDetail(duration time.Duration, reason string) string {
+	if reason == "" {
+		return formatDuration(duration)
+	}
+	return formatDuration(duration) + ": " + reason
+}
+
+func describeBan(target string, expires time.Time, reason string) string {
+	desc := target
+	if expires.IsZero() {
+		desc += " (permanent)"
+	} else {
+		desc += " (until " + expires.Format(time.RFC1123) + ")"
+	}
+	if reason != "" {
+		desc += " - " + reason
+	}
+	return desc
+}
@@ -0,0 +1,47 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/imaneimrh/TCP-Chat_Server/audit"
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
+)
+
+// peerLogin logs in a connection whose peer the operating system has already
+// identified, so no password is asked for. Bans and duplicate sessions are
+// still refused.
+func (h *Handler) peerLogin(client *shared.Client, username string) error {
+	if h.AuthManager.Reserved(username) {
+		return fmt.Errorf("username '%s' is reserved", username)
+	}
+
+	if h.Access != nil {
+		if err := h.Access.CheckUser(username); err != nil {
+			authFailures.With("banned").Inc()
+			h.Audit.Record(audit.EventLoginFailure, username, username, remoteHost(client.Conn), err.Error())
+			return fmt.Errorf("you are %v", err)
+		}
+	}
+
+	if h.isLoggedIn(username, client) {
+		return fmt.Errorf("user '%s' is already logged in", username)
+	}
+
+	client.Username = username
+	client.Log = client.Log.With("user", username)
+	client.Log.Info("User logged in with peer credentials")
+	h.Audit.Record(audit.EventLogin, username, username, remoteHost(client.Conn), "peer credentials")
+	return nil
+}
+
+func (h *Handler) isLoggedIn(username string, except *shared.Client) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, c := range h.Clients {
+		if c.Username == username && c != except {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
+)
+
+// PostAs posts text to a room with sender as its author. Plugins and
+// incoming webhooks post this way; unlike user messages it does not trigger
+// plugin message hooks, so bots cannot set each other off in a loop. Bots
+// follow the same rules for @room and @here as users.
+func (h *Handler) PostAs(sender, roomName, text string) error {
+	message := shared.Message{
+		Type:     shared.TextMessage,
+		Sender:   sender,
+		RoomName: roomName,
+		Content:  text,
+	}
+	if err := h.checkMentions(message); err != nil {
+		return err
+	}
+	return h.RoomManager.BroadcastToRoom(roomName, message)
+}
+
+// DirectAs sends a direct message from sender to a logged-in user.
+func (h *Handler) DirectAs(sender, username, text string) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	recipient, exists := h.Clients[username]
+	if !exists || recipient.Username == "" {
+		return fmt.Errorf("user %s is not online", username)
+	}
+
+	select {
+	case recipient.Send <- shared.Message{
+		Type:      shared.TextMessage,
+		Sender:    sender,
+		Recipient: username,
+		Content:   text,
+	}:
+		return nil
+	default:
+		return fmt.Errorf("send queue for %s is full", username)
+	}
+}
+
+func (h *Handler) Rooms() []string {
+	return h.RoomManager.ListRooms()
+}
+
+// pluginHelp lists plugin commands in the same style as /help, or returns
+// "" when there are none.
+func (h *Handler) pluginHelp() string {
+	commands := h.Plugins.Commands()
+	if len(commands) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("╔══════════════════════════════════════════════════════════════╗\n")
+	sb.WriteString("║                    Plugin Commands                           ║\n")
+	sb.WriteString("╠══════════════════════════════════════════════════════════════╣\n")
+	for _, cmd := range commands {
+		usage := cmd.Usage
+		if usage == "" {
+			usage = cmd.Name
+		}
+		sb.WriteString(fmt.Sprintf("║   %-32s- %-25s║\n", usage, cmd.Description))
+	}
+	sb.WriteString("╚══════════════════════════════════════════════════════════════╝")
+	return sb.String()
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
+)
+
+type ProtocolLimits struct {
+	MaxFrameSize    int
+	MaxContentSize  int
+	MaxFileNameSize int
+	MaxFileDataSize int
+}
+
+func DefaultProtocolLimits() ProtocolLimits {
+	return ProtocolLimits{
+		MaxFrameSize:    64 * 1024,
+		MaxContentSize:  8 * 1024,
+		MaxFileNameSize: 255,
+		MaxFileDataSize: 4 * MaxChunkSize,
+	}
+}
+
+type ProtocolError struct {
+	Reason string
+}
+
+func (e *ProtocolError) Error() string {
+	return "protocol error: " + e.Reason
+}
+
+func ValidateMessage(msg shared.Message, limits ProtocolLimits) error {
+	if limits.MaxContentSize > 0 && len(msg.Content) > limits.MaxContentSize {
+		return &ProtocolError{Reason: fmt.Sprintf("message content exceeds %d bytes", limits.MaxContentSize)}
+	}
+
+	switch msg.Type {
+	case shared.EditMessage, shared.DeleteMessage, shared.HistoryMessage,
+		shared.ReactionMessage, shared.MentionMessage, shared.ReadMessage:
+		return &ProtocolError{Reason: fmt.Sprintf("%s frames are sent by the server only", msg.Type)}
+	}
+	if msg.Notice != nil {
+		return &ProtocolError{Reason: "notices are sent by the server only"}
+	}
+
+	isFileMessage := msg.Type == shared.FileTransferRequest ||
+		msg.Type == shared.FileTransferData ||
+		msg.Type == shared.FileTransferComplete
+	if !isFileMessage {
+		return nil
+	}
+
+	if limits.MaxFileNameSize > 0 && len(msg.FileName) > limits.MaxFileNameSize {
+		return &ProtocolError{Reason: fmt.Sprintf("file name exceeds %d bytes", limits.MaxFileNameSize)}
+	}
+
+	if msg.FileName == "" || msg.FileName == "." || msg.FileName == ".." ||
+		strings.ContainsAny(msg.FileName, "/\\\x00") {
+		return &ProtocolError{Reason: "invalid file name"}
+	}
+
+	if limits.MaxFileDataSize > 0 && len(msg.FileData) > limits.MaxFileDataSize {
+		return &ProtocolError{Reason: fmt.Sprintf("file chunk exceeds %d bytes", limits.MaxFileDataSize)}
+	}
+
+	if msg.FileSize < 0 || msg.FileOffset < 0 || msg.FileOffset+len(msg.FileData) > msg.FileSize {
+		return &ProtocolError{Reason: "invalid file size or offset"}
+	}
+
+	return nil
+}
+
+// writeDirect writes a final message straight to the connection, bypassing
+// the Send queue, so that it reaches the client before the connection is closed.
+func writeDirect(conn shared.MessageConn, content string) {
+	data, err := FormatMessage(shared.Message{
+		Type:    shared.TextMessage,
+		Sender:  "Server",
+		Content: content,
+	})
+	if err != nil {
+		return
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	conn.WriteFrame(data)
+}
+
+func remoteHost(conn shared.MessageConn) string {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return conn.RemoteAddr().String()
+	}
+	return host
+}
@@ -0,0 +1,218 @@
+package client
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/imaneimrh/TCP-Chat_Server/ratelimit"
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
+)
+
+type RateLimits struct {
+	MessagesPerSecond     float64
+	MessageBurst          int
+	CommandsPerSecond     float64
+	CommandBurst          int
+	BytesPerSecond        float64
+	ByteBurst             int
+	ChunksPerSecond       float64
+	ChunkBurst            int
+	WarningsBeforeMute    int
+	MuteDuration          time.Duration
+	MutesBeforeDisconnect int
+}
+
+func DefaultRateLimits() RateLimits {
+	return RateLimits{
+		MessagesPerSecond:     5,
+		MessageBurst:          10,
+		CommandsPerSecond:     5,
+		CommandBurst:          10,
+		BytesPerSecond:        2 << 20,
+		ByteBurst:             4 << 20,
+		ChunksPerSecond:       256,
+		ChunkBurst:            512,
+		WarningsBeforeMute:    3,
+		MuteDuration:          30 * time.Second,
+		MutesBeforeDisconnect: 3,
+	}
+}
+
+type rateDecision int
+
+const (
+	rateAllow rateDecision = iota
+	rateDrop
+	rateDisconnect
+)
+
+// limiterSweepInterval is how often idle per-user limiters are dropped.
+const limiterSweepInterval = time.Minute
+
+type limiterSet struct {
+	messages *ratelimit.Bucket
+	commands *ratelimit.Bucket
+	bytes    *ratelimit.Bucket
+	chunks   *ratelimit.Bucket
+}
+
+func newLimiterSet(limits RateLimits) *limiterSet {
+	return &limiterSet{
+		messages: ratelimit.NewBucket(limits.MessagesPerSecond, limits.MessageBurst),
+		commands: ratelimit.NewBucket(limits.CommandsPerSecond, limits.CommandBurst),
+		bytes:    ratelimit.NewBucket(limits.BytesPerSecond, limits.ByteBurst),
+		chunks:   ratelimit.NewBucket(limits.ChunksPerSecond, limits.ChunkBurst),
+	}
+}
+
+// idle reports whether every bucket has refilled, so that the set can be
+// dropped and later recreated without giving its user anything back.
+func (s *limiterSet) idle() bool {
+	return s.messages.Full() && s.commands.Full() && s.bytes.Full() && s.chunks.Full()
+}
+
+// floodControl tracks how often a connection has exceeded its limits and
+// escalates from warnings to a temporary mute and finally a disconnect.
+type floodControl struct {
+	warnings   int
+	mutes      int
+	mutedUntil time.Time
+}
+
+type userLimiters struct {
+	sets      map[string]*limiterSet
+	lastSweep time.Time
+	mu        sync.Mutex
+}
+
+func (h *Handler) userLimiter(username string) *limiterSet {
+	h.limiters.mu.Lock()
+	defer h.limiters.mu.Unlock()
+
+	if now := time.Now(); now.Sub(h.limiters.lastSweep) >= limiterSweepInterval {
+		h.limiters.lastSweep = now
+		for name, set := range h.limiters.sets {
+			if set.idle() {
+				delete(h.limiters.sets, name)
+			}
+		}
+	}
+
+	set, exists := h.limiters.sets[username]
+	if !exists {
+		set = newLimiterSet(h.Limits)
+		h.limiters.sets[username] = set
+	}
+	return set
+}
+
+func (h *Handler) checkRateLimits(client *shared.Client, conn *limiterSet, flood *floodControl, line []byte) rateDecision {
+	if client.Username != "" && h.AuthManager.IsAdmin(client.Username) {
+		return rateAllow
+	}
+
+	sets := []*limiterSet{conn}
+	if client.Username != "" {
+		sets = append(sets, h.userLimiter(client.Username))
+	}
+
+	// Bytes are throttled by delaying the reader rather than dropping frames,
+	// so that file transfers slow down instead of being corrupted.
+	var wait time.Duration
+	for _, set := range sets {
+		if d := set.bytes.Reserve(len(line)); d > wait {
+			wait = d
+		}
+	}
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+
+	isCommand := len(line) > 0 && line[0] == '/'
+
+	// File chunks are delayed like bytes rather than dropped. The offer that
+	// starts a transfer is charged as a message below.
+	if !isCommand && isFileChunk(line) {
+		wait = 0
+		for _, set := range sets {
+			if d := set.chunks.Reserve(1); d > wait {
+				wait = d
+			}
+		}
+		if wait > 0 {
+			time.Sleep(wait)
+		}
+		return rateAllow
+	}
+
+	now := time.Now()
+	if !isCommand && now.Before(flood.mutedUntil) {
+		return rateDrop
+	}
+
+	allowed := true
+	for _, set := range sets {
+		bucket := set.messages
+		if isCommand {
+			bucket = set.commands
+		}
+		if !bucket.Allow() {
+			allowed = false
+		}
+	}
+
+	if allowed {
+		return rateAllow
+	}
+
+	flood.warnings++
+	if flood.warnings <= h.Limits.WarningsBeforeMute {
+		client.Send <- shared.Message{
+			Type:    shared.TextMessage,
+			Sender:  "Server",
+			Content: fmt.Sprintf("You are sending messages too quickly. Please slow down (warning %d of %d).", flood.warnings, h.Limits.WarningsBeforeMute),
+		}
+		return rateDrop
+	}
+
+	flood.warnings = 0
+	flood.mutes++
+	if flood.mutes > h.Limits.MutesBeforeDisconnect {
+		return rateDisconnect
+	}
+
+	flood.mutedUntil = now.Add(h.Limits.MuteDuration)
+	client.Send <- shared.Message{
+		Type:    shared.TextMessage,
+		Sender:  "Server",
+		Content: fmt.Sprintf(shared.ReplyMuted, h.Limits.MuteDuration),
+	}
+	return rateDrop
+}
+
+func isFileChunk(line []byte) bool {
+	msgType, ok := frameType(line)
+	return ok && (msgType == shared.FileTransferData || msgType == shared.FileTransferComplete)
+}
+
+func (h *Handler) checkSlowMode(client *shared.Client, roomName string) bool {
+	if h.AuthManager.IsAdmin(client.Username) {
+		return true
+	}
+
+	r, exists := h.RoomManager.GetRoom(roomName)
+	if !exists {
+		return true
+	}
+
+	wait, ok := r.AllowPost(client.Username)
+	if !ok {
+		client.Send <- shared.Message{
+			Type:    shared.TextMessage,
+			Sender:  "Server",
+			Content: fmt.Sprintf(shared.ReplySlowMode, roomName, int(wait.Seconds())+1),
+		}
+	}
+	return ok
+}
@@ -0,0 +1,30 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/imaneimrh/TCP-Chat_Server/auth"
+	"github.com/imaneimrh/TCP-Chat_Server/room"
+)
+
+func TestIdleUserLimitersAreEvicted(t *testing.T) {
+	h := NewHandler(room.NewManager(), auth.NewManager())
+
+	h.userLimiter("idle")
+	busy := h.userLimiter("busy")
+	busy.messages.Allow()
+
+	h.limiters.lastSweep = time.Now().Add(-limiterSweepInterval)
+	h.userLimiter("new")
+
+	if _, exists := h.limiters.sets["idle"]; exists {
+		t.Error("idle limiter was kept")
+	}
+	if h.limiters.sets["busy"] != busy {
+		t.Error("limiter with tokens in use was evicted")
+	}
+	if _, exists := h.limiters.sets["new"]; !exists {
+		t.Error("new limiter was not stored")
+	}
+}
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
+)
+
+// maxReactionSize caps a reaction in bytes, enough for emoji built from
+// several code points and for short names such as :+1:.
+const maxReactionSize = 32
+
+// handleReaction gives (/react) or takes back (/unreact) a reaction to a
+// message in one of the caller's rooms.
+func (h *Handler) handleReaction(client *shared.Client, command []string) {
+	if client.Username == "" {
+		client.Send <- serverNotice(fmt.Sprintf(shared.ReplyLoginRequiredFor, command[0]))
+		return
+	}
+
+	if len(command) != 3 {
+		client.Send <- usageNotice(command[0] + " <id> <emoji>")
+		return
+	}
+
+	failed := shared.ReplyReactFailed
+	if command[0] == "/unreact" {
+		failed = shared.ReplyUnreactFailed
+	}
+
+	emoji := command[2]
+	if err := validReaction(emoji); err != nil {
+		client.Send <- serverNotice(fmt.Sprintf(failed, err))
+		return
+	}
+
+	r, _, exists := h.RoomManager.FindPost(command[1])
+	if !exists {
+		client.Send <- serverNotice(fmt.Sprintf(failed, noSuchMessage(command[1])))
+		return
+	}
+	if !client.IsInRoom(r.Name) {
+		client.Send <- serverNotice(fmt.Sprintf(shared.ReplyNotInRoom, r.Name, r.Name))
+		return
+	}
+
+	var err error
+	if command[0] == "/unreact" {
+		err = r.Unreact(command[1], client.Username, emoji)
+	} else {
+		err = r.React(command[1], client.Username, emoji)
+	}
+	if err != nil {
+		client.Send <- serverNotice(fmt.Sprintf(failed, err))
+	}
+}
+
+func validReaction(emoji string) error {
+	if len(emoji) > maxReactionSize || !utf8.ValidString(emoji) {
+		return fmt.Errorf("reactions are limited to %d bytes", maxReactionSize)
+	}
+	if strings.IndexFunc(emoji, unicode.IsControl) >= 0 {
+		return fmt.Errorf("reactions cannot contain control characters")
+	}
+	return nil
+}
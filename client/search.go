@@ -0,0 +1,86 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/imaneimrh/TCP-Chat_Server/search"
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
+)
+
+// handleSearch answers /search with a page of matching messages as
+// HistoryMessage frames, newest first, followed by a notice that ends the
+// listing. Each result is surrounded by the messages before and after it,
+// whose frames carry its ID as their Context. Users only find messages in
+// rooms they are in and direct messages they sent or received.
+func (h *Handler) handleSearch(client *shared.Client, line string) {
+	if client.Username == "" {
+		client.Send <- serverNotice(fmt.Sprintf(shared.ReplyLoginRequiredFor, "/search"))
+		return
+	}
+
+	if h.Search == nil {
+		client.Send <- serverNotice(shared.ReplySearchDisabled)
+		return
+	}
+
+	text := commandText(line, 1)
+	if text == "" {
+		client.Send <- usageNotice("/search <words> [in:<room>] [from:<user>] [before:<date>] [after:<date>] [page:<n>]")
+		return
+	}
+
+	q, err := search.ParseQuery(text)
+	if err != nil {
+		client.Send <- serverNotice(fmt.Sprintf(shared.ReplySearchFailed, err))
+		return
+	}
+	if q.Room != "" && !client.IsInRoom(q.Room) {
+		client.Send <- serverNotice(fmt.Sprintf(shared.ReplySearchFailed, fmt.Errorf("you are not in room %s", q.Room)))
+		return
+	}
+
+	page := h.Search.Search(q, func(doc search.Document) bool {
+		if doc.Room != "" {
+			return client.IsInRoom(doc.Room)
+		}
+		return doc.From == client.Username || doc.To == client.Username
+	})
+
+	for _, result := range page.Results {
+		for _, doc := range result.Before {
+			client.Send <- searchFrame(doc, result.ID)
+		}
+		client.Send <- searchFrame(result.Document, "")
+		for _, doc := range result.After {
+			client.Send <- searchFrame(doc, result.ID)
+		}
+	}
+
+	if page.Total == 0 {
+		client.Send <- serverNotice(shared.ReplyNoResults)
+		return
+	}
+
+	noun := "results"
+	if page.Total == 1 {
+		noun = "result"
+	}
+	more := ""
+	if page.Page < page.Pages {
+		more = fmt.Sprintf(" (add page:%d for more)", page.Page+1)
+	}
+	client.Send <- serverNotice(fmt.Sprintf(shared.ReplySearchPage, page.Total, noun, page.Page, page.Pages, more))
+}
+
+func searchFrame(doc search.Document, context string) shared.Message {
+	return shared.Message{
+		Type:      shared.HistoryMessage,
+		Sender:    doc.From,
+		Recipient: doc.To,
+		RoomName:  doc.Room,
+		Content:   doc.Text,
+		ID:        doc.ID,
+		ParentID:  doc.ParentID,
+		Context:   context,
+	}
+}
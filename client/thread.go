@@ -0,0 +1,186 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/imaneimrh/TCP-Chat_Server/room"
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
+)
+
+// threadRoot resolves the message a reply is aimed at to the message that
+// started its thread, so that threads stay one level deep.
+func threadRoot(r *room.Room, parentID string) (string, error) {
+	post, exists := r.Post(parentID)
+	switch {
+	case !exists:
+		return "", fmt.Errorf("message %s does not exist in %s", parentID, r.Name)
+	case post.Deleted:
+		return "", fmt.Errorf("message %s has been deleted", parentID)
+	case post.ParentID != "":
+		return post.ParentID, nil
+	}
+	return post.ID, nil
+}
+
+// handleReply posts a reply to the thread of an earlier message. line is
+// the raw command so that the text keeps its spacing.
+func (h *Handler) handleReply(client *shared.Client, command []string, line string) {
+	if client.Username == "" {
+		client.Send <- serverNotice(fmt.Sprintf(shared.ReplyLoginRequiredFor, "/reply"))
+		return
+	}
+
+	text := commandText(line, 2)
+	if len(command) < 3 || text == "" {
+		client.Send <- usageNotice("/reply <id> <text>")
+		return
+	}
+	if len(text) > h.Protocol.MaxContentSize {
+		client.Send <- serverNotice("Message is too long")
+		return
+	}
+
+	r, _, exists := h.RoomManager.FindPost(command[1])
+	if !exists {
+		client.Send <- serverNotice(fmt.Sprintf(shared.ReplyReplyFailed, noSuchMessage(command[1])))
+		return
+	}
+	if !client.IsInRoom(r.Name) {
+		client.Send <- serverNotice(fmt.Sprintf(shared.ReplyNotInRoom, r.Name, r.Name))
+		return
+	}
+
+	root, err := threadRoot(r, command[1])
+	if err != nil {
+		client.Send <- serverNotice(fmt.Sprintf(shared.ReplyReplyFailed, err))
+		return
+	}
+
+	if !h.checkSlowMode(client, r.Name) {
+		return
+	}
+
+	h.Broadcast <- shared.Message{
+		Type:     shared.TextMessage,
+		Sender:   client.Username,
+		RoomName: r.Name,
+		Content:  text,
+		ParentID: root,
+	}
+}
+
+// handleThread replays a thread as HistoryMessage frames, followed by a
+// notice that ends the listing.
+func (h *Handler) handleThread(client *shared.Client, command []string) {
+	if client.Username == "" {
+		client.Send <- serverNotice(fmt.Sprintf(shared.ReplyLoginRequiredFor, "/thread"))
+		return
+	}
+
+	if len(command) != 2 {
+		client.Send <- usageNotice("/thread <id>")
+		return
+	}
+
+	r, post, exists := h.RoomManager.FindPost(command[1])
+	if !exists {
+		client.Send <- serverNotice(fmt.Sprintf(shared.ReplyThreadFailed, noSuchMessage(command[1])))
+		return
+	}
+	if !client.IsInRoom(r.Name) {
+		client.Send <- serverNotice(fmt.Sprintf(shared.ReplyNotInRoom, r.Name, r.Name))
+		return
+	}
+
+	root := post.ID
+	if post.ParentID != "" {
+		root = post.ParentID
+	}
+
+	replies := 0
+	for _, p := range r.Thread(root) {
+		if p.ParentID != "" {
+			replies++
+		}
+		client.Send <- historyFrame(r.Name, p)
+	}
+
+	noun := "replies"
+	if replies == 1 {
+		noun = "reply"
+	}
+	client.Send <- serverNotice(fmt.Sprintf(shared.ReplyThread, root, r.Name, replies, noun))
+}
+
+// historyFrame replays a stored post with its reaction counts. Deleted
+// posts have no Content.
+func historyFrame(roomName string, post room.Post) shared.Message {
+	return shared.Message{
+		Type:      shared.HistoryMessage,
+		Sender:    post.Sender,
+		RoomName:  roomName,
+		Content:   post.Text,
+		ID:        post.ID,
+		ParentID:  post.ParentID,
+		Reactions: post.ReactionCounts(),
+	}
+}
+
+// RoomMessage makes the Handler a room.Observer. Posting marks the room read
+// for the sender. It tells everyone who took part in a thread about a new
+// reply when they are not in the room to see it themselves, and everyone a
+// message mentions. The notices are sent from another goroutine: the room's
+// goroutine must not wait for h.mu, which registerClient holds while
+// joining a room.
+func (h *Handler) RoomMessage(roomName string, message shared.Message) {
+	if message.Type != shared.TextMessage {
+		return
+	}
+	if message.ID != "" {
+		h.markers.advance(message.Sender, roomName, messageNumber(message.ID))
+	}
+	if message.ParentID != "" {
+		go h.notifyThread(roomName, message)
+	}
+	if message.ID != "" && strings.Contains(message.Content, "@") {
+		go h.notifyMentions(roomName, message)
+	}
+}
+
+func (h *Handler) notifyThread(roomName string, message shared.Message) {
+	r, exists := h.RoomManager.GetRoom(roomName)
+	if !exists {
+		return
+	}
+
+	participants := make(map[string]bool)
+	for _, post := range r.Thread(message.ParentID) {
+		participants[post.Sender] = true
+	}
+	delete(participants, message.Sender)
+
+	notice := shared.Message{
+		Type:     shared.TextMessage,
+		Sender:   "Server",
+		Content:  fmt.Sprintf(shared.ReplyThreadActivity, message.Sender, message.ParentID, roomName, message.Content),
+		ID:       message.ID,
+		ParentID: message.ParentID,
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for username := range participants {
+		participant, online := h.Clients[username]
+		if !online || participant.IsInRoom(roomName) {
+			continue
+		}
+
+		// Skip a full queue rather than hold h.mu for a slow client.
+		select {
+		case participant.Send <- notice:
+		default:
+		}
+	}
+}
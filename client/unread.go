@@ -0,0 +1,284 @@
+package client
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/imaneimrh/TCP-Chat_Server/room"
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
+)
+
+// maxInbox caps the direct messages remembered per conversation for unread
+// counts.
+const maxInbox = room.DefaultHistorySize
+
+// readMarkers holds, for each user, the ID of the last message they have
+// read in each room and direct conversation. Direct conversations are keyed
+// "@" followed by the other user. Rooms keep their own history, but direct
+// messages are not stored anywhere else, so the IDs of those each user
+// received are kept here too.
+type readMarkers struct {
+	mu      sync.Mutex
+	markers map[string]map[string]uint64
+	inbox   map[string]map[string][]uint64
+}
+
+func newReadMarkers() *readMarkers {
+	return &readMarkers{
+		markers: make(map[string]map[string]uint64),
+		inbox:   make(map[string]map[string][]uint64),
+	}
+}
+
+func (m *readMarkers) get(username, conversation string) (uint64, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id, exists := m.markers[username][conversation]
+	return id, exists
+}
+
+// set moves a marker, backwards too so that a conversation can be marked
+// unread from an earlier message.
+func (m *readMarkers) set(username, conversation string, id uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.markers[username] == nil {
+		m.markers[username] = make(map[string]uint64)
+	}
+	m.markers[username][conversation] = id
+}
+
+// start places a marker at id unless the user already has one, so that a
+// room's earlier history does not count as unread when they first join it.
+func (m *readMarkers) start(username, conversation string, id uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.markers[username] == nil {
+		m.markers[username] = make(map[string]uint64)
+	}
+	if _, exists := m.markers[username][conversation]; !exists {
+		m.markers[username][conversation] = id
+	}
+}
+
+// advance moves a marker forward to the user's own message. Senders that
+// never logged in, such as webhook bots, are ignored.
+func (m *readMarkers) advance(username, conversation string, id uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	markers := m.markers[username]
+	if markers != nil && markers[conversation] < id {
+		markers[conversation] = id
+	}
+}
+
+// received remembers a direct message to username.
+func (m *readMarkers) received(username, conversation string, id uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.inbox[username] == nil {
+		m.inbox[username] = make(map[string][]uint64)
+	}
+	ids := append(m.inbox[username][conversation], id)
+	if len(ids) > maxInbox {
+		ids = append(ids[:0:0], ids[len(ids)-maxInbox:]...)
+	}
+	m.inbox[username][conversation] = ids
+}
+
+// unreadDirect counts the direct messages received after marker and returns
+// the newest ID in the conversation.
+func (m *readMarkers) unreadDirect(username, conversation string, marker uint64) (int, uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := m.inbox[username][conversation]
+	if len(ids) == 0 {
+		return 0, 0
+	}
+	return len(ids) - sort.Search(len(ids), func(i int) bool { return ids[i] > marker }), ids[len(ids)-1]
+}
+
+// conversations lists the rooms and direct conversations a user has a
+// marker or direct messages in, sorted.
+func (m *readMarkers) conversations(username string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seen := make(map[string]bool)
+	for conversation := range m.markers[username] {
+		seen[conversation] = true
+	}
+	for conversation := range m.inbox[username] {
+		seen[conversation] = true
+	}
+
+	conversations := make([]string, 0, len(seen))
+	for conversation := range seen {
+		conversations = append(conversations, conversation)
+	}
+	sort.Strings(conversations)
+	return conversations
+}
+
+// messageNumber turns a message ID into a number that orders it. IDs that
+// are not numbers sort first.
+func messageNumber(id string) uint64 {
+	n, _ := strconv.ParseUint(id, 10, 64)
+	return n
+}
+
+// startReading places the user's marker in a room they have just joined.
+func (h *Handler) startReading(username, roomName string) {
+	if r, exists := h.RoomManager.GetRoom(roomName); exists {
+		h.markers.start(username, roomName, messageNumber(r.LastID()))
+	}
+}
+
+// unread counts the messages from others after a user's marker in a room or
+// direct conversation, and returns the newest message ID in it. ok is false
+// for rooms that no longer exist.
+func (h *Handler) unread(username, conversation string) (count int, last uint64, ok bool) {
+	marker, _ := h.markers.get(username, conversation)
+	if strings.HasPrefix(conversation, "@") {
+		count, last = h.markers.unreadDirect(username, conversation, marker)
+		return count, last, true
+	}
+
+	r, exists := h.RoomManager.GetRoom(conversation)
+	if !exists {
+		return 0, 0, false
+	}
+	for _, post := range r.History(0) {
+		last = messageNumber(post.ID)
+		if last > marker && post.Sender != username && !post.Deleted {
+			count++
+		}
+	}
+	return count, last, true
+}
+
+func readFrame(conversation string, marker uint64, unread int) shared.Message {
+	frame := shared.Message{
+		Type:   shared.ReadMessage,
+		Sender: "Server",
+		Unread: unread,
+	}
+	if marker > 0 {
+		frame.ID = strconv.FormatUint(marker, 10)
+	}
+	if peer, direct := strings.CutPrefix(conversation, "@"); direct {
+		frame.Recipient = peer
+	} else {
+		frame.RoomName = conversation
+	}
+	return frame
+}
+
+// unreadFrames builds a ReadMessage frame for every conversation of username
+// with unread messages, followed by a summary notice. At login nothing is
+// said when everything has been read.
+func (h *Handler) unreadFrames(username string, login bool) []shared.Message {
+	var frames []shared.Message
+	for _, conversation := range h.markers.conversations(username) {
+		count, _, ok := h.unread(username, conversation)
+		if !ok || count == 0 {
+			continue
+		}
+		marker, _ := h.markers.get(username, conversation)
+		frames = append(frames, readFrame(conversation, marker, count))
+	}
+
+	conversations := len(frames)
+	summary := fmt.Sprintf("%d conversations", conversations)
+	if conversations == 1 {
+		summary = "1 conversation"
+	}
+
+	switch {
+	case conversations == 0 && !login:
+		frames = append(frames, serverNotice(shared.ReplyNoUnread))
+	case conversations > 0 && login:
+		frames = append(frames, serverNotice(fmt.Sprintf("You have unread messages in %s. Use /unread to list them and /read to mark them read.", summary)))
+	case conversations > 0:
+		frames = append(frames, serverNotice(fmt.Sprintf(shared.ReplyUnread, summary)))
+	}
+	return frames
+}
+
+// handleUnread lists the conversations with unread messages.
+func (h *Handler) handleUnread(client *shared.Client) {
+	if client.Username == "" {
+		client.Send <- serverNotice(fmt.Sprintf(shared.ReplyLoginRequiredFor, "/unread"))
+		return
+	}
+	for _, frame := range h.unreadFrames(client.Username, false) {
+		client.Send <- frame
+	}
+}
+
+// handleRead moves the caller's read marker in one conversation, to the
+// newest message unless an ID is given, or in all of them, and sends back
+// the new marker.
+func (h *Handler) handleRead(client *shared.Client, command []string) {
+	if client.Username == "" {
+		client.Send <- serverNotice(fmt.Sprintf(shared.ReplyLoginRequiredFor, "/read"))
+		return
+	}
+
+	if len(command) > 3 {
+		client.Send <- usageNotice("/read [room|@user] [id]")
+		return
+	}
+
+	if len(command) == 1 {
+		for _, conversation := range h.markers.conversations(client.Username) {
+			count, last, ok := h.unread(client.Username, conversation)
+			if !ok || count == 0 {
+				continue
+			}
+			h.markers.set(client.Username, conversation, last)
+			client.Send <- readFrame(conversation, last, 0)
+		}
+		client.Send <- serverNotice(shared.ReplyAllRead)
+		return
+	}
+
+	conversation := command[1]
+	if peer, direct := strings.CutPrefix(conversation, "@"); direct {
+		if peer == "" || peer == client.Username {
+			client.Send <- serverNotice(fmt.Sprintf(shared.ReplyReadFailed, fmt.Errorf("invalid conversation '%s'", conversation)))
+			return
+		}
+	} else if _, marked := h.markers.get(client.Username, conversation); !marked && !client.IsInRoom(conversation) {
+		client.Send <- serverNotice(fmt.Sprintf(shared.ReplyReadFailed, fmt.Errorf("you are not in room %s", conversation)))
+		return
+	}
+
+	_, last, ok := h.unread(client.Username, conversation)
+	if !ok {
+		client.Send <- serverNotice(fmt.Sprintf(shared.ReplyReadFailed, fmt.Errorf("room %s does not exist", conversation)))
+		return
+	}
+
+	if len(command) == 3 {
+		id, err := strconv.ParseUint(command[2], 10, 64)
+		if err != nil {
+			client.Send <- serverNotice(fmt.Sprintf(shared.ReplyReadFailed, fmt.Errorf("invalid message ID '%s'", command[2])))
+			return
+		}
+		last = min(id, last)
+	}
+
+	h.markers.set(client.Username, conversation, last)
+	count, _, _ := h.unread(client.Username, conversation)
+	client.Send <- readFrame(conversation, last, count)
+}
@@ -2,38 +2,60 @@ package main
 
 import (
 	"bufio"
-	"encoding/json"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"io"
-	"net"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
-	"time"
 
+	"github.com/imaneimrh/TCP-Chat_Server/chatclient"
 	"github.com/imaneimrh/TCP-Chat_Server/shared"
 )
 
+func printUsage() {
+	fmt.Println("╔═══════════════════════════════════════════════════════╗")
+	fmt.Println("║              TCP Chat Client Usage                    ║")
+	fmt.Println("╠═══════════════════════════════════════════════════════╣")
+	fmt.Println("║ Usage: go run client.go [-tui] <host> <port>          ║")
+	fmt.Println("║ Example: go run client.go localhost 8080              ║")
+	fmt.Println("║                                                       ║")
+	fmt.Println("║ -tui  full-screen interface with a pane per room      ║")
+	fmt.Println("╚═══════════════════════════════════════════════════════╝")
+}
+
 func main() {
-	if len(os.Args) < 3 {
-		fmt.Println("╔═══════════════════════════════════════════════════════╗")
-		fmt.Println("║              TCP Chat Client Usage                    ║")
-		fmt.Println("╠═══════════════════════════════════════════════════════╣")
-		fmt.Println("║ Usage: go run client.go <host> <port>                 ║")
-		fmt.Println("║ Example: go run client.go localhost 8080              ║")
-		fmt.Println("╚═══════════════════════════════════════════════════════╝")
+	tuiMode := flag.Bool("tui", false, "full-screen terminal interface")
+	flag.Usage = printUsage
+	flag.Parse()
+
+	if flag.NArg() < 2 {
+		printUsage()
 		os.Exit(1)
 	}
 
-	host := os.Args[1]
-	port := os.Args[2]
+	host := flag.Arg(0)
+	port := flag.Arg(1)
 
-	conn, err := net.Dial("tcp", host+":"+port)
+	config := chatclient.DefaultConfig()
+	config.Reconnect = true
+
+	chat, err := chatclient.Connect(context.Background(), host+":"+port, config)
 	if err != nil {
 		fmt.Printf("Error connecting to server: %v\n", err)
 		os.Exit(1)
 	}
-	defer conn.Close()
+	defer chat.Close()
+
+	if *tuiMode {
+		if err := runTUI(chat, host+":"+port); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	fmt.Printf("╔═══════════════════════════════════════════════════════╗\n")
 	fmt.Printf("║              TCP Chat Client Connected                ║\n")
@@ -44,40 +66,10 @@ func main() {
 	fmt.Printf("╚═══════════════════════════════════════════════════════╝\n")
 
 	go func() {
-		reader := bufio.NewReader(conn)
-		for {
-			line, err := reader.ReadBytes('\n')
-			if err != nil {
-				if err == io.EOF {
-					fmt.Println("\n[Connection closed by server]")
-				} else {
-					fmt.Printf("\n[Error reading from server: %v]\n", err)
-				}
-				os.Exit(1)
-			}
-
-			var msg shared.Message
-			err = json.Unmarshal(line, &msg)
-			if err != nil {
-				fmt.Println(strings.TrimSpace(string(line)))
-				continue
-			}
-
-			if msg.Content == "PING" {
-				continue
-			}
-
-			if msg.Type == shared.TextMessage {
-				if msg.RoomName != "" && msg.Sender != "Server" {
-					fmt.Printf("\n[%s] %s: %s\n", msg.RoomName, msg.Sender, msg.Content)
-				} else {
-					fmt.Printf("\n%s: %s\n", msg.Sender, msg.Content)
-				}
-			} else if msg.Type == shared.FileTransferRequest {
-				fmt.Printf("\n[File Transfer Request] From %s: %s (%d bytes)\n",
-					msg.Sender, msg.FileName, msg.FileSize)
-			}
+		for event := range chat.Events() {
+			printEvent(event)
 		}
+		os.Exit(1)
 	}()
 
 	scanner := bufio.NewScanner(os.Stdin)
@@ -85,154 +77,15 @@ func main() {
 	for scanner.Scan() {
 		input := scanner.Text()
 
-		if strings.HasPrefix(input, "/file") {
-			parts := strings.Fields(input)
-			if len(parts) < 3 {
-				fmt.Println("Usage: /file <username> <filepath>")
-				fmt.Print("> ")
-				continue
-			}
-
-			recipient := parts[1]
-			filePath := parts[2]
-
-			fileInfo, err := os.Stat(filePath)
-			if err != nil {
-				fmt.Printf("Error accessing file: %v\n", err)
-				fmt.Print("> ")
-				continue
-			}
-
-			if fileInfo.IsDir() {
-				fmt.Println("Cannot send a directory, please specify a file")
-				fmt.Print("> ")
-				continue
-			}
-
-			fileRequest := shared.Message{
-				Type:      shared.FileTransferRequest,
-				Sender:    "",
-				Recipient: recipient,
-				FileName:  filepath.Base(filePath),
-				FileSize:  int(fileInfo.Size()),
-			}
-
-			data, err := json.Marshal(fileRequest)
-			if err != nil {
-				fmt.Printf("Error encoding file request: %v\n", err)
-				fmt.Print("> ")
-				continue
-			}
-			_, err = conn.Write(append(data, '\n'))
-			if err != nil {
-				fmt.Printf("Error sending file request: %v\n", err)
-				fmt.Print("> ")
-				continue
-			}
-
-			file, err := os.Open(filePath)
-			if err != nil {
-				fmt.Printf("Error opening file: %v\n", err)
-				fmt.Print("> ")
-				continue
-			}
-
-			fmt.Printf("\n[Sending file %s to %s...]\n", filepath.Base(filePath), recipient)
-
-			buffer := make([]byte, 8192)
-			offset := 0
-
-			var isLastChunk bool
-
-			for {
-				n, err := file.Read(buffer)
-				if err != nil && err != io.EOF {
-					fmt.Printf("Error reading file: %v\n", err)
-					break
-				}
-
-				if n == 0 {
-					break
-				}
-
-				isLastChunk = (err == io.EOF)
-
-				fileChunk := shared.Message{
-					Type:       shared.FileTransferData,
-					Sender:     "",
-					Recipient:  recipient,
-					FileName:   filepath.Base(filePath),
-					FileSize:   int(fileInfo.Size()),
-					FileData:   buffer[:n],
-					FileOffset: offset,
-				}
-
-				if isLastChunk {
-					fileChunk.Type = shared.FileTransferComplete
-				}
-
-				chunkData, err := json.Marshal(fileChunk)
-				if err != nil {
-					fmt.Printf("Error encoding file chunk: %v\n", err)
-					break
-				}
-				_, err = conn.Write(append(chunkData, '\n'))
-				if err != nil {
-					fmt.Printf("Error sending file chunk: %v\n", err)
-					break
-				}
-
-				offset += n
-
-				progress := float64(offset) / float64(fileInfo.Size()) * 100
-				progressBar := generateProgressBar(int(progress), 40)
-				fmt.Printf("\r[Progress: %.1f%% %s]", progress, progressBar)
-
-				if isLastChunk {
-					fmt.Println("\n[File transfer complete!]")
-					break
-				}
-
-				time.Sleep(10 * time.Millisecond)
-			}
-			if !isLastChunk {
-				completeMsg := shared.Message{
-					Type:       shared.FileTransferComplete,
-					Sender:     "",
-					Recipient:  recipient,
-					FileName:   filepath.Base(filePath),
-					FileSize:   int(fileInfo.Size()),
-					FileOffset: offset,
-				}
-
-				completeData, err := json.Marshal(completeMsg)
-				if err != nil {
-					fmt.Printf("Error encoding completion message: %v\n", err)
-				} else {
-					_, err = conn.Write(append(completeData, '\n'))
-					if err != nil {
-						fmt.Printf("Error sending completion message: %v\n", err)
-					}
-					time.Sleep(50 * time.Millisecond)
-				}
-			}
-
-			file.Close()
-			fmt.Print("> ")
-			continue
-		}
-
-		_, err := fmt.Fprintf(conn, "%s\n", input)
-		if err != nil {
-			fmt.Printf("Error sending message: %v\n", err)
-			break
-		}
-
 		if input == "/quit" {
 			fmt.Println("\nDisconnecting from chat server...")
 			break
 		}
 
+		if err := runInput(chat, input); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+
 		fmt.Print("> ")
 	}
 
@@ -241,6 +94,198 @@ func main() {
 	}
 }
 
+// runInput sends one line typed by the user. Commands the library tracks go
+// through its typed API so that a reconnect can restore them; everything
+// else is passed to the server as typed. Refusals are not returned because
+// the server's reply has already been printed.
+func runInput(chat *chatclient.Client, input string) error {
+	parts := strings.Fields(input)
+	ctx := context.Background()
+
+	var err error
+	switch {
+	case len(parts) == 0:
+		return nil
+
+	case parts[0] == "/file":
+		if len(parts) < 3 {
+			fmt.Println("Usage: /file <username> <filepath>")
+			return nil
+		}
+		err = sendFile(chat, parts[1], parts[2])
+
+	case parts[0] == "/login" && len(parts) == 3:
+		err = chat.Login(ctx, parts[1], parts[2])
+
+	case parts[0] == "/join" && len(parts) == 2:
+		err = chat.Join(ctx, parts[1])
+
+	case parts[0] == "/leave" && len(parts) == 2:
+		err = chat.Leave(ctx, parts[1])
+
+	default:
+		err = chat.Command(input)
+	}
+
+	var refused *chatclient.ServerError
+	if errors.As(err, &refused) {
+		return nil
+	}
+	return err
+}
+
+func sendFile(chat *chatclient.Client, recipient, filePath string) error {
+	fmt.Printf("\n[Sending file %s to %s...]\n", filepath.Base(filePath), recipient)
+
+	err := chat.SendFile(context.Background(), recipient, filePath, func(sent, total int64) {
+		progress := 100.0
+		if total > 0 {
+			progress = float64(sent) / float64(total) * 100
+		}
+		fmt.Printf("\r[Progress: %.1f%% %s]", progress, generateProgressBar(int(progress), 40))
+	})
+	if err != nil {
+		fmt.Println()
+		return err
+	}
+
+	fmt.Println("\n[File transfer complete!]")
+	return nil
+}
+
+func printEvent(event chatclient.Event) {
+	switch event.Kind {
+	case chatclient.EventDisconnected:
+		if event.Err != nil {
+			fmt.Printf("\n[Error reading from server: %v]\n", event.Err)
+		} else {
+			fmt.Println("\n[Connection closed by server]")
+		}
+		return
+
+	case chatclient.EventReconnected:
+		if event.Err != nil {
+			fmt.Printf("\n[Reconnected, but the session could not be restored: %v]\n", event.Err)
+		} else {
+			fmt.Println("\n[Reconnected]")
+		}
+		return
+
+	case chatclient.EventEdit:
+		fmt.Printf("\n[%s] * %s edited [%s]: %s\n", event.Room, event.From, event.ID, event.Text)
+		return
+
+	case chatclient.EventDelete:
+		fmt.Printf("\n[%s] * %s deleted [%s]\n", event.Room, event.From, event.ID)
+		return
+
+	case chatclient.EventReaction:
+		fmt.Printf("\n[%s] * %s reacted %s to [%s]%s\n", event.Room, event.From, event.Text, event.ID, reactions(event.Reactions))
+		return
+
+	case chatclient.EventUnreaction:
+		fmt.Printf("\n[%s] * %s took back %s on [%s]%s\n", event.Room, event.From, event.Text, event.ID, reactions(event.Reactions))
+		return
+
+	case chatclient.EventMessage:
+		// Replies are collapsed to one line; /thread shows them in full.
+		if event.Parent != "" {
+			fmt.Printf("\n[%s] %s↳ re [%s] %s: %s\n", event.Room, messageID(event.ID), event.Parent, event.From, preview(event.Text, 40))
+			return
+		}
+
+	case chatclient.EventHistory:
+		text := event.Text
+		if text == "" {
+			text = "(deleted)"
+		}
+		from := event.From
+		if event.Room == "" {
+			from += " → " + event.Message.Recipient
+		}
+		if event.Parent != "" {
+			fmt.Printf("    ↳ [%s] %s: %s%s\n", event.ID, from, text, reactions(event.Reactions))
+		} else {
+			fmt.Printf("\n  [%s] %s: %s%s\n", event.ID, from, text, reactions(event.Reactions))
+		}
+		return
+
+	case chatclient.EventRead:
+		conversation := event.Room
+		if conversation == "" {
+			conversation = "@" + event.From
+		}
+		if event.Unread > 0 {
+			fmt.Printf("\n[%s] %d unread after %s\n", conversation, event.Unread, messageID(event.ID))
+		} else {
+			fmt.Printf("\n[%s] marked read\n", conversation)
+		}
+		return
+
+	case chatclient.EventMention:
+		fmt.Printf("\n[@ mention in %s] %s%s: %s\n", event.Room, messageID(event.ID), event.From, event.Text)
+		return
+
+	case chatclient.EventThreadReply:
+		fmt.Printf("\n[thread [%s] in %s] %s: %s\n", event.Parent, event.Room, event.From, event.Text)
+		return
+	}
+
+	msg := event.Message
+	if msg.RoomName != "" && msg.Sender != shared.ServerName {
+		fmt.Printf("\n[%s] %s%s: %s\n", msg.RoomName, messageID(msg.ID), msg.Sender, msg.Content)
+	} else {
+		fmt.Printf("\n%s: %s\n", msg.Sender, msg.Content)
+	}
+}
+
+// messageID prefixes a room message with the ID that /edit and /delete
+// refer to it by.
+func messageID(id string) string {
+	if id == "" {
+		return ""
+	}
+	return "[" + id + "] "
+}
+
+// reactions summarises reaction counts as "  (👍 3 🎉 1)", most given
+// first.
+func reactions(counts map[string]int) string {
+	if len(counts) == 0 {
+		return ""
+	}
+
+	emoji := make([]string, 0, len(counts))
+	for e := range counts {
+		emoji = append(emoji, e)
+	}
+	sort.Slice(emoji, func(i, j int) bool {
+		if counts[emoji[i]] != counts[emoji[j]] {
+			return counts[emoji[i]] > counts[emoji[j]]
+		}
+		return emoji[i] < emoji[j]
+	})
+
+	parts := make([]string, len(emoji))
+	for i, e := range emoji {
+		parts[i] = fmt.Sprintf("%s %d", e, counts[e])
+	}
+	return "  (" + strings.Join(parts, " ") + ")"
+}
+
+// preview shortens text to one line of at most width characters.
+func preview(text string, width int) string {
+	text, _, cut := strings.Cut(text, "\n")
+	runes := []rune(text)
+	if len(runes) > width {
+		runes, cut = runes[:width], true
+	}
+	if cut {
+		return string(runes) + "…"
+	}
+	return text
+}
+
 func generateProgressBar(progress int, width int) string {
 	if progress < 0 {
 		progress = 0
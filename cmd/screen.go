@@ -0,0 +1,170 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+type keyCode int
+
+const (
+	keyNone keyCode = iota
+	keyRune
+	keyEnter
+	keyBackspace
+	keyDelete
+	keyTab
+	keyUp
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyPageUp
+	keyPageDown
+	keyKillLine
+	keyKillWord
+	keyNextPane
+	keyPrevPane
+	keyPane
+	keyRedraw
+	keyQuit
+	keyEOF
+)
+
+// key is one decoded keypress. r holds the character for keyRune and the
+// digit for keyPane.
+type key struct {
+	code keyCode
+	r    rune
+}
+
+var controlKeys = map[byte]keyCode{
+	'\r': keyEnter,
+	'\n': keyEnter,
+	'\t': keyTab,
+	0x7f: keyBackspace,
+	0x08: keyBackspace,
+	0x01: keyHome,     // Ctrl-A
+	0x05: keyEnd,      // Ctrl-E
+	0x15: keyKillLine, // Ctrl-U
+	0x17: keyKillWord, // Ctrl-W
+	0x0e: keyNextPane, // Ctrl-N
+	0x10: keyPrevPane, // Ctrl-P
+	0x0c: keyRedraw,   // Ctrl-L
+	0x03: keyQuit,     // Ctrl-C
+	0x04: keyEOF,      // Ctrl-D
+}
+
+var escapeKeys = map[string]keyCode{
+	"[A":  keyUp,
+	"[B":  keyDown,
+	"[C":  keyRight,
+	"[D":  keyLeft,
+	"[H":  keyHome,
+	"[F":  keyEnd,
+	"OH":  keyHome,
+	"OF":  keyEnd,
+	"[1~": keyHome,
+	"[4~": keyEnd,
+	"[7~": keyHome,
+	"[8~": keyEnd,
+	"[3~": keyDelete,
+	"[5~": keyPageUp,
+	"[6~": keyPageDown,
+}
+
+// decodeKeys turns what one read from a raw-mode terminal returned into
+// keypresses. Terminals send an escape sequence in a single write, so
+// sequences are not reassembled across reads.
+func decodeKeys(data []byte) []key {
+	var keys []key
+	for len(data) > 0 {
+		k, n := decodeKey(data)
+		data = data[n:]
+		if k.code != keyNone {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+func decodeKey(data []byte) (key, int) {
+	if data[0] == 0x1b {
+		return decodeEscape(data)
+	}
+	if code, ok := controlKeys[data[0]]; ok {
+		return key{code: code}, 1
+	}
+	if data[0] < 0x20 {
+		return key{}, 1
+	}
+
+	r, n := utf8.DecodeRune(data)
+	if r == utf8.RuneError {
+		return key{}, n
+	}
+	return key{code: keyRune, r: r}, n
+}
+
+func decodeEscape(data []byte) (key, int) {
+	if len(data) == 1 {
+		return key{}, 1
+	}
+
+	// Alt-1 through Alt-9 select a pane.
+	if data[1] >= '1' && data[1] <= '9' {
+		return key{code: keyPane, r: rune(data[1])}, 2
+	}
+	if data[1] != '[' && data[1] != 'O' {
+		return key{}, 1
+	}
+
+	for i := 2; i < len(data); i++ {
+		if data[i] >= 0x40 && data[i] <= 0x7e {
+			return key{code: escapeKeys[string(data[1:i+1])]}, i + 1
+		}
+	}
+	return key{}, len(data)
+}
+
+// sanitize keeps other users from sending escape sequences to the terminal.
+func sanitize(text string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n':
+			return r
+		case r == '\t':
+			return ' '
+		case r == '\r':
+			return -1
+		case r < 0x20, r == 0x7f, r >= 0x80 && r <= 0x9f:
+			return '?'
+		}
+		return r
+	}, text)
+}
+
+// wrap breaks line into rows of at most width characters.
+func wrap(line string, width int) []string {
+	runes := []rune(line)
+	if len(runes) == 0 {
+		return []string{""}
+	}
+
+	var rows []string
+	for len(runes) > width {
+		rows = append(rows, string(runes[:width]))
+		runes = runes[width:]
+	}
+	return append(rows, string(runes))
+}
+
+// fit truncates or pads text to exactly width characters.
+func fit(text string, width int) string {
+	runes := []rune(text)
+	if len(runes) > width {
+		return string(runes[:width])
+	}
+	return text + strings.Repeat(" ", width-len(runes))
+}
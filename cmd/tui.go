@@ -0,0 +1,836 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+
+	"github.com/imaneimrh/TCP-Chat_Server/chatclient"
+)
+
+const (
+	serverPane    = "server"
+	sidebarWidth  = 20
+	maxScrollback = 1000
+)
+
+var commandNames = []string{
+	"/ban", "/banip", "/bans", "/close", "/create", "/delete", "/edit", "/file",
+	"/help", "/join", "/kick", "/leave", "/list", "/login", "/loglevel", "/logout",
+	"/msg", "/quit", "/react", "/read", "/register", "/reloadacl", "/reply",
+	"/role", "/room", "/search", "/slowmode", "/thread", "/topic", "/unban",
+	"/unbanip", "/unreact", "/unread", "/users", "/whoami",
+}
+
+// Commands whose first argument is completed as a room or as a username.
+var (
+	roomArgCommands = map[string]bool{"/join": true, "/leave": true, "/topic": true, "/slowmode": true, "/room": true}
+	userArgCommands = map[string]bool{"/msg": true, "/file": true, "/kick": true, "/ban": true, "/unban": true, "/role": true}
+)
+
+// pane is the scrollback of one room ("#name"), one direct conversation
+// ("@user") or the server itself.
+type pane struct {
+	name   string
+	lines  []string
+	unread int
+	// mentioned is set when the user is mentioned in a pane they are not
+	// looking at.
+	mentioned bool
+	// seen is set when messages arrive while the pane is on screen, so
+	// that leaving it marks them read on the server.
+	seen bool
+	// scroll is how many lines the view has been moved up from the bottom.
+	scroll int
+}
+
+type completion struct {
+	start   int
+	matches []string
+	index   int
+}
+
+// tui owns all interface state. Everything runs on the goroutine in run;
+// other goroutines hand work back through actions.
+type tui struct {
+	chat   *chatclient.Client
+	server string
+	out    io.Writer
+
+	width  int
+	height int
+
+	panes  []*pane
+	active int
+
+	users map[string]bool
+	rooms map[string]bool
+
+	input      []rune
+	cursor     int
+	history    []string
+	histPos    int
+	completion *completion
+
+	connection string
+	transfer   string
+	actions    chan func()
+	done       bool
+}
+
+// runTUI takes over the terminal until the user quits.
+func runTUI(chat *chatclient.Client, server string) error {
+	in, out := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !term.IsTerminal(in) || !term.IsTerminal(out) {
+		return fmt.Errorf("-tui needs an interactive terminal")
+	}
+
+	state, err := term.MakeRaw(in)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(in, state)
+
+	// Switch to the alternate screen so the shell is left as it was.
+	fmt.Print("\x1b[?1049h")
+	defer fmt.Print("\x1b[?25h\x1b[?1049l")
+
+	t := &tui{
+		chat:       chat,
+		server:     server,
+		out:        os.Stdout,
+		users:      make(map[string]bool),
+		rooms:      map[string]bool{"general": true},
+		connection: "connected",
+		actions:    make(chan func()),
+	}
+	t.panes = []*pane{{name: serverPane}}
+	t.resize()
+	t.notice(t.panes[0], "Ctrl-N/Ctrl-P or Alt-1..9 switch panes, PgUp/PgDn scroll, Tab completes, Ctrl-C quits.")
+
+	t.run()
+	return nil
+}
+
+func (t *tui) run() {
+	keys := make(chan []byte)
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			keys <- append([]byte(nil), buf[:n]...)
+		}
+	}()
+
+	events := t.chat.Events()
+	resize := time.NewTicker(250 * time.Millisecond)
+	defer resize.Stop()
+
+	t.draw()
+	for !t.done {
+		select {
+		case data, ok := <-keys:
+			if !ok {
+				return
+			}
+			for _, k := range decodeKeys(data) {
+				t.handleKey(k)
+			}
+
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				t.connection = "disconnected"
+				t.notice(t.current(), "Connection closed. Press Ctrl-C to exit.")
+				break
+			}
+			t.handleEvent(event)
+
+		case action := <-t.actions:
+			action()
+
+		case <-resize.C:
+			if !t.resize() {
+				continue
+			}
+		}
+		t.draw()
+	}
+}
+
+func (t *tui) resize() bool {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || (width == t.width && height == t.height) {
+		return false
+	}
+	t.width, t.height = width, height
+	return true
+}
+
+func (t *tui) current() *pane {
+	return t.panes[t.active]
+}
+
+// pane returns the pane called name, opening it if needed.
+func (t *tui) pane(name string) *pane {
+	name = sanitize(name)
+	for _, p := range t.panes {
+		if p.name == name {
+			return p
+		}
+	}
+	p := &pane{name: name}
+	t.panes = append(t.panes, p)
+	return p
+}
+
+func (t *tui) switchTo(index int) {
+	if index < 0 {
+		index = len(t.panes) - 1
+	}
+	if index >= len(t.panes) {
+		index = 0
+	}
+	if leaving := t.current(); leaving.seen {
+		t.markRead(leaving)
+	}
+	t.active = index
+	if t.current().unread > 0 || t.current().mentioned {
+		t.markRead(t.current())
+	}
+	t.current().unread = 0
+	t.current().mentioned = false
+}
+
+// markRead moves the server's read marker for a room or direct message
+// pane, so that its count stays clear at the next login.
+func (t *tui) markRead(p *pane) {
+	p.seen = false
+	switch {
+	case strings.HasPrefix(p.name, "#"):
+		t.chat.Command("/read " + strings.TrimPrefix(p.name, "#"))
+	case strings.HasPrefix(p.name, "@"):
+		t.chat.Command("/read " + p.name)
+	}
+}
+
+func (t *tui) show(name string) {
+	p := t.pane(name)
+	for i := range t.panes {
+		if t.panes[i] == p {
+			t.switchTo(i)
+		}
+	}
+}
+
+func (t *tui) closePane(name string) {
+	for i, p := range t.panes {
+		if p.name != name || p.name == serverPane {
+			continue
+		}
+		t.panes = append(t.panes[:i], t.panes[i+1:]...)
+		if t.active >= i && t.active > 0 {
+			t.active--
+		}
+		return
+	}
+}
+
+// add appends text to p. Chat messages count as unread when p is not the
+// pane being looked at; notices do not.
+func (t *tui) add(p *pane, text string, message bool) {
+	stamp := time.Now().Format("15:04")
+	lines := strings.Split(sanitize(text), "\n")
+	for _, line := range lines {
+		p.lines = append(p.lines, stamp+" "+line)
+	}
+	if len(p.lines) > maxScrollback {
+		p.lines = p.lines[len(p.lines)-maxScrollback:]
+	}
+
+	// Keep a scrolled-back view where it is.
+	if p.scroll > 0 {
+		p.scroll = min(p.scroll+len(lines), len(p.lines)-1)
+	}
+	if message && p != t.current() {
+		p.unread++
+	} else if message {
+		p.seen = true
+	}
+}
+
+// notice adds a line from the server or the client itself. Boxed,
+// multi-line replies are added as they are so that they stay aligned.
+func (t *tui) notice(p *pane, text string) {
+	if !strings.Contains(text, "\n") {
+		text = "-- " + text
+	}
+	t.add(p, text, false)
+}
+
+func (t *tui) handleEvent(event chatclient.Event) {
+	switch event.Kind {
+	case chatclient.EventMessage:
+		t.rooms[event.Room] = true
+		t.users[event.From] = true
+		reply := ""
+		if event.Parent != "" {
+			reply = "↳[" + event.Parent + "] "
+		}
+		t.add(t.pane("#"+event.Room), fmt.Sprintf("%s%s<%s> %s", messageID(event.ID), reply, event.From, event.Text), true)
+
+	case chatclient.EventHistory:
+		text := event.Text
+		if text == "" {
+			text = "(deleted)"
+		}
+		indent := "   "
+		if event.Parent != "" {
+			indent = "     ↳ "
+		}
+		// Direct messages found by /search go to the other user's pane.
+		p := t.current()
+		switch {
+		case event.Room != "":
+			p = t.pane("#" + event.Room)
+		case event.From != t.chat.Username():
+			p = t.pane("@" + event.From)
+		case event.Message.Recipient != "":
+			p = t.pane("@" + event.Message.Recipient)
+		}
+		t.add(p, fmt.Sprintf("%s[%s] <%s> %s%s", indent, event.ID, event.From, text, reactions(event.Reactions)), false)
+
+	case chatclient.EventRead:
+		p := t.pane("@" + event.From)
+		if event.Room != "" {
+			p = t.pane("#" + event.Room)
+		}
+		if p != t.current() {
+			p.unread = event.Unread
+		}
+
+	case chatclient.EventMention:
+		// The message itself shows in the room's pane; point at it from
+		// any other.
+		if p := t.pane("#" + event.Room); p != t.current() {
+			p.mentioned = true
+			t.add(t.current(), fmt.Sprintf("-- %s mentioned you in #%s [%s]: %s", event.From, event.Room, event.ID, event.Text), true)
+		}
+
+	case chatclient.EventThreadReply:
+		t.add(t.current(), fmt.Sprintf("-- %s replied to thread [%s] in #%s: %s", event.From, event.Parent, event.Room, event.Text), true)
+
+	case chatclient.EventEdit:
+		t.add(t.pane("#"+event.Room), fmt.Sprintf("-- %s edited [%s]: %s", event.From, event.ID, event.Text), false)
+
+	case chatclient.EventDelete:
+		t.add(t.pane("#"+event.Room), fmt.Sprintf("-- %s deleted [%s]", event.From, event.ID), false)
+
+	case chatclient.EventReaction:
+		t.add(t.pane("#"+event.Room), fmt.Sprintf("-- %s reacted %s to [%s]%s", event.From, event.Text, event.ID, reactions(event.Reactions)), false)
+
+	case chatclient.EventUnreaction:
+		t.add(t.pane("#"+event.Room), fmt.Sprintf("-- %s took back %s on [%s]%s", event.From, event.Text, event.ID, reactions(event.Reactions)), false)
+
+	case chatclient.EventDirect:
+		t.users[event.From] = true
+		t.add(t.pane("@"+event.From), fmt.Sprintf("<%s> %s", event.From, event.Text), true)
+
+	case chatclient.EventJoin:
+		t.rooms[event.Room] = true
+		t.users[event.From] = true
+		t.add(t.pane("#"+event.Room), fmt.Sprintf("--> %s joined %s", event.From, event.Room), false)
+
+	case chatclient.EventLeave:
+		t.add(t.pane("#"+event.Room), fmt.Sprintf("<-- %s left %s", event.From, event.Room), false)
+
+	case chatclient.EventTopic:
+		t.add(t.pane("#"+event.Room), fmt.Sprintf("-- %s set the topic: %s", event.From, event.Text), false)
+
+	case chatclient.EventFileReceived:
+		t.add(t.current(), fmt.Sprintf("-- %s sent you %s; the server saved it to downloads/%s", event.From, event.File, event.File), true)
+
+	case chatclient.EventDisconnected:
+		t.connection = "reconnecting"
+		if event.Err != nil {
+			t.notice(t.current(), fmt.Sprintf("Lost connection: %v", event.Err))
+		} else {
+			t.notice(t.current(), "Connection closed by server")
+		}
+
+	case chatclient.EventReconnected:
+		t.connection = "connected"
+		if event.Err != nil {
+			t.notice(t.current(), fmt.Sprintf("Reconnected, but the session could not be restored: %v", event.Err))
+		} else {
+			t.notice(t.current(), "Reconnected")
+		}
+
+	default:
+		t.serverNotice(event)
+	}
+}
+
+func (t *tui) serverNotice(event chatclient.Event) {
+	msg := event.Message
+
+	if msg.Recipient != "" {
+		// The server confirms a direct message by echoing it to the sender.
+		if text, ok := strings.CutPrefix(msg.Content, "(To "+msg.Recipient+"): "); ok {
+			t.add(t.pane("@"+msg.Recipient), fmt.Sprintf("<%s> %s", t.chat.Username(), text), false)
+			return
+		}
+		// Copies of direct messages and per-chunk progress are shown elsewhere.
+		if strings.HasPrefix(msg.Content, "Direct message from ") || strings.HasPrefix(msg.Content, "File transfer progress:") {
+			return
+		}
+	}
+
+	t.learn(msg.Content)
+
+	p := t.current()
+	if event.Room != "" {
+		p = t.pane("#" + event.Room)
+	}
+	t.notice(p, msg.Content)
+}
+
+// learn collects usernames and room names from server replies for tab
+// completion.
+func (t *tui) learn(content string) {
+	switch {
+	case strings.Contains(content, "Online Users"):
+		for _, row := range boxRows(content) {
+			t.users[strings.TrimSuffix(row, " (you)")] = true
+		}
+	case strings.Contains(content, "Available Rooms"):
+		for _, row := range boxRows(content) {
+			t.rooms[strings.TrimSuffix(row, " (joined)")] = true
+		}
+	case strings.HasPrefix(content, "Room created: "):
+		t.rooms[strings.TrimPrefix(content, "Room created: ")] = true
+	case strings.HasSuffix(content, " has joined the server."):
+		t.users[strings.TrimSuffix(content, " has joined the server.")] = true
+	}
+}
+
+// boxRows returns the body rows of one of the server's boxed listings.
+func boxRows(content string) []string {
+	var rows []string
+	inBody := false
+	for _, line := range strings.Split(content, "\n") {
+		switch {
+		case strings.HasPrefix(line, "╠"):
+			inBody = true
+		case strings.HasPrefix(line, "╚"):
+			return rows
+		case inBody:
+			if row := strings.Trim(line, "║ "); row != "" {
+				rows = append(rows, row)
+			}
+		}
+	}
+	return rows
+}
+
+func (t *tui) handleKey(k key) {
+	if k.code != keyTab {
+		t.completion = nil
+	}
+
+	switch k.code {
+	case keyRune:
+		t.input = append(t.input[:t.cursor], append([]rune{k.r}, t.input[t.cursor:]...)...)
+		t.cursor++
+
+	case keyEnter:
+		line := string(t.input)
+		t.input, t.cursor = nil, 0
+		t.submit(line)
+
+	case keyBackspace:
+		if t.cursor > 0 {
+			t.input = append(t.input[:t.cursor-1], t.input[t.cursor:]...)
+			t.cursor--
+		}
+
+	case keyDelete:
+		if t.cursor < len(t.input) {
+			t.input = append(t.input[:t.cursor], t.input[t.cursor+1:]...)
+		}
+
+	case keyLeft:
+		t.cursor = max(t.cursor-1, 0)
+	case keyRight:
+		t.cursor = min(t.cursor+1, len(t.input))
+	case keyHome:
+		t.cursor = 0
+	case keyEnd:
+		t.cursor = len(t.input)
+
+	case keyKillLine:
+		t.input = append([]rune(nil), t.input[t.cursor:]...)
+		t.cursor = 0
+
+	case keyKillWord:
+		start := t.cursor
+		for start > 0 && t.input[start-1] == ' ' {
+			start--
+		}
+		for start > 0 && t.input[start-1] != ' ' {
+			start--
+		}
+		t.input = append(t.input[:start], t.input[t.cursor:]...)
+		t.cursor = start
+
+	case keyUp:
+		if t.histPos > 0 {
+			t.histPos--
+			t.input = []rune(t.history[t.histPos])
+			t.cursor = len(t.input)
+		}
+	case keyDown:
+		if t.histPos < len(t.history)-1 {
+			t.histPos++
+			t.input = []rune(t.history[t.histPos])
+		} else {
+			t.histPos = len(t.history)
+			t.input = nil
+		}
+		t.cursor = len(t.input)
+
+	case keyTab:
+		t.complete()
+
+	case keyPageUp:
+		p := t.current()
+		p.scroll = min(p.scroll+t.pageSize(), max(len(p.lines)-1, 0))
+	case keyPageDown:
+		p := t.current()
+		p.scroll = max(p.scroll-t.pageSize(), 0)
+
+	case keyNextPane:
+		t.switchTo(t.active + 1)
+	case keyPrevPane:
+		t.switchTo(t.active - 1)
+	case keyPane:
+		if index := int(k.r - '1'); index < len(t.panes) {
+			t.switchTo(index)
+		}
+
+	case keyQuit:
+		t.done = true
+	case keyEOF:
+		if len(t.input) == 0 {
+			t.done = true
+		}
+	}
+}
+
+func (t *tui) pageSize() int {
+	return max((t.height-2)/2, 1)
+}
+
+// submit handles a line entered in the input. Plain text goes to the pane
+// being looked at.
+func (t *tui) submit(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	t.history = append(t.history, line)
+	t.histPos = len(t.history)
+
+	p := t.current()
+	if !strings.HasPrefix(line, "/") {
+		t.say(p, line)
+		return
+	}
+
+	parts := strings.Fields(line)
+	switch {
+	case parts[0] == "/quit":
+		t.done = true
+
+	case parts[0] == "/close":
+		t.closePane(p.name)
+
+	case parts[0] == "/file" && len(parts) == 3:
+		t.sendFile(parts[1], parts[2])
+
+	case parts[0] == "/login" && len(parts) == 3:
+		t.async(func(ctx context.Context) error {
+			return t.chat.Login(ctx, parts[1], parts[2])
+		}, func() {
+			t.show("#general")
+		})
+
+	case parts[0] == "/join" && len(parts) == 2:
+		t.async(func(ctx context.Context) error {
+			return t.chat.Join(ctx, parts[1])
+		}, func() {
+			t.show("#" + parts[1])
+		})
+
+	case parts[0] == "/leave" && len(parts) <= 2:
+		roomName, ok := strings.CutPrefix(p.name, "#")
+		if len(parts) == 2 {
+			roomName, ok = parts[1], true
+		}
+		if !ok {
+			t.notice(p, "Usage: /leave <room>")
+			return
+		}
+		t.async(func(ctx context.Context) error {
+			return t.chat.Leave(ctx, roomName)
+		}, func() {
+			t.closePane("#" + roomName)
+		})
+
+	case parts[0] == "/msg" && len(parts) >= 3:
+		t.show("@" + parts[1])
+		t.say(t.current(), strings.Join(parts[2:], " "))
+
+	default:
+		if err := t.chat.Command(line); err != nil {
+			t.notice(p, fmt.Sprintf("Error: %v", err))
+		}
+	}
+}
+
+func (t *tui) say(p *pane, text string) {
+	switch {
+	case strings.HasPrefix(p.name, "#"):
+		if err := t.chat.Send(p.name[1:], text); err != nil {
+			t.notice(p, fmt.Sprintf("Error: %v", err))
+		}
+	case strings.HasPrefix(p.name, "@"):
+		username := p.name[1:]
+		t.async(func(ctx context.Context) error {
+			return t.chat.DM(ctx, username, text)
+		}, nil)
+	default:
+		t.notice(p, "Switch to a room or conversation to chat (Ctrl-N / Ctrl-P)")
+	}
+}
+
+// async runs a request that waits for the server off the UI goroutine, then
+// calls done on it if the request succeeded. Refusals are not repeated
+// because the server's reply is already on screen.
+func (t *tui) async(request func(context.Context) error, done func()) {
+	p := t.current()
+	go func() {
+		err := request(context.Background())
+		t.actions <- func() {
+			var refused *chatclient.ServerError
+			switch {
+			case err == nil:
+				if done != nil {
+					done()
+				}
+			case !errors.As(err, &refused):
+				t.notice(p, fmt.Sprintf("Error: %v", err))
+			}
+		}
+	}()
+}
+
+func (t *tui) sendFile(recipient, path string) {
+	name := filepath.Base(path)
+	p := t.current()
+	t.transfer = fmt.Sprintf("%s to %s: waiting", name, recipient)
+
+	go func() {
+		err := t.chat.SendFile(context.Background(), recipient, path, func(sent, total int64) {
+			percent := 100
+			if total > 0 {
+				percent = int(sent * 100 / total)
+			}
+			t.actions <- func() {
+				t.transfer = fmt.Sprintf("%s to %s %s %d%%", name, recipient, generateProgressBar(percent, 20), percent)
+			}
+		})
+
+		t.actions <- func() {
+			t.transfer = ""
+			var refused *chatclient.ServerError
+			switch {
+			case err == nil:
+				t.notice(p, fmt.Sprintf("Sent %s to %s", name, recipient))
+			case !errors.As(err, &refused):
+				t.notice(p, fmt.Sprintf("File transfer of %s failed: %v", name, err))
+			}
+		}
+	}()
+}
+
+func (t *tui) complete() {
+	if t.completion == nil {
+		start := t.cursor
+		for start > 0 && t.input[start-1] != ' ' {
+			start--
+		}
+		matches := t.candidates(strings.Fields(string(t.input[:start])), string(t.input[start:t.cursor]))
+		if len(matches) == 0 {
+			return
+		}
+		t.completion = &completion{start: start, matches: matches}
+	} else {
+		t.completion.index = (t.completion.index + 1) % len(t.completion.matches)
+	}
+
+	c := t.completion
+	replacement := []rune(c.matches[c.index] + " ")
+	rest := t.input[t.cursor:]
+
+	input := append([]rune(nil), t.input[:c.start]...)
+	input = append(input, replacement...)
+	t.input = append(input, rest...)
+	t.cursor = c.start + len(replacement)
+}
+
+// candidates lists completions of word, given the words before it.
+func (t *tui) candidates(before []string, word string) []string {
+	var pool []string
+	switch {
+	case len(before) == 0 && strings.HasPrefix(word, "/"):
+		pool = commandNames
+	case len(before) == 1 && roomArgCommands[before[0]]:
+		pool = keys(t.rooms)
+	case len(before) == 1 && userArgCommands[before[0]]:
+		pool = keys(t.users)
+	default:
+		pool = append(keys(t.users), keys(t.rooms)...)
+	}
+
+	var matches []string
+	for _, candidate := range pool {
+		if strings.HasPrefix(strings.ToLower(candidate), strings.ToLower(word)) {
+			matches = append(matches, candidate)
+		}
+	}
+	return matches
+}
+
+func keys(set map[string]bool) []string {
+	list := make([]string, 0, len(set))
+	for name := range set {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func (t *tui) status() string {
+	var parts []string
+
+	account := "not logged in"
+	if username := t.chat.Username(); username != "" {
+		account = username
+	}
+	parts = append(parts, fmt.Sprintf("%s | %s@%s", t.connection, account, t.server))
+
+	if t.transfer != "" {
+		parts = append(parts, t.transfer)
+	}
+	if t.current().scroll > 0 {
+		parts = append(parts, "scrolled back, PgDn for newer")
+	}
+	return " " + strings.Join(parts, " | ")
+}
+
+func (t *tui) sidebar() []string {
+	rows := make([]string, 0, len(t.panes))
+	for i, p := range t.panes {
+		label := fmt.Sprintf("%d %s", i+1, p.name)
+		if p.unread > 0 {
+			label = fmt.Sprintf("%s (%d)", label, p.unread)
+		}
+		if p.mentioned {
+			label += " @"
+		}
+		label = fit(label, sidebarWidth)
+
+		switch {
+		case i == t.active:
+			label = "\x1b[7m" + label + "\x1b[0m"
+		case p.mentioned:
+			label = "\x1b[1;33m" + label + "\x1b[0m"
+		case p.unread > 0:
+			label = "\x1b[1m" + label + "\x1b[0m"
+		}
+		rows = append(rows, label)
+	}
+	return rows
+}
+
+// view returns the rows of p that fit in width and height, bottom aligned.
+func (p *pane) view(width, height int) []string {
+	var rows []string
+	for i := len(p.lines) - 1 - p.scroll; i >= 0 && len(rows) < height; i-- {
+		wrapped := wrap(p.lines[i], width)
+		for j := len(wrapped) - 1; j >= 0 && len(rows) < height; j-- {
+			rows = append(rows, wrapped[j])
+		}
+	}
+
+	for len(rows) < height {
+		rows = append(rows, "")
+	}
+	for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+		rows[i], rows[j] = rows[j], rows[i]
+	}
+	return rows
+}
+
+// draw repaints the whole screen. Frames are small enough that diffing
+// against the previous one is not worth it.
+func (t *tui) draw() {
+	var b strings.Builder
+	b.WriteString("\x1b[?25l\x1b[H")
+
+	if t.width < sidebarWidth+20 || t.height < 5 {
+		b.WriteString("\x1b[2JTerminal too small")
+		io.WriteString(t.out, b.String())
+		return
+	}
+
+	body := t.height - 2
+	contentWidth := t.width - sidebarWidth - 1
+	side := t.sidebar()
+	rows := t.current().view(contentWidth, body)
+
+	for row := 0; row < body; row++ {
+		fmt.Fprintf(&b, "\x1b[%d;1H", row+1)
+		if row < len(side) {
+			b.WriteString(side[row])
+		} else {
+			b.WriteString(strings.Repeat(" ", sidebarWidth))
+		}
+		b.WriteString("│")
+		b.WriteString(fit(rows[row], contentWidth))
+	}
+
+	fmt.Fprintf(&b, "\x1b[%d;1H\x1b[7m%s\x1b[0m", t.height-1, fit(t.status(), t.width))
+
+	prompt := fmt.Sprintf("[%s] ", t.current().name)
+	available := max(t.width-len([]rune(prompt))-1, 1)
+	start := max(t.cursor-available, 0)
+	end := min(len(t.input), start+available)
+
+	fmt.Fprintf(&b, "\x1b[%d;1H%s%s\x1b[K", t.height, prompt, string(t.input[start:end]))
+	fmt.Fprintf(&b, "\x1b[%d;%dH\x1b[?25h", t.height, len([]rune(prompt))+t.cursor-start+1)
+
+	io.WriteString(t.out, b.String())
+}
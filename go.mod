@@ -2,4 +2,9 @@ module github.com/imaneimrh/TCP-Chat_Server
 
 go 1.23.4
 
-require golang.org/x/crypto v0.35.0  
+require (
+	golang.org/x/crypto v0.35.0
+	golang.org/x/term v0.29.0
+)
+
+require golang.org/x/sys v0.30.0 // indirect
@@ -0,0 +1,71 @@
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/imaneimrh/TCP-Chat_Server/client"
+	"github.com/imaneimrh/TCP-Chat_Server/room"
+	"github.com/imaneimrh/TCP-Chat_Server/server"
+)
+
+// Checker answers liveness and readiness probes for a supervisor.
+type Checker struct {
+	Server      *server.Server
+	Handler     *client.Handler
+	RoomManager *room.Manager
+	Timeout     time.Duration
+}
+
+func NewChecker(chatServer *server.Server, handler *client.Handler, roomManager *room.Manager) *Checker {
+	return &Checker{
+		Server:      chatServer,
+		Handler:     handler,
+		RoomManager: roomManager,
+		Timeout:     2 * time.Second,
+	}
+}
+
+// Ready succeeds once the listener is bound and the hub goroutines are running.
+func (c *Checker) Ready() error {
+	if !c.Server.Ready() {
+		return fmt.Errorf("listener is not bound")
+	}
+	if !c.Handler.Running() {
+		return fmt.Errorf("client handler is not running")
+	}
+	if !c.RoomManager.RoomsRunning() {
+		return fmt.Errorf("room loops are not running")
+	}
+	return nil
+}
+
+// Live succeeds when the client handler and every room loop acknowledge a
+// probe within Timeout, which catches a deadlocked hub.
+func (c *Checker) Live() error {
+	if err := c.Handler.Probe(c.Timeout); err != nil {
+		return err
+	}
+	return c.RoomManager.ProbeRooms(c.Timeout)
+}
+
+func (c *Checker) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /healthz", serveCheck(c.Live))
+	mux.HandleFunc("GET /readyz", serveCheck(c.Ready))
+}
+
+func serveCheck(check func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := check(); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]string{"status": "fail", "error": err.Error()})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	}
+}
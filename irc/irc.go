@@ -0,0 +1,757 @@
+package irc
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/imaneimrh/TCP-Chat_Server/client"
+	"github.com/imaneimrh/TCP-Chat_Server/room"
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
+)
+
+const (
+	rplWelcome        = "001"
+	rplYourHost       = "002"
+	rplCreated        = "003"
+	rplMyInfo         = "004"
+	rplISupport       = "005"
+	rplUModeIs        = "221"
+	rplEndOfWho       = "315"
+	rplWhoisUser      = "311"
+	rplEndOfWhois     = "318"
+	rplWhoisChannels  = "319"
+	rplISON           = "303"
+	rplListStart      = "321"
+	rplList           = "322"
+	rplListEnd        = "323"
+	rplChannelModeIs  = "324"
+	rplNoTopic        = "331"
+	rplTopic          = "332"
+	rplWhoReply       = "352"
+	rplNamReply       = "353"
+	rplEndOfNames     = "366"
+	rplEndOfBanList   = "368"
+	errNoSuchNick     = "401"
+	errNoSuchChannel  = "403"
+	errCannotSend     = "404"
+	errNoRecipient    = "411"
+	errNoTextToSend   = "412"
+	errUnknownCmd     = "421"
+	errNoMOTD         = "422"
+	errNoNickGiven    = "431"
+	errBadNick        = "432"
+	errNotOnChannel   = "442"
+	errNotRegistered  = "451"
+	errNeedMoreParam  = "461"
+	errAlreadyReg     = "462"
+	errPasswdMismatch = "464"
+)
+
+// Server accepts IRC connections and serves them through the chat handler,
+// so IRC users share rooms, direct messages, limits and bans with everyone
+// else. Channels map to rooms by their name without the leading '#'.
+type Server struct {
+	Addr        string
+	Name        string
+	Handler     *client.Handler
+	RoomManager *room.Manager
+	// Admit, when set, applies the chat server's access list and connection
+	// limits to each new connection.
+	Admit   func(addr net.Addr) (func(), error)
+	started time.Time
+}
+
+func NewServer(addr string, handler *client.Handler, roomManager *room.Manager) *Server {
+	return &Server{
+		Addr:        addr,
+		Name:        "chat",
+		Handler:     handler,
+		RoomManager: roomManager,
+	}
+}
+
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	s.started = time.Now()
+	slog.Info("IRC gateway listening", "addr", s.Addr)
+
+	for {
+		netConn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			slog.Error("Error accepting IRC connection", "error", err)
+			continue
+		}
+
+		release := func() {}
+		if s.Admit != nil {
+			release, err = s.Admit(netConn.RemoteAddr())
+			if err != nil {
+				go func() {
+					netConn.SetWriteDeadline(time.Now().Add(2 * time.Second))
+					io.WriteString(netConn, formatMessage("", "ERROR", err.Error()))
+					netConn.Close()
+				}()
+				continue
+			}
+		}
+
+		slog.Info("New IRC connection", "remote", netConn.RemoteAddr().String())
+		s.Handler.ServeConn(newConn(s, netConn, release))
+	}
+}
+
+// conn translates between IRC and the native protocol. ReadFrame turns IRC
+// commands into native frames, answering queries such as NAMES and WHO
+// itself, and WriteFrame turns native messages into IRC lines.
+type conn struct {
+	net.Conn
+	server  *Server
+	reader  *bufio.Reader
+	pending [][]byte
+	// nick is set by the reader goroutine and read by the writer, so it
+	// is only used through nickname and setNick.
+	nickMu     sync.Mutex
+	nick       string
+	user       string
+	pass       string
+	loginSent  bool
+	registered atomic.Bool
+	writeMu    sync.Mutex
+	closeOnce  sync.Once
+	release    func()
+}
+
+func newConn(server *Server, netConn net.Conn, release func()) *conn {
+	return &conn{
+		Conn:    netConn,
+		server:  server,
+		reader:  bufio.NewReader(netConn),
+		nick:    "*",
+		release: release,
+	}
+}
+
+func (c *conn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(c.release)
+	return err
+}
+
+func (c *conn) ReadFrame(maxSize int) ([]byte, error) {
+	for len(c.pending) == 0 {
+		line, err := client.ReadFrame(c.reader, maxSize)
+		if err != nil {
+			return nil, err
+		}
+
+		msg, ok := parseMessage(string(line))
+		if !ok {
+			continue
+		}
+
+		if err := c.handle(msg); err != nil {
+			return nil, err
+		}
+	}
+
+	frame := c.pending[0]
+	c.pending = c.pending[1:]
+	return frame, nil
+}
+
+// WriteFrame translates one native message. Frames that have no IRC
+// equivalent are dropped.
+func (c *conn) WriteFrame(frame []byte) error {
+	var msg shared.Message
+	if err := json.Unmarshal(frame, &msg); err != nil {
+		return nil
+	}
+
+	lines := c.translate(msg)
+	if len(lines) == 0 {
+		return nil
+	}
+	return c.writeLines(lines...)
+}
+
+func (c *conn) LoginSucceeded(username string) {
+	c.setNick(username)
+	c.registered.Store(true)
+
+	s := c.server
+	c.writeLines(
+		c.numeric(rplWelcome, fmt.Sprintf("Welcome to the %s IRC gateway %s", s.Name, c.hostmask(username))),
+		c.numeric(rplYourHost, fmt.Sprintf("Your host is %s", s.Name)),
+		c.numeric(rplCreated, fmt.Sprintf("This server was created %s", s.started.Format(time.RFC1123))),
+		c.numeric(rplMyInfo, s.Name, "tcp-chat", "o", "nt"),
+		c.numeric(rplISupport, "CHANTYPES=#&", "NETWORK="+s.Name, "are supported by this server"),
+		c.numeric(errNoMOTD, "MOTD File is missing"),
+	)
+}
+
+func (c *conn) LoginFailed(reason string) {
+	c.writeLines(
+		c.numeric(errPasswdMismatch, reason),
+		formatMessage("", "ERROR", "Closing link: "+reason),
+	)
+	c.Close()
+}
+
+func (c *conn) nickname() string {
+	c.nickMu.Lock()
+	defer c.nickMu.Unlock()
+	return c.nick
+}
+
+func (c *conn) setNick(nick string) {
+	c.nickMu.Lock()
+	defer c.nickMu.Unlock()
+	c.nick = nick
+}
+
+func (c *conn) hostmask(nick string) string {
+	return nick + "!" + nick + "@" + c.server.Name
+}
+
+func (c *conn) numeric(code string, params ...string) string {
+	return formatMessage(c.server.Name, code, append([]string{c.nickname()}, params...)...)
+}
+
+func (c *conn) writeLines(lines ...string) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	_, err := io.WriteString(c.Conn, strings.Join(lines, ""))
+	return err
+}
+
+func (c *conn) queue(frame []byte) {
+	c.pending = append(c.pending, frame)
+}
+
+func (c *conn) queueCommand(format string, args ...any) {
+	c.queue([]byte(fmt.Sprintf(format, args...)))
+}
+
+func (c *conn) queueMessage(msg shared.Message) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	c.queue(data)
+}
+
+func (c *conn) handle(msg message) error {
+	switch msg.command {
+	case "CAP":
+		if len(msg.params) > 0 && strings.ToUpper(msg.params[0]) == "LS" {
+			c.writeLines(formatMessage(c.server.Name, "CAP", "*", "LS", ""))
+		} else if len(msg.params) > 1 && strings.ToUpper(msg.params[0]) == "REQ" {
+			c.writeLines(formatMessage(c.server.Name, "CAP", "*", "NAK", msg.params[1]))
+		}
+		return nil
+
+	case "PASS":
+		if c.registered.Load() {
+			c.writeLines(c.numeric(errAlreadyReg, "You may not reregister"))
+			return nil
+		}
+		if len(msg.params) < 1 {
+			c.writeLines(c.numeric(errNeedMoreParam, "PASS", "Not enough parameters"))
+			return nil
+		}
+		c.pass = msg.params[0]
+		return nil
+
+	case "NICK":
+		if len(msg.params) < 1 {
+			c.writeLines(c.numeric(errNoNickGiven, "No nickname given"))
+			return nil
+		}
+		if c.registered.Load() {
+			if msg.params[0] != c.nickname() {
+				c.writeLines(formatMessage(c.server.Name, "NOTICE", c.nickname(), "Nickname changes are not supported; your nickname is your account name"))
+			}
+			return nil
+		}
+		if !validNick(msg.params[0]) {
+			c.writeLines(c.numeric(errBadNick, msg.params[0], "Erroneous nickname"))
+			return nil
+		}
+		c.setNick(msg.params[0])
+		return c.tryLogin()
+
+	case "USER":
+		if c.registered.Load() {
+			c.writeLines(c.numeric(errAlreadyReg, "You may not reregister"))
+			return nil
+		}
+		if len(msg.params) < 4 {
+			c.writeLines(c.numeric(errNeedMoreParam, "USER", "Not enough parameters"))
+			return nil
+		}
+		c.user = msg.params[0]
+		return c.tryLogin()
+
+	case "PING":
+		token := c.server.Name
+		if len(msg.params) > 0 {
+			token = msg.params[0]
+		}
+		c.writeLines(formatMessage(c.server.Name, "PONG", c.server.Name, token))
+		return nil
+
+	case "PONG":
+		c.queueMessage(shared.Message{Type: shared.PongMessage})
+		return nil
+
+	case "QUIT":
+		c.writeLines(formatMessage("", "ERROR", "Closing link"))
+		return io.EOF
+	}
+
+	if !c.registered.Load() {
+		c.writeLines(c.numeric(errNotRegistered, "You have not registered"))
+		return nil
+	}
+
+	switch msg.command {
+	case "JOIN":
+		c.handleJoin(msg.params)
+	case "PART":
+		c.handlePart(msg.params)
+	case "PRIVMSG", "NOTICE":
+		c.handlePrivmsg(msg.command, msg.params)
+	case "TOPIC":
+		c.handleTopic(msg.params)
+	case "NAMES":
+		c.handleNames(msg.params)
+	case "LIST":
+		c.handleList()
+	case "WHO":
+		c.handleWho(msg.params)
+	case "WHOIS":
+		c.handleWhois(msg.params)
+	case "ISON":
+		c.handleIson(msg.params)
+	case "MODE":
+		c.handleMode(msg.params)
+	default:
+		c.writeLines(c.numeric(errUnknownCmd, msg.command, "Unknown command"))
+	}
+	return nil
+}
+
+// tryLogin logs in once both NICK and USER have arrived. Accounts are the
+// chat server's own, so a PASS is always required.
+func (c *conn) tryLogin() error {
+	if c.loginSent || c.nickname() == "*" || c.user == "" {
+		return nil
+	}
+
+	if c.pass == "" {
+		c.writeLines(
+			c.numeric(errPasswdMismatch, "Password required: send PASS <password> before NICK"),
+			formatMessage("", "ERROR", "Closing link: password required"),
+		)
+		return io.EOF
+	}
+
+	c.loginSent = true
+	c.queueCommand("/login %s %s", c.nickname(), c.pass)
+	c.pass = ""
+	return nil
+}
+
+func (c *conn) handleJoin(params []string) {
+	if len(params) < 1 {
+		c.writeLines(c.numeric(errNeedMoreParam, "JOIN", "Not enough parameters"))
+		return
+	}
+
+	for _, channel := range strings.Split(params[0], ",") {
+		roomName, ok := channelToRoom(channel)
+		if !ok {
+			c.writeLines(c.numeric(errNoSuchChannel, channel, "No such channel"))
+			continue
+		}
+
+		if _, exists := c.server.RoomManager.GetRoom(roomName); !exists {
+			c.queueCommand("/create %s", roomName)
+		}
+		c.queueCommand("/join %s", roomName)
+	}
+}
+
+func (c *conn) handlePart(params []string) {
+	if len(params) < 1 {
+		c.writeLines(c.numeric(errNeedMoreParam, "PART", "Not enough parameters"))
+		return
+	}
+
+	for _, channel := range strings.Split(params[0], ",") {
+		roomName, ok := channelToRoom(channel)
+		if !ok || !c.inRoom(roomName) {
+			c.writeLines(c.numeric(errNotOnChannel, channel, "You're not on that channel"))
+			continue
+		}
+
+		// Rooms announce a departure only to the members who remain, so the
+		// leaver's own PART is echoed here.
+		c.queueCommand("/leave %s", roomName)
+		c.writeLines(formatMessage(c.hostmask(c.nickname()), "PART", roomToChannel(roomName)))
+	}
+}
+
+func (c *conn) handlePrivmsg(command string, params []string) {
+	if len(params) < 1 {
+		c.writeLines(c.numeric(errNoRecipient, "No recipient given ("+command+")"))
+		return
+	}
+	if len(params) < 2 || params[1] == "" {
+		c.writeLines(c.numeric(errNoTextToSend, "No text to send"))
+		return
+	}
+
+	text := params[1]
+	if action, ok := ctcpAction(text); ok {
+		text = "* " + action
+	} else if strings.HasPrefix(text, "\x01") {
+		// Other CTCP requests (VERSION, PING, ...) have no native meaning.
+		return
+	}
+
+	for _, target := range strings.Split(params[0], ",") {
+		if !isChannel(target) {
+			c.queueCommand("/msg %s %s", target, text)
+			continue
+		}
+
+		roomName, ok := channelToRoom(target)
+		if !ok || !c.inRoom(roomName) {
+			c.writeLines(c.numeric(errCannotSend, target, "Cannot send to channel"))
+			continue
+		}
+
+		c.queueMessage(shared.Message{
+			Type:     shared.TextMessage,
+			RoomName: roomName,
+			Content:  text,
+		})
+	}
+}
+
+func (c *conn) handleTopic(params []string) {
+	if len(params) < 1 {
+		c.writeLines(c.numeric(errNeedMoreParam, "TOPIC", "Not enough parameters"))
+		return
+	}
+
+	roomName, ok := channelToRoom(params[0])
+	r, exists := c.server.RoomManager.GetRoom(roomName)
+	if !ok || !exists {
+		c.writeLines(c.numeric(errNoSuchChannel, params[0], "No such channel"))
+		return
+	}
+
+	if len(params) == 1 {
+		c.writeLines(c.topicReply(r))
+		return
+	}
+
+	c.queueCommand("/topic %s %s", roomName, params[1])
+}
+
+func (c *conn) topicReply(r *room.Room) string {
+	if topic := r.Topic(); topic != "" {
+		return c.numeric(rplTopic, roomToChannel(r.Name), topic)
+	}
+	return c.numeric(rplNoTopic, roomToChannel(r.Name), "No topic is set")
+}
+
+func (c *conn) handleNames(params []string) {
+	var rooms []string
+	if len(params) > 0 {
+		for _, channel := range strings.Split(params[0], ",") {
+			if roomName, ok := channelToRoom(channel); ok {
+				rooms = append(rooms, roomName)
+			}
+		}
+	} else {
+		rooms = c.server.RoomManager.ListRooms()
+		sort.Strings(rooms)
+	}
+
+	var lines []string
+	for _, roomName := range rooms {
+		lines = append(lines, c.namesReply(roomName)...)
+	}
+	if len(params) == 0 {
+		lines = append(lines, c.numeric(rplEndOfNames, "*", "End of /NAMES list"))
+	}
+	c.writeLines(lines...)
+}
+
+func (c *conn) namesReply(roomName string) []string {
+	channel := roomToChannel(roomName)
+
+	var lines []string
+	if r, exists := c.server.RoomManager.GetRoom(roomName); exists {
+		members := r.Members()
+		for len(members) > 0 {
+			n := min(len(members), 20)
+			lines = append(lines, c.numeric(rplNamReply, "=", channel, strings.Join(members[:n], " ")))
+			members = members[n:]
+		}
+	}
+	return append(lines, c.numeric(rplEndOfNames, channel, "End of /NAMES list"))
+}
+
+func (c *conn) handleList() {
+	rooms := c.server.RoomManager.ListRooms()
+	sort.Strings(rooms)
+
+	lines := []string{c.numeric(rplListStart, "Channel", "Users  Name")}
+	for _, roomName := range rooms {
+		if r, exists := c.server.RoomManager.GetRoom(roomName); exists {
+			lines = append(lines, c.numeric(rplList, roomToChannel(r.Name), strconv.Itoa(r.GetClientCount()), r.Topic()))
+		}
+	}
+	lines = append(lines, c.numeric(rplListEnd, "End of /LIST"))
+	c.writeLines(lines...)
+}
+
+func (c *conn) whoReply(channel, nick string) string {
+	return c.numeric(rplWhoReply, channel, nick, c.server.Name, c.server.Name, nick, "H", "0 "+nick)
+}
+
+func (c *conn) handleWho(params []string) {
+	if len(params) < 1 {
+		c.writeLines(c.numeric(rplEndOfWho, "*", "End of /WHO list"))
+		return
+	}
+
+	mask := params[0]
+	var lines []string
+	if roomName, ok := channelToRoom(mask); ok {
+		if r, exists := c.server.RoomManager.GetRoom(roomName); exists {
+			for _, member := range r.Members() {
+				lines = append(lines, c.whoReply(mask, member))
+			}
+		}
+	} else if c.online(mask) {
+		lines = append(lines, c.whoReply("*", mask))
+	}
+
+	lines = append(lines, c.numeric(rplEndOfWho, mask, "End of /WHO list"))
+	c.writeLines(lines...)
+}
+
+func (c *conn) handleWhois(params []string) {
+	if len(params) < 1 {
+		c.writeLines(c.numeric(errNoNickGiven, "No nickname given"))
+		return
+	}
+
+	nick := params[len(params)-1]
+	for _, info := range c.server.Handler.OnlineClients() {
+		if info.Username != nick {
+			continue
+		}
+
+		channels := make([]string, 0, len(info.Rooms))
+		for _, roomName := range info.Rooms {
+			channels = append(channels, roomToChannel(roomName))
+		}
+
+		c.writeLines(
+			c.numeric(rplWhoisUser, nick, nick, c.server.Name, "*", nick),
+			c.numeric(rplWhoisChannels, nick, strings.Join(channels, " ")),
+			c.numeric(rplEndOfWhois, nick, "End of /WHOIS list"),
+		)
+		return
+	}
+
+	c.writeLines(
+		c.numeric(errNoSuchNick, nick, "No such nick/channel"),
+		c.numeric(rplEndOfWhois, nick, "End of /WHOIS list"),
+	)
+}
+
+func (c *conn) handleIson(params []string) {
+	online := make(map[string]bool)
+	for _, username := range c.server.Handler.OnlineUsers() {
+		online[username] = true
+	}
+
+	var present []string
+	for _, param := range params {
+		for _, nick := range strings.Fields(param) {
+			if online[nick] {
+				present = append(present, nick)
+			}
+		}
+	}
+	c.writeLines(c.numeric(rplISON, strings.Join(present, " ")))
+}
+
+// handleMode answers the mode queries clients send after joining. Rooms
+// have no modes, so changes are ignored.
+func (c *conn) handleMode(params []string) {
+	if len(params) < 1 {
+		c.writeLines(c.numeric(errNeedMoreParam, "MODE", "Not enough parameters"))
+		return
+	}
+
+	if !isChannel(params[0]) {
+		if len(params) == 1 {
+			c.writeLines(c.numeric(rplUModeIs, "+"))
+		}
+		return
+	}
+
+	switch {
+	case len(params) == 1:
+		c.writeLines(c.numeric(rplChannelModeIs, params[0], "+"))
+	case params[1] == "b" || params[1] == "+b":
+		c.writeLines(c.numeric(rplEndOfBanList, params[0], "End of channel ban list"))
+	}
+}
+
+func (c *conn) inRoom(roomName string) bool {
+	r, exists := c.server.RoomManager.GetRoom(roomName)
+	if !exists {
+		return false
+	}
+	nick := c.nickname()
+	for _, member := range r.Members() {
+		if member == nick {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *conn) online(nick string) bool {
+	for _, username := range c.server.Handler.OnlineUsers() {
+		if username == nick {
+			return true
+		}
+	}
+	return false
+}
+
+// translate renders a native message as IRC lines for this connection.
+func (c *conn) translate(msg shared.Message) []string {
+	if msg.Type == shared.PingMessage {
+		return []string{formatMessage("", "PING", c.server.Name)}
+	}
+
+	if !c.registered.Load() {
+		return nil
+	}
+
+	// IRC cannot change a line once it has been sent, so edits and deletes
+	// are described in a notice instead. Mentions are a notice to the user.
+	switch msg.Type {
+	case shared.EditMessage:
+		return c.textLines(c.server.Name, "NOTICE", roomToChannel(msg.RoomName),
+			fmt.Sprintf("%s edited message [%s]: %s", msg.Sender, msg.ID, msg.Content))
+	case shared.DeleteMessage:
+		return []string{formatMessage(c.server.Name, "NOTICE", roomToChannel(msg.RoomName),
+			fmt.Sprintf("%s deleted message [%s]", msg.Sender, msg.ID))}
+	case shared.MentionMessage:
+		return c.textLines(c.server.Name, "NOTICE", c.nickname(),
+			fmt.Sprintf("%s mentioned you in %s: %s", msg.Sender, roomToChannel(msg.RoomName), msg.Content))
+	}
+
+	// Before login the native welcome text only describes /register and
+	// /login, which do not apply here.
+	if msg.Type != shared.TextMessage {
+		return nil
+	}
+
+	if msg.Sender == shared.ServerName {
+		return c.translateNotice(msg)
+	}
+
+	if msg.RoomName != "" {
+		if msg.Sender == c.nickname() {
+			return nil
+		}
+		content := msg.Content
+		if msg.ParentID != "" {
+			content = fmt.Sprintf("(reply to [%s]) %s", msg.ParentID, content)
+		}
+		return c.textLines(c.hostmask(msg.Sender), "PRIVMSG", roomToChannel(msg.RoomName), content)
+	}
+
+	if msg.Recipient == c.nickname() {
+		return c.textLines(c.hostmask(msg.Sender), "PRIVMSG", c.nickname(), msg.Content)
+	}
+	return nil
+}
+
+func (c *conn) translateNotice(msg shared.Message) []string {
+	if msg.RoomName == "" {
+		// Server copies of direct messages and file transfer progress name a
+		// recipient; the direct message itself is delivered separately.
+		if msg.Recipient != "" {
+			return nil
+		}
+		return c.textLines(c.server.Name, "NOTICE", c.nickname(), msg.Content)
+	}
+
+	channel := roomToChannel(msg.RoomName)
+	if msg.Notice == nil {
+		return c.textLines(c.server.Name, "NOTICE", channel, msg.Content)
+	}
+
+	username := msg.Notice.User
+	switch msg.Notice.Kind {
+	case shared.NoticeJoined:
+		lines := []string{formatMessage(c.hostmask(username), "JOIN", channel)}
+		if username == c.nickname() {
+			if r, exists := c.server.RoomManager.GetRoom(msg.RoomName); exists && r.Topic() != "" {
+				lines = append(lines, c.topicReply(r))
+			}
+			lines = append(lines, c.namesReply(msg.RoomName)...)
+		}
+		return lines
+
+	case shared.NoticeLeft:
+		return []string{formatMessage(c.hostmask(username), "PART", channel)}
+
+	case shared.NoticeTopic:
+		return []string{formatMessage(c.hostmask(username), "TOPIC", channel, msg.Notice.Topic)}
+	}
+
+	return c.textLines(c.server.Name, "NOTICE", channel, msg.Content)
+}
+
+func (c *conn) textLines(prefix, command, target, content string) []string {
+	var lines []string
+	for _, line := range textLines(content) {
+		lines = append(lines, formatMessage(prefix, command, target, line))
+	}
+	return lines
+}
@@ -0,0 +1,123 @@
+package irc
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// maxTextLength keeps relayed lines well inside the 512-byte IRC limit once
+// the prefix, command and target have been added.
+const maxTextLength = 400
+
+type message struct {
+	prefix  string
+	command string
+	params  []string
+}
+
+// parseMessage splits a raw IRC line into prefix, command and parameters as
+// described in RFC 1459 section 2.3.1.
+func parseMessage(line string) (message, bool) {
+	var msg message
+
+	line = strings.TrimRight(line, "\r\n")
+	if strings.HasPrefix(line, ":") {
+		prefix, rest, ok := strings.Cut(line[1:], " ")
+		if !ok {
+			return msg, false
+		}
+		msg.prefix = prefix
+		line = rest
+	}
+
+	line = strings.TrimLeft(line, " ")
+	for line != "" {
+		if strings.HasPrefix(line, ":") && msg.command != "" {
+			msg.params = append(msg.params, line[1:])
+			break
+		}
+
+		word, rest, _ := strings.Cut(line, " ")
+		if msg.command == "" {
+			msg.command = strings.ToUpper(word)
+		} else {
+			msg.params = append(msg.params, word)
+		}
+		line = strings.TrimLeft(rest, " ")
+	}
+
+	return msg, msg.command != ""
+}
+
+// formatMessage builds a line, adding the trailing-parameter colon to the
+// last parameter when IRC requires it.
+func formatMessage(prefix, command string, params ...string) string {
+	var sb strings.Builder
+	if prefix != "" {
+		sb.WriteString(":" + prefix + " ")
+	}
+	sb.WriteString(command)
+
+	for i, param := range params {
+		sb.WriteString(" ")
+		if i == len(params)-1 && (param == "" || strings.ContainsRune(param, ' ') || strings.HasPrefix(param, ":")) {
+			sb.WriteString(":")
+		}
+		sb.WriteString(param)
+	}
+	sb.WriteString("\r\n")
+	return sb.String()
+}
+
+// channelToRoom maps "#general" (or "&general") to the room "general".
+func channelToRoom(channel string) (string, bool) {
+	if len(channel) < 2 || (channel[0] != '#' && channel[0] != '&') {
+		return "", false
+	}
+	return channel[1:], true
+}
+
+func roomToChannel(roomName string) string {
+	return "#" + roomName
+}
+
+func isChannel(target string) bool {
+	return strings.HasPrefix(target, "#") || strings.HasPrefix(target, "&")
+}
+
+func validNick(nick string) bool {
+	if nick == "" || isChannel(nick) || strings.HasPrefix(nick, ":") {
+		return false
+	}
+	return !strings.ContainsAny(nick, " ,*?!@\x00")
+}
+
+// textLines splits chat content into lines that can each be sent as one
+// PRIVMSG or NOTICE.
+func textLines(content string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(content, "\r", ""), "\n") {
+		for len(line) > maxTextLength {
+			cut := maxTextLength
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			lines = append(lines, line[:cut])
+			line = line[cut:]
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// ctcpAction returns the text of a CTCP ACTION ("/me waves") and whether
+// text was one.
+func ctcpAction(text string) (string, bool) {
+	inner, ok := strings.CutPrefix(text, "\x01ACTION ")
+	if !ok {
+		return "", false
+	}
+	return strings.TrimSuffix(inner, "\x01"), true
+}
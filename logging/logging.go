@@ -0,0 +1,80 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Level is shared by every handler created with Setup so that the level can
+// be changed at runtime without rebuilding the logger.
+var Level = new(slog.LevelVar)
+
+var sensitiveKeys = map[string]bool{
+	"password": true,
+	"token":    true,
+	"secret":   true,
+}
+
+func Setup(w io.Writer, format, level string) error {
+	if err := SetLevel(level); err != nil {
+		return err
+	}
+
+	options := &slog.HandlerOptions{
+		Level:       Level,
+		ReplaceAttr: redactAttr,
+	}
+
+	var handler slog.Handler
+	switch format {
+	case "text":
+		handler = slog.NewTextHandler(w, options)
+	case "json":
+		handler = slog.NewJSONHandler(w, options)
+	default:
+		return fmt.Errorf("unknown log format '%s'", format)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("unknown log level '%s'", name)
+	}
+	return level, nil
+}
+
+func SetLevel(name string) error {
+	level, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	Level.Set(level)
+	return nil
+}
+
+func CurrentLevel() string {
+	return strings.ToLower(Level.Level().String())
+}
+
+// RedactCommand hides the password argument of /login and /register so a raw
+// command line can be logged safely.
+func RedactCommand(line string) string {
+	fields := strings.Fields(line)
+	if len(fields) > 2 && (fields[0] == "/login" || fields[0] == "/register") {
+		return fields[0] + " " + fields[1] + " [REDACTED]"
+	}
+	return line
+}
+
+func redactAttr(groups []string, attr slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, "[REDACTED]")
+	}
+	return attr
+}
@@ -1,43 +1,460 @@
 package main
 
 import (
-	"log"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/imaneimrh/TCP-Chat_Server/access"
+	"github.com/imaneimrh/TCP-Chat_Server/admin"
+	"github.com/imaneimrh/TCP-Chat_Server/audit"
 	"github.com/imaneimrh/TCP-Chat_Server/auth"
 	"github.com/imaneimrh/TCP-Chat_Server/client"
+	"github.com/imaneimrh/TCP-Chat_Server/health"
+	"github.com/imaneimrh/TCP-Chat_Server/irc"
+	"github.com/imaneimrh/TCP-Chat_Server/logging"
+	"github.com/imaneimrh/TCP-Chat_Server/metrics"
+	"github.com/imaneimrh/TCP-Chat_Server/plugin"
+	"github.com/imaneimrh/TCP-Chat_Server/plugin/dice"
 	"github.com/imaneimrh/TCP-Chat_Server/room"
+	"github.com/imaneimrh/TCP-Chat_Server/search"
 	"github.com/imaneimrh/TCP-Chat_Server/server"
+	"github.com/imaneimrh/TCP-Chat_Server/webhook"
 )
 
 func main() {
+	listen := flag.String("listen", ":8080", "comma-separated chat listeners: host:port, tcp://host:port or unix:///path/to/socket")
+	unixMode := flag.String("unix-mode", "0660", "permissions of Unix socket files, in octal")
+	peerUsers := flag.String("peer-users", "", "comma-separated uid:username pairs; Unix socket peers with a listed uid are logged in without a password")
+	authOrder := flag.String("auth", "local", "comma-separated authentication providers, tried in order (local, htpasswd, ldap)")
+	htpasswdPath := flag.String("htpasswd", "", "path to an htpasswd file for the htpasswd provider")
+	ldapAddr := flag.String("ldap-addr", "", "LDAP server address (host:port) for the ldap provider")
+	ldapBindDN := flag.String("ldap-bind-dn", "uid=%s,ou=people,dc=example,dc=com", "bind DN template, %s is replaced by the username")
+	ldapTLS := flag.Bool("ldap-tls", false, "connect to the LDAP server over TLS (ldaps)")
+	admins := flag.String("admins", "", "comma-separated usernames with the admin role")
+	moderators := flag.String("moderators", "", "comma-separated usernames with the moderator role")
+	metricsAddr := flag.String("metrics-addr", "", "address for the Prometheus metrics HTTP listener, e.g. 127.0.0.1:9100 (empty disables)")
+	healthAddr := flag.String("health-addr", "", "address for the /healthz and /readyz HTTP listener; may equal -metrics-addr (empty disables)")
+	healthTimeout := flag.Duration("health-timeout", 2*time.Second, "how long the hub loops have to answer a liveness probe")
+	adminAddr := flag.String("admin-addr", "127.0.0.1:9090", "address for the admin HTTP API")
+	adminToken := flag.String("admin-token", os.Getenv("CHAT_ADMIN_TOKEN"), "bearer token for the admin HTTP API (defaults to $CHAT_ADMIN_TOKEN, empty disables the API)")
+	wsAddr := flag.String("ws-addr", "", "address for the WebSocket listener, e.g. :8081 (empty disables)")
+	wsPath := flag.String("ws-path", "/ws", "HTTP path that accepts WebSocket upgrades")
+	wsOrigins := flag.String("ws-origins", "", "comma-separated Origin values allowed to open WebSockets (empty allows any)")
+	ircAddr := flag.String("irc-addr", "", "address for the IRC gateway, e.g. :6667 (empty disables)")
+	ircName := flag.String("irc-name", "chat", "server name the IRC gateway reports to clients")
+	aclPath := flag.String("acl", "access.json", "file holding CIDR allow/deny lists and bans (empty keeps bans in memory only)")
+
+	limits := client.DefaultRateLimits()
+	flag.Float64Var(&limits.MessagesPerSecond, "rate-messages", limits.MessagesPerSecond, "messages per second allowed per connection and per user (0 disables)")
+	flag.IntVar(&limits.MessageBurst, "rate-messages-burst", limits.MessageBurst, "message burst size")
+	flag.Float64Var(&limits.CommandsPerSecond, "rate-commands", limits.CommandsPerSecond, "commands per second allowed per connection and per user (0 disables)")
+	flag.IntVar(&limits.CommandBurst, "rate-commands-burst", limits.CommandBurst, "command burst size")
+	flag.Float64Var(&limits.BytesPerSecond, "rate-bytes", limits.BytesPerSecond, "bytes per second read per connection and per user (0 disables)")
+	flag.IntVar(&limits.ByteBurst, "rate-bytes-burst", limits.ByteBurst, "byte burst size")
+	flag.Float64Var(&limits.ChunksPerSecond, "rate-chunks", limits.ChunksPerSecond, "file chunks per second read per connection and per user (0 disables)")
+	flag.IntVar(&limits.ChunkBurst, "rate-chunks-burst", limits.ChunkBurst, "file chunk burst size")
+	flag.IntVar(&limits.WarningsBeforeMute, "flood-warnings", limits.WarningsBeforeMute, "rate limit warnings before a flooder is muted")
+	flag.DurationVar(&limits.MuteDuration, "flood-mute", limits.MuteDuration, "how long a flooder is muted")
+	flag.IntVar(&limits.MutesBeforeDisconnect, "flood-mutes", limits.MutesBeforeDisconnect, "mutes before a flooder is disconnected")
+
+	protocol := client.DefaultProtocolLimits()
+	flag.IntVar(&protocol.MaxFrameSize, "max-frame", protocol.MaxFrameSize, "maximum size in bytes of a single protocol line")
+	flag.IntVar(&protocol.MaxContentSize, "max-content", protocol.MaxContentSize, "maximum size in bytes of message content")
+	flag.IntVar(&protocol.MaxFileNameSize, "max-filename", protocol.MaxFileNameSize, "maximum length in bytes of a transferred file name")
+	flag.IntVar(&protocol.MaxFileDataSize, "max-chunk", protocol.MaxFileDataSize, "maximum size in bytes of a file transfer chunk")
+
+	timeouts := client.DefaultTimeouts()
+	flag.DurationVar(&timeouts.PingInterval, "ping-interval", timeouts.PingInterval, "interval between server pings (0 disables)")
+	flag.IntVar(&timeouts.MaxMissedPongs, "max-missed-pongs", timeouts.MaxMissedPongs, "unanswered pings before a client is disconnected")
+	flag.DurationVar(&timeouts.LoginTimeout, "login-timeout", timeouts.LoginTimeout, "time a connection has to log in (0 disables)")
+	flag.DurationVar(&timeouts.IdleTimeout, "idle-timeout", timeouts.IdleTimeout, "disconnect clients that send nothing but pongs for this long (0 disables)")
+	flag.DurationVar(&timeouts.WriteTimeout, "write-timeout", timeouts.WriteTimeout, "maximum time to write a message to a client (0 disables)")
+
+	mentions := client.DefaultMentionLimits()
+	flag.IntVar(&mentions.BroadcastRoomSize, "mention-all-limit", mentions.BroadcastRoomSize, "members above which only moderators may use @room and @here (0 disables)")
+	flag.IntVar(&mentions.QueueSize, "mention-queue", mentions.QueueSize, "mentions kept for each offline user")
+
+	connLimits := server.DefaultLimits()
+	flag.IntVar(&connLimits.MaxConnections, "max-conns", connLimits.MaxConnections, "maximum concurrent connections (0 disables)")
+	flag.IntVar(&connLimits.MaxConnectionsPerIP, "max-conns-per-ip", connLimits.MaxConnectionsPerIP, "maximum concurrent connections from one IP (0 disables)")
+	flag.Float64Var(&connLimits.AcceptRate, "accept-rate", connLimits.AcceptRate, "new connections accepted per second (0 disables)")
+	flag.IntVar(&connLimits.AcceptBurst, "accept-burst", connLimits.AcceptBurst, "burst of new connections accepted at once")
+	webhooksPath := flag.String("webhooks", "", "JSON file of outgoing webhooks that receive matching room messages (empty disables)")
+	webhookConfig := webhook.DefaultConfig()
+	flag.IntVar(&webhookConfig.QueueSize, "webhook-queue", webhookConfig.QueueSize, "deliveries that may wait for one webhook before new ones are dropped")
+	flag.DurationVar(&webhookConfig.Timeout, "webhook-timeout", webhookConfig.Timeout, "timeout of a single webhook request")
+	flag.IntVar(&webhookConfig.MaxAttempts, "webhook-attempts", webhookConfig.MaxAttempts, "attempts made to deliver a webhook before giving up")
+
+	incomingPath := flag.String("incoming-webhooks", "", "JSON file of incoming webhooks that may post into rooms")
+	incomingAddr := flag.String("incoming-addr", "", "address serving POST /hooks/{name} for incoming webhooks; may equal -metrics-addr (empty disables)")
+	receiverConfig := webhook.DefaultReceiverConfig()
+	flag.Int64Var(&receiverConfig.MaxBodySize, "incoming-max-body", receiverConfig.MaxBodySize, "maximum size in bytes of an incoming webhook request")
+	flag.Float64Var(&receiverConfig.PostsPerSecond, "incoming-rate", receiverConfig.PostsPerSecond, "posts per second allowed per incoming webhook (0 disables)")
+	flag.IntVar(&receiverConfig.PostBurst, "incoming-burst", receiverConfig.PostBurst, "incoming webhook burst size")
+
+	historySize := flag.Int("history-size", room.DefaultHistorySize, "messages each room remembers for /edit and /delete")
+
+	searchConfig := search.DefaultConfig()
+	flag.IntVar(&searchConfig.MaxDocuments, "search-size", searchConfig.MaxDocuments, "messages kept in the /search index (0 disables search)")
+	flag.IntVar(&searchConfig.PageSize, "search-page", searchConfig.PageSize, "results per /search page")
+	flag.IntVar(&searchConfig.Context, "search-context", searchConfig.Context, "messages shown before and after each /search result")
+
+	auditPath := flag.String("audit-log", "audit.log", "append-only, hash-chained security audit log (empty disables)")
+	verifyAudit := flag.String("verify-audit", "", "verify the hash chain of an audit log file and exit")
+	plugins := flag.String("plugins", "", "comma-separated built-in plugins to enable (available: dice)")
+	logFormat := flag.String("log-format", "text", "log output format (text or json)")
+	logLevel := flag.String("log-level", "info", "minimum log level (debug, info, warn, error)")
+	flag.Parse()
+
+	if err := logging.Setup(os.Stderr, *logFormat, *logLevel); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid logging configuration: %v\n", err)
+		os.Exit(2)
+	}
+
+	if *verifyAudit != "" {
+		count, err := audit.VerifyFile(*verifyAudit)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: verification failed after %d good entries: %v\n", *verifyAudit, count, err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s: %d entries verified, chain intact\n", *verifyAudit, count)
+		return
+	}
 
 	authManager := auth.NewManager()
 	roomManager := room.NewManager()
+	roomManager.SetHistorySize(*historySize)
+
+	assignRoles(authManager, *moderators, auth.RoleModerator)
+	assignRoles(authManager, *admins, auth.RoleAdmin)
+
+	authenticator, err := buildAuthenticator(*authOrder, authManager, *htpasswdPath, *ldapAddr, *ldapBindDN, *ldapTLS)
+	if err != nil {
+		fatal("Failed to configure authentication", "error", err)
+	}
+
+	accessControl, err := access.NewControl(*aclPath)
+	if err != nil {
+		fatal("Failed to load access list", "error", err)
+	}
+
+	var auditLog *audit.Log
+	if *auditPath != "" {
+		auditLog, err = audit.Open(*auditPath)
+		if err != nil {
+			fatal("Failed to open audit log", "error", err)
+		}
+		defer auditLog.Close()
+	}
 
 	handler := client.NewHandler(roomManager, authManager)
+	handler.Authenticator = authenticator
+	// Directory accounts are unknown to the local store, so /register could
+	// claim one of their names and the roles given to it.
+	handler.OpenRegistration = authenticator.LocalOnly()
+	if handler.OpenRegistration && (*admins != "" || *moderators != "") {
+		slog.Warn("Role holders cannot register local accounts; add them to an htpasswd file or LDAP to let them log in")
+	}
+	handler.Limits = limits
+	handler.Protocol = protocol
+	handler.Timeouts = timeouts
+	handler.Mentions = mentions
+	handler.Access = accessControl
+	handler.Audit = auditLog
+
+	if searchConfig.MaxDocuments > 0 {
+		handler.Search = search.NewIndex(searchConfig)
+		roomManager.AddObserver(handler.Search)
+	}
+
+	pluginManager, err := buildPlugins(*plugins, handler)
+	if err != nil {
+		fatal("Invalid plugin configuration", "error", err)
+	}
+	pluginManager.Start()
+	handler.Plugins = pluginManager
 
 	go handler.Run()
 
-	chatServer := server.NewServerWithHandler(":8080", handler, roomManager, authManager)
+	var webhooks *webhook.Dispatcher
+	if *webhooksPath != "" {
+		hooks, err := webhook.LoadFile(*webhooksPath)
+		if err != nil {
+			fatal("Failed to load webhooks", "error", err)
+		}
+		webhooks, err = webhook.NewDispatcher(hooks, webhookConfig)
+		if err != nil {
+			fatal("Invalid webhook configuration", "error", err)
+		}
+		webhooks.Start()
+		roomManager.AddObserver(webhooks)
+		slog.Info("Webhooks loaded", "count", len(hooks))
+	}
+
+	listeners, err := buildListeners(*listen, *unixMode, *peerUsers)
+	if err != nil {
+		fatal("Invalid listener configuration", "error", err)
+	}
+
+	chatServer := server.NewServerWithHandler("", handler, roomManager, authManager)
+	chatServer.Listeners = listeners
+	chatServer.Limits = connLimits
+	chatServer.Access = accessControl
+
+	opsMuxes := make(map[string]*http.ServeMux)
+	opsMux := func(addr string) *http.ServeMux {
+		if opsMuxes[addr] == nil {
+			opsMuxes[addr] = http.NewServeMux()
+		}
+		return opsMuxes[addr]
+	}
+
+	if *metricsAddr != "" {
+		handler.RegisterMetrics()
+		roomManager.RegisterMetrics()
+		chatServer.RegisterMetrics()
+
+		opsMux(*metricsAddr).Handle("/metrics", metrics.Handler())
+	}
+
+	if *healthAddr != "" {
+		checker := health.NewChecker(chatServer, handler, roomManager)
+		checker.Timeout = *healthTimeout
+		checker.Register(opsMux(*healthAddr))
+	}
+
+	if *incomingAddr != "" {
+		var hooks []webhook.IncomingHook
+		if *incomingPath != "" {
+			hooks, err = webhook.LoadIncomingFile(*incomingPath)
+			if err != nil {
+				fatal("Failed to load incoming webhooks", "error", err)
+			}
+		}
+
+		receiverConfig.MaxTextSize = protocol.MaxContentSize
+		receiver, err := webhook.NewReceiver(hooks, handler, receiverConfig)
+		if err != nil {
+			fatal("Invalid incoming webhook configuration", "error", err)
+		}
+		for _, botName := range receiver.BotNames() {
+			if err := authManager.Reserve(botName); err != nil {
+				fatal("Invalid incoming webhook configuration", "error", err)
+			}
+		}
+		receiver.Register(opsMux(*incomingAddr))
+	}
+
+	for addr, mux := range opsMuxes {
+		go func(addr string, mux *http.ServeMux) {
+			slog.Info("Serving operational endpoints", "addr", addr)
+			err := http.ListenAndServe(addr, mux)
+			if err != nil {
+				fatal("Failed to start HTTP listener", "addr", addr, "error", err)
+			}
+		}(addr, mux)
+	}
+
+	if *adminToken != "" {
+		adminServer := admin.NewServer(*adminAddr, *adminToken, handler, roomManager, authManager, accessControl)
+		adminServer.Audit = auditLog
+
+		go func() {
+			err := adminServer.Start()
+			if err != nil {
+				fatal("Failed to start admin API", "error", err)
+			}
+		}()
+	}
+
+	if *wsAddr != "" {
+		wsMux := http.NewServeMux()
+		wsMux.Handle(*wsPath, chatServer.WebSocketHandler(splitList(*wsOrigins)))
+
+		go func() {
+			slog.Info("WebSocket gateway listening", "addr", *wsAddr, "path", *wsPath)
+			err := http.ListenAndServe(*wsAddr, wsMux)
+			if err != nil {
+				fatal("Failed to start WebSocket listener", "error", err)
+			}
+		}()
+	}
+
+	if *ircAddr != "" {
+		ircServer := irc.NewServer(*ircAddr, handler, roomManager)
+		ircServer.Name = *ircName
+		ircServer.Admit = chatServer.Admit
+
+		go func() {
+			err := ircServer.Start()
+			if err != nil {
+				fatal("Failed to start IRC gateway", "error", err)
+			}
+		}()
+	}
 
 	go func() {
-		log.Println("Starting integrated TCP Chat Server...")
+		slog.Info("Starting integrated TCP Chat Server")
 		err := chatServer.Start()
 		if err != nil {
-			log.Fatalf("Failed to start server: %v", err)
+			fatal("Failed to start server", "error", err)
 		}
 	}()
 
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
-	<-sigChan
-	log.Println("Shutting down server...")
+	for sig := range sigChan {
+		if sig != syscall.SIGHUP {
+			break
+		}
+
+		if err := accessControl.Reload(); err != nil {
+			slog.Error("Failed to reload access list", "error", err)
+		} else {
+			slog.Info("Access list reloaded")
+		}
+	}
+	slog.Info("Shutting down server")
 
+	pluginManager.Stop(time.Second)
+	if webhooks != nil {
+		webhooks.Stop(time.Second)
+	}
 	time.Sleep(time.Second)
-	log.Println("Server stopped")
+	slog.Info("Server stopped")
+}
+
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+func buildAuthenticator(order string, authManager *auth.Manager, htpasswdPath, ldapAddr, ldapBindDN string, ldapTLS bool) (*auth.Chain, error) {
+	chain := auth.NewChain()
+
+	for _, name := range strings.Split(order, ",") {
+		name = strings.TrimSpace(name)
+
+		switch name {
+		case "":
+			continue
+
+		case "local":
+			chain.Add(name, authManager)
+
+		case "htpasswd":
+			if htpasswdPath == "" {
+				return nil, fmt.Errorf("the htpasswd provider requires -htpasswd")
+			}
+			htpasswd, err := auth.NewHtpasswdFile(htpasswdPath)
+			if err != nil {
+				return nil, err
+			}
+			chain.Add(name, htpasswd)
+
+		case "ldap":
+			if ldapAddr == "" {
+				return nil, fmt.Errorf("the ldap provider requires -ldap-addr")
+			}
+			ldap := auth.NewLDAPAuthenticator(ldapAddr, ldapBindDN)
+			ldap.UseTLS = ldapTLS
+			chain.Add(name, ldap)
+
+		default:
+			return nil, fmt.Errorf("unknown authentication provider '%s'", name)
+		}
+	}
+
+	if chain.Len() == 0 {
+		return nil, fmt.Errorf("no authentication providers configured")
+	}
+
+	return chain, nil
+}
+
+func buildListeners(specs, unixMode, peerUsers string) ([]server.Listener, error) {
+	mode, err := strconv.ParseUint(unixMode, 8, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -unix-mode '%s'", unixMode)
+	}
+
+	peers := make(map[uint32]string)
+	for _, pair := range splitList(peerUsers) {
+		uid, username, ok := strings.Cut(pair, ":")
+		id, err := strconv.ParseUint(uid, 10, 32)
+		if !ok || err != nil || username == "" {
+			return nil, fmt.Errorf("invalid -peer-users entry '%s', expected uid:username", pair)
+		}
+		if auth.IsReserved(username) {
+			return nil, fmt.Errorf("invalid -peer-users entry '%s', the name is reserved", pair)
+		}
+		peers[uint32(id)] = username
+	}
+
+	var listeners []server.Listener
+	for _, spec := range splitList(specs) {
+		l, err := server.ParseListener(spec)
+		if err != nil {
+			return nil, err
+		}
+		if l.Network == "unix" {
+			l.Mode = os.FileMode(mode)
+			l.PeerUsers = peers
+		}
+		listeners = append(listeners, l)
+	}
+
+	if len(listeners) == 0 {
+		return nil, fmt.Errorf("at least one listener is required")
+	}
+	return listeners, nil
+}
+
+func assignRoles(authManager *auth.Manager, usernames string, role auth.Role) {
+	for _, username := range splitList(usernames) {
+		authManager.SetRole(username, role)
+	}
+}
+
+// availablePlugins are the plugins built into the server binary.
+var availablePlugins = map[string]func() plugin.Plugin{
+	"dice": func() plugin.Plugin { return dice.New() },
+}
+
+func buildPlugins(names string, handler *client.Handler) (*plugin.Manager, error) {
+	manager := plugin.NewManager(handler)
+
+	for _, name := range splitList(names) {
+		newPlugin, exists := availablePlugins[name]
+		if !exists {
+			return nil, fmt.Errorf("unknown plugin '%s'", name)
+		}
+		p := newPlugin()
+		// A plugin posts under its name, so no user may take it.
+		if err := handler.AuthManager.Reserve(p.Name()); err != nil {
+			return nil, fmt.Errorf("plugin '%s': %w", name, err)
+		}
+		manager.Register(p)
+	}
+	return manager, nil
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
@@ -0,0 +1,92 @@
+package dice
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+
+	"github.com/imaneimrh/TCP-Chat_Server/plugin"
+)
+
+const (
+	maxDice  = 20
+	maxSides = 1000
+)
+
+// Plugin adds /roll. Results are posted to the room the command was sent
+// from, or sent back to the caller when it came from outside a room.
+type Plugin struct {
+	host plugin.Host
+}
+
+func New() *Plugin {
+	return &Plugin{}
+}
+
+func (p *Plugin) Name() string {
+	return "dice"
+}
+
+func (p *Plugin) Start(host plugin.Host) error {
+	p.host = host
+	return host.RegisterCommand(plugin.Command{
+		Name:        "/roll",
+		Usage:       "/roll [NdM]",
+		Description: "Roll dice (default 1d6)",
+		Run:         p.roll,
+	})
+}
+
+func (p *Plugin) roll(call plugin.Call) {
+	spec := "1d6"
+	if len(call.Args) > 0 {
+		spec = strings.ToLower(call.Args[0])
+	}
+
+	count, sides, err := parseDice(spec)
+	if err != nil {
+		call.Reply(err.Error())
+		return
+	}
+
+	rolls := make([]string, count)
+	total := 0
+	for i := range rolls {
+		roll := rand.IntN(sides) + 1
+		total += roll
+		rolls[i] = strconv.Itoa(roll)
+	}
+
+	text := fmt.Sprintf("%s rolled %s: %s", call.Username, spec, strings.Join(rolls, " + "))
+	if count > 1 {
+		text += fmt.Sprintf(" = %d", total)
+	}
+
+	if call.Room != "" && p.host.Post(call.Room, text) == nil {
+		return
+	}
+	call.Reply(text)
+}
+
+// parseDice reads dice notation such as "2d6" or "d20".
+func parseDice(spec string) (count, sides int, err error) {
+	countText, sidesText, ok := strings.Cut(spec, "d")
+	if !ok {
+		return 0, 0, fmt.Errorf("Usage: /roll [NdM], for example /roll 2d6")
+	}
+
+	count = 1
+	if countText != "" {
+		count, err = strconv.Atoi(countText)
+		if err != nil || count < 1 || count > maxDice {
+			return 0, 0, fmt.Errorf("You can roll between 1 and %d dice", maxDice)
+		}
+	}
+
+	sides, err = strconv.Atoi(sidesText)
+	if err != nil || sides < 2 || sides > maxSides {
+		return 0, 0, fmt.Errorf("Dice need between 2 and %d sides", maxSides)
+	}
+	return count, sides, nil
+}
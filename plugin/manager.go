@@ -0,0 +1,359 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imaneimrh/TCP-Chat_Server/metrics"
+)
+
+var (
+	pluginPanics        = metrics.NewCounterVec("chat_plugin_panics_total", "Panics recovered from plugins.", "plugin")
+	pluginEventsDropped = metrics.NewCounterVec("chat_plugin_events_dropped_total", "Hook calls dropped because a plugin's queue was full.", "plugin")
+)
+
+// DefaultQueueSize is how many hook calls may wait for a busy plugin before
+// new ones are dropped.
+const DefaultQueueSize = 256
+
+// Backend is the part of the server plugins act through. client.Handler
+// implements it.
+type Backend interface {
+	PostAs(sender, room, text string) error
+	DirectAs(sender, username, text string) error
+	OnlineUsers() []string
+	Rooms() []string
+}
+
+// Manager runs plugins and delivers hooks to them. Each plugin has its own
+// goroutine and queue, so a slow plugin only delays itself, and every call
+// into a plugin recovers from panics. A nil *Manager ignores every hook.
+type Manager struct {
+	QueueSize int
+
+	backend  Backend
+	plugins  []Plugin
+	runners  []*runner
+	commands map[string]*registeredCommand
+	mu       sync.RWMutex
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	tasks  sync.WaitGroup
+}
+
+type registeredCommand struct {
+	Command
+	owner *runner
+}
+
+func NewManager(backend Backend) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		QueueSize: DefaultQueueSize,
+		backend:   backend,
+		commands:  make(map[string]*registeredCommand),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Register adds plugins. It must be called before Start.
+func (m *Manager) Register(plugins ...Plugin) {
+	m.plugins = append(m.plugins, plugins...)
+}
+
+// Start starts every registered plugin. A plugin whose Start fails or
+// panics is logged and left out.
+func (m *Manager) Start() {
+	for _, p := range m.plugins {
+		r := &runner{
+			plugin:  p,
+			manager: m,
+			queue:   make(chan func(), m.QueueSize),
+			log:     slog.Default().With("plugin", p.Name()),
+		}
+
+		var err error
+		if !r.call("start", func() { err = p.Start(&host{r}) }) || err != nil {
+			if err != nil {
+				r.log.Error("Plugin failed to start", "error", err)
+			}
+			m.dropCommands(r)
+			continue
+		}
+
+		m.runners = append(m.runners, r)
+		go r.run()
+		r.log.Info("Plugin started")
+	}
+}
+
+// Stop cancels background tasks, waits up to timeout for them to return
+// and then calls each plugin's Stop.
+func (m *Manager) Stop(timeout time.Duration) {
+	if m == nil {
+		return
+	}
+	m.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		m.tasks.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		slog.Warn("Plugin tasks did not stop in time")
+	}
+
+	for _, r := range m.runners {
+		if stopper, ok := r.plugin.(Stopper); ok {
+			r.call("stop", stopper.Stop)
+		}
+	}
+}
+
+func (m *Manager) dropCommands(r *runner) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for name, cmd := range m.commands {
+		if cmd.owner == r {
+			delete(m.commands, name)
+		}
+	}
+}
+
+// Message delivers a room message to plugins.
+func (m *Manager) Message(msg Message) {
+	if m == nil {
+		return
+	}
+	for _, r := range m.runners {
+		if hook, ok := r.plugin.(MessageHook); ok {
+			r.enqueue(func() { hook.OnMessage(msg) })
+		}
+	}
+}
+
+func (m *Manager) Joined(room, username string) {
+	if m == nil {
+		return
+	}
+	for _, r := range m.runners {
+		if hook, ok := r.plugin.(JoinHook); ok {
+			r.enqueue(func() { hook.OnJoin(room, username) })
+		}
+	}
+}
+
+func (m *Manager) Left(room, username string) {
+	if m == nil {
+		return
+	}
+	for _, r := range m.runners {
+		if hook, ok := r.plugin.(LeaveHook); ok {
+			r.enqueue(func() { hook.OnLeave(room, username) })
+		}
+	}
+}
+
+func (m *Manager) LoggedIn(username string) {
+	if m == nil {
+		return
+	}
+	for _, r := range m.runners {
+		if hook, ok := r.plugin.(LoginHook); ok {
+			r.enqueue(func() { hook.OnLogin(username) })
+		}
+	}
+}
+
+func (m *Manager) LoggedOut(username string) {
+	if m == nil {
+		return
+	}
+	for _, r := range m.runners {
+		if hook, ok := r.plugin.(LogoutHook); ok {
+			r.enqueue(func() { hook.OnLogout(username) })
+		}
+	}
+}
+
+// CommandRun tells plugins that username ran a slash command. line must
+// already have its secrets redacted.
+func (m *Manager) CommandRun(username, line string) {
+	if m == nil {
+		return
+	}
+	for _, r := range m.runners {
+		if hook, ok := r.plugin.(CommandHook); ok {
+			r.enqueue(func() { hook.OnCommand(username, line) })
+		}
+	}
+}
+
+// RunCommand runs line if it names a plugin command and reports whether it
+// did. room is the room the command was sent to, if any.
+func (m *Manager) RunCommand(username, room, line string) bool {
+	if m == nil {
+		return false
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false
+	}
+
+	m.mu.RLock()
+	cmd, exists := m.commands[fields[0]]
+	m.mu.RUnlock()
+	if !exists {
+		return false
+	}
+
+	sender := cmd.owner.plugin.Name()
+	call := Call{
+		Username: username,
+		Room:     room,
+		Args:     fields[1:],
+		reply: func(text string) {
+			if err := m.backend.DirectAs(sender, username, text); err != nil {
+				cmd.owner.log.Debug("Plugin reply not delivered", "user", username, "error", err)
+			}
+		},
+	}
+	cmd.owner.enqueue(func() { cmd.Run(call) })
+	return true
+}
+
+// Commands lists the registered plugin commands by name.
+func (m *Manager) Commands() []Command {
+	if m == nil {
+		return nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	commands := make([]Command, 0, len(m.commands))
+	for _, cmd := range m.commands {
+		commands = append(commands, cmd.Command)
+	}
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].Name < commands[j].Name
+	})
+	return commands
+}
+
+type runner struct {
+	plugin  Plugin
+	manager *Manager
+	queue   chan func()
+	log     *slog.Logger
+}
+
+func (r *runner) run() {
+	for {
+		select {
+		case task := <-r.queue:
+			r.call("hook", task)
+		case <-r.manager.ctx.Done():
+			return
+		}
+	}
+}
+
+// call runs fn and turns a panic into a log entry. It reports whether fn
+// returned normally.
+func (r *runner) call(stage string, fn func()) (ok bool) {
+	defer func() {
+		if v := recover(); v != nil {
+			pluginPanics.With(r.plugin.Name()).Inc()
+			r.log.Error("Plugin panicked", "stage", stage, "panic", v, "stack", string(debug.Stack()))
+			ok = false
+		}
+	}()
+	fn()
+	return true
+}
+
+func (r *runner) enqueue(task func()) {
+	select {
+	case r.queue <- task:
+	default:
+		pluginEventsDropped.With(r.plugin.Name()).Inc()
+		r.log.Warn("Plugin queue full, dropping hook call")
+	}
+}
+
+// host is the Host handed to one plugin.
+type host struct {
+	r *runner
+}
+
+func (h *host) Post(room, text string) error {
+	return h.r.manager.backend.PostAs(h.r.plugin.Name(), room, text)
+}
+
+func (h *host) DM(username, text string) error {
+	return h.r.manager.backend.DirectAs(h.r.plugin.Name(), username, text)
+}
+
+func (h *host) RegisterCommand(cmd Command) error {
+	if !strings.HasPrefix(cmd.Name, "/") || len(cmd.Name) < 2 || strings.ContainsAny(cmd.Name, " \t") {
+		return fmt.Errorf("invalid command name '%s'", cmd.Name)
+	}
+	if cmd.Run == nil {
+		return fmt.Errorf("command %s has no Run function", cmd.Name)
+	}
+
+	m := h.r.manager
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if existing, exists := m.commands[cmd.Name]; exists {
+		return fmt.Errorf("command %s is already registered by %s", cmd.Name, existing.owner.plugin.Name())
+	}
+	m.commands[cmd.Name] = &registeredCommand{Command: cmd, owner: h.r}
+	return nil
+}
+
+func (h *host) Go(task func(ctx context.Context)) {
+	m := h.r.manager
+	m.tasks.Add(1)
+	go func() {
+		defer m.tasks.Done()
+		h.r.call("task", func() { task(m.ctx) })
+	}()
+}
+
+func (h *host) Every(interval time.Duration, fn func()) {
+	h.Go(func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				h.r.enqueue(fn)
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+}
+
+func (h *host) OnlineUsers() []string {
+	return h.r.manager.backend.OnlineUsers()
+}
+
+func (h *host) Rooms() []string {
+	return h.r.manager.backend.Rooms()
+}
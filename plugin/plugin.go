@@ -0,0 +1,95 @@
+package plugin
+
+import (
+	"context"
+	"time"
+)
+
+// Plugin is a bot that runs inside the server. Besides Name and Start, a
+// plugin implements whichever hook interfaces below it needs.
+type Plugin interface {
+	// Name is used as the sender of everything the plugin posts, so it
+	// should not match a registered username.
+	Name() string
+	// Start is called once, before any hook. Commands are registered here.
+	Start(host Host) error
+}
+
+// Message is a chat message posted to a room by a user.
+type Message struct {
+	Room string
+	From string
+	Text string
+}
+
+type MessageHook interface {
+	OnMessage(msg Message)
+}
+
+type JoinHook interface {
+	OnJoin(room, username string)
+}
+
+type LeaveHook interface {
+	OnLeave(room, username string)
+}
+
+type LoginHook interface {
+	OnLogin(username string)
+}
+
+type LogoutHook interface {
+	OnLogout(username string)
+}
+
+// CommandHook sees every slash command a logged-in user runs, built-in or
+// not. Passwords are redacted from line.
+type CommandHook interface {
+	OnCommand(username, line string)
+}
+
+// Stopper is called on shutdown, after the plugin's background tasks have
+// been cancelled.
+type Stopper interface {
+	Stop()
+}
+
+// Host is what a plugin uses to act on the server.
+type Host interface {
+	Post(room, text string) error
+	DM(username, text string) error
+	// RegisterCommand adds a slash command. Built-in commands take
+	// precedence over plugin commands of the same name.
+	RegisterCommand(cmd Command) error
+	// Go runs task in the background with panics recovered. ctx is
+	// cancelled when the server shuts down.
+	Go(task func(ctx context.Context))
+	// Every calls fn on the plugin's own goroutine at each interval until
+	// shutdown.
+	Every(interval time.Duration, fn func())
+	OnlineUsers() []string
+	Rooms() []string
+}
+
+type Command struct {
+	// Name includes the leading slash, e.g. "/roll".
+	Name        string
+	Usage       string
+	Description string
+	Run         func(call Call)
+}
+
+// Call is one invocation of a plugin command.
+type Call struct {
+	Username string
+	// Room is set when the command was sent as a room message.
+	Room string
+	Args []string
+
+	reply func(text string)
+}
+
+// Reply answers the user who ran the command with a direct message.
+func (c Call) Reply(text string) {
+	c.reply(text)
+}
@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Bucket is a token bucket that refills at Rate tokens per second up to Burst
+// tokens. A Bucket with a non-positive rate never limits.
+type Bucket struct {
+	Rate   float64
+	Burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+	mu     sync.Mutex
+}
+
+func NewBucket(rate float64, burst int) *Bucket {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &Bucket{
+		Rate:   rate,
+		Burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		now:    time.Now,
+	}
+}
+
+func (b *Bucket) Allow() bool {
+	return b.AllowN(1)
+}
+
+func (b *Bucket) AllowN(n int) bool {
+	if b == nil || b.Rate <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(b.now())
+	if b.tokens < float64(n) {
+		return false
+	}
+
+	b.tokens -= float64(n)
+	return true
+}
+
+// Reserve takes n tokens unconditionally and returns how long the caller
+// should wait before acting so that the configured rate is respected.
+func (b *Bucket) Reserve(n int) time.Duration {
+	if b == nil || b.Rate <= 0 {
+		return 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(b.now())
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.Rate * float64(time.Second))
+}
+
+// Full reports whether the bucket has refilled to its burst, so that
+// replacing it with a new one would change nothing.
+func (b *Bucket) Full() bool {
+	if b == nil || b.Rate <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(b.now())
+	return b.tokens >= b.Burst
+}
+
+func (b *Bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	b.last = now
+
+	b.tokens += elapsed * b.Rate
+	if b.tokens > b.Burst {
+		b.tokens = b.Burst
+	}
+}
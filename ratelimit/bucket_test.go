@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only moves when told to.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func newTestBucket(rate float64, burst int) (*Bucket, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	b := NewBucket(rate, burst)
+	b.now = clock.now
+	b.last = clock.t
+	return b, clock
+}
+
+func TestBucketBurst(t *testing.T) {
+	b, _ := newTestBucket(1, 3)
+
+	for i := 0; i < 3; i++ {
+		if !b.Allow() {
+			t.Fatalf("Allow() #%d = false within the burst", i+1)
+		}
+	}
+	if b.Allow() {
+		t.Fatal("Allow() = true after the burst was used up")
+	}
+	if b.AllowN(4) {
+		t.Fatal("AllowN() = true for more than the burst")
+	}
+}
+
+func TestBucketRefill(t *testing.T) {
+	b, clock := newTestBucket(2, 4)
+
+	if !b.AllowN(4) {
+		t.Fatal("AllowN(4) = false on a full bucket")
+	}
+
+	clock.advance(400 * time.Millisecond)
+	if b.Allow() {
+		t.Fatal("Allow() = true before a whole token refilled")
+	}
+
+	clock.advance(100 * time.Millisecond)
+	if !b.Allow() {
+		t.Fatal("Allow() = false after a token refilled")
+	}
+
+	// Refilling stops at the burst.
+	clock.advance(time.Hour)
+	if !b.AllowN(4) || b.Allow() {
+		t.Fatal("bucket did not refill to exactly its burst")
+	}
+}
+
+func TestBucketReserve(t *testing.T) {
+	b, clock := newTestBucket(10, 10)
+
+	if d := b.Reserve(10); d != 0 {
+		t.Fatalf("Reserve(10) = %v within the burst, want 0", d)
+	}
+	if d := b.Reserve(5); d != 500*time.Millisecond {
+		t.Fatalf("Reserve(5) = %v, want 500ms", d)
+	}
+
+	// The debt is paid back before anything else is allowed.
+	clock.advance(500 * time.Millisecond)
+	if b.Allow() {
+		t.Fatal("Allow() = true while the reservation was still owed")
+	}
+	clock.advance(100 * time.Millisecond)
+	if !b.Allow() {
+		t.Fatal("Allow() = false after the reservation was paid back")
+	}
+}
+
+func TestBucketFull(t *testing.T) {
+	b, clock := newTestBucket(1, 2)
+
+	if !b.Full() {
+		t.Fatal("Full() = false for a new bucket")
+	}
+	b.Allow()
+	if b.Full() {
+		t.Fatal("Full() = true after a token was taken")
+	}
+	clock.advance(time.Second)
+	if !b.Full() {
+		t.Fatal("Full() = false after the bucket refilled")
+	}
+}
+
+func TestBucketDisabled(t *testing.T) {
+	var unset *Bucket
+	for _, b := range []*Bucket{unset, NewBucket(0, 1)} {
+		for i := 0; i < 100; i++ {
+			if !b.Allow() || b.Reserve(1000) != 0 || !b.Full() {
+				t.Fatal("a disabled bucket limited")
+			}
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package room
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
+)
+
+// DefaultHistorySize is how many messages a room remembers.
+const DefaultHistorySize = 500
+
+// MaxReactions caps the different reactions one message can collect.
+const MaxReactions = 20
+
+// Post is a message kept in a room's history. A deleted post stays as a
+// tombstone with its text and reactions removed. ParentID is set on
+// replies. Reactions maps each reaction to the users who gave it, in order.
+type Post struct {
+	ID        string
+	ParentID  string
+	Sender    string
+	Text      string
+	Time      time.Time
+	Edited    time.Time
+	Deleted   bool
+	Reactions map[string][]string
+}
+
+// ReactionCounts returns how many users gave each reaction, or nil when the
+// post has none.
+func (p Post) ReactionCounts() map[string]int {
+	if len(p.Reactions) == 0 {
+		return nil
+	}
+
+	counts := make(map[string]int, len(p.Reactions))
+	for emoji, users := range p.Reactions {
+		counts[emoji] = len(users)
+	}
+	return counts
+}
+
+// clone copies the post so that callers cannot share its reactions with
+// the history.
+func (p Post) clone() Post {
+	if p.Reactions != nil {
+		reactions := make(map[string][]string, len(p.Reactions))
+		for emoji, users := range p.Reactions {
+			reactions[emoji] = slices.Clone(users)
+		}
+		p.Reactions = reactions
+	}
+	return p
+}
+
+// record assigns message its ID and keeps it in the history. Server notices
+// are neither numbered nor kept. r.mu must be held.
+func (r *Room) record(message *shared.Message) {
+	message.ID = ""
+	if r.newID == nil || message.Type != shared.TextMessage || message.Sender == shared.ServerName {
+		return
+	}
+
+	message.ID = r.newID()
+	r.history = append(r.history, Post{
+		ID:       message.ID,
+		ParentID: message.ParentID,
+		Sender:   message.Sender,
+		Text:     message.Content,
+		Time:     time.Now(),
+	})
+
+	if over := len(r.history) - r.historySize; over > 0 {
+		r.history = append(r.history[:0:0], r.history[over:]...)
+	}
+}
+
+// find returns the index of a post in the history, or -1. r.mu must be
+// held.
+func (r *Room) find(id string) int {
+	for i := len(r.history) - 1; i >= 0; i-- {
+		if r.history[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// History returns up to limit of the most recent posts, oldest first. A
+// limit of zero or less returns the whole history.
+func (r *Room) History(limit int) []Post {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	start := 0
+	if limit > 0 && len(r.history) > limit {
+		start = len(r.history) - limit
+	}
+	posts := make([]Post, 0, len(r.history)-start)
+	for _, post := range r.history[start:] {
+		posts = append(posts, post.clone())
+	}
+	return posts
+}
+
+func (r *Room) Post(id string) (Post, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if i := r.find(id); i >= 0 {
+		return r.history[i].clone(), true
+	}
+	return Post{}, false
+}
+
+// LastID returns the ID of the newest post, or "" when there is none.
+func (r *Room) LastID() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.history) == 0 {
+		return ""
+	}
+	return r.history[len(r.history)-1].ID
+}
+
+// Thread returns the post that started thread root followed by its
+// replies, oldest first. The first post is missing once it has aged out of
+// the history.
+func (r *Room) Thread(root string) []Post {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var thread []Post
+	for _, post := range r.history {
+		if post.ID == root || post.ParentID == root {
+			thread = append(thread, post.clone())
+		}
+	}
+	return thread
+}
+
+// Edit replaces the text of a post by editor and tells the members and
+// observers.
+func (r *Room) Edit(id, editor, text string) error {
+	r.mu.Lock()
+
+	i := r.find(id)
+	switch {
+	case i < 0:
+		r.mu.Unlock()
+		return fmt.Errorf("message %s does not exist in %s", id, r.Name)
+	case r.history[i].Deleted:
+		r.mu.Unlock()
+		return fmt.Errorf("message %s has been deleted", id)
+	case r.history[i].Sender != editor:
+		r.mu.Unlock()
+		return fmt.Errorf("you can only edit your own messages")
+	}
+
+	r.history[i].Text = text
+	r.history[i].Edited = time.Now()
+
+	edit := shared.Message{
+		Type:     shared.EditMessage,
+		Sender:   editor,
+		RoomName: r.Name,
+		Content:  text,
+		ID:       id,
+	}
+	r.broadcastToClients(edit)
+	r.mu.Unlock()
+
+	r.notify(edit)
+	return nil
+}
+
+// Delete replaces a post with a tombstone and tells the members and
+// observers. Only the author may delete a post unless force is set, as it
+// is for moderators. It returns the deleted post's author.
+func (r *Room) Delete(id, by string, force bool) (string, error) {
+	r.mu.Lock()
+
+	i := r.find(id)
+	switch {
+	case i < 0:
+		r.mu.Unlock()
+		return "", fmt.Errorf("message %s does not exist in %s", id, r.Name)
+	case r.history[i].Deleted:
+		r.mu.Unlock()
+		return "", fmt.Errorf("message %s has already been deleted", id)
+	case r.history[i].Sender != by && !force:
+		r.mu.Unlock()
+		return "", fmt.Errorf("you can only delete your own messages")
+	}
+
+	r.history[i].Text = ""
+	r.history[i].Deleted = true
+	r.history[i].Reactions = nil
+	author := r.history[i].Sender
+
+	deletion := shared.Message{
+		Type:     shared.DeleteMessage,
+		Sender:   by,
+		RoomName: r.Name,
+		ID:       id,
+	}
+	r.broadcastToClients(deletion)
+	r.mu.Unlock()
+
+	r.notify(deletion)
+	return author, nil
+}
+
+// React adds a reaction by username to a post and tells the members the new
+// counts. Each user can give each reaction once per post.
+func (r *Room) React(id, username, emoji string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.find(id)
+	switch {
+	case i < 0:
+		return fmt.Errorf("message %s does not exist in %s", id, r.Name)
+	case r.history[i].Deleted:
+		return fmt.Errorf("message %s has been deleted", id)
+	}
+
+	post := &r.history[i]
+	users, exists := post.Reactions[emoji]
+	switch {
+	case slices.Contains(users, username):
+		return fmt.Errorf("you already reacted with %s", emoji)
+	case !exists && len(post.Reactions) >= MaxReactions:
+		return fmt.Errorf("message %s already has %d different reactions", id, MaxReactions)
+	}
+
+	if post.Reactions == nil {
+		post.Reactions = make(map[string][]string)
+	}
+	post.Reactions[emoji] = append(users, username)
+
+	r.broadcastReaction(*post, username, "+"+emoji)
+	return nil
+}
+
+// Unreact takes back a reaction username gave to a post.
+func (r *Room) Unreact(id, username, emoji string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.find(id)
+	if i < 0 {
+		return fmt.Errorf("message %s does not exist in %s", id, r.Name)
+	}
+
+	post := &r.history[i]
+	users := post.Reactions[emoji]
+	at := slices.Index(users, username)
+	if at < 0 {
+		return fmt.Errorf("you have not reacted with %s", emoji)
+	}
+
+	if users = slices.Delete(users, at, at+1); len(users) > 0 {
+		post.Reactions[emoji] = users
+	} else {
+		delete(post.Reactions, emoji)
+	}
+
+	r.broadcastReaction(*post, username, "-"+emoji)
+	return nil
+}
+
+// broadcastReaction sends the counts of a post after a change. Content is
+// the reaction that changed, prefixed with + when it was given and - when it
+// was taken back. r.mu must be held.
+func (r *Room) broadcastReaction(post Post, username, change string) {
+	r.broadcastToClients(shared.Message{
+		Type:      shared.ReactionMessage,
+		Sender:    username,
+		RoomName:  r.Name,
+		Content:   change,
+		ID:        post.ID,
+		ParentID:  post.ParentID,
+		Reactions: post.ReactionCounts(),
+	})
+}
+
+func (r *Room) setHistorySize(size int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.historySize = size
+	if over := len(r.history) - size; over > 0 {
+		r.history = append(r.history[:0:0], r.history[over:]...)
+	}
+}
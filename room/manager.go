@@ -2,19 +2,28 @@ package room
 
 import (
 	"fmt"
+	"log/slog"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/imaneimrh/TCP-Chat_Server/metrics"
 	"github.com/imaneimrh/TCP-Chat_Server/shared"
 )
 
 type Manager struct {
-	rooms map[string]*Room
-	mu    sync.RWMutex
+	rooms       map[string]*Room
+	observers   atomic.Pointer[[]Observer]
+	lastID      atomic.Uint64
+	historySize int
+	mu          sync.RWMutex
 }
 
 func NewManager() *Manager {
 	manager := &Manager{
-		rooms: make(map[string]*Room),
+		rooms:       make(map[string]*Room),
+		historySize: DefaultHistorySize,
 	}
 
 	manager.CreateRoom("general")
@@ -31,13 +40,62 @@ func (m *Manager) CreateRoom(name string) (*Room, error) {
 	}
 
 	newRoom := NewRoom(name)
+	newRoom.observers = m.Observers
+	newRoom.newID = m.NewID
+	newRoom.historySize = m.historySize
 	m.rooms[name] = newRoom
 
 	go newRoom.Run()
 
+	slog.Info("Room created", "room", name)
+
 	return newRoom, nil
 }
 
+// AddObserver adds an Observer to every room, including rooms that already
+// exist.
+func (m *Manager) AddObserver(observer Observer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	observers := append(append([]Observer(nil), m.Observers()...), observer)
+	m.observers.Store(&observers)
+}
+
+func (m *Manager) Observers() []Observer {
+	if observers := m.observers.Load(); observers != nil {
+		return *observers
+	}
+	return nil
+}
+
+// NewID numbers messages across all rooms, and direct messages too. IDs are
+// unique for the lifetime of the server.
+func (m *Manager) NewID() string {
+	return strconv.FormatUint(m.lastID.Add(1), 10)
+}
+
+// SetHistorySize sets how many messages each room remembers.
+func (m *Manager) SetHistorySize(size int) {
+	m.mu.Lock()
+	m.historySize = size
+	m.mu.Unlock()
+
+	for _, room := range m.allRooms() {
+		room.setHistorySize(size)
+	}
+}
+
+// FindPost looks a message up by ID in every room's history.
+func (m *Manager) FindPost(id string) (*Room, Post, bool) {
+	for _, room := range m.allRooms() {
+		if post, ok := room.Post(id); ok {
+			return room, post, true
+		}
+	}
+	return nil, Post{}, false
+}
+
 func (m *Manager) GetRoom(name string) (*Room, bool) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -64,6 +122,9 @@ func (m *Manager) DeleteRoom(name string) error {
 	}
 
 	delete(m.rooms, name)
+	room.Stop()
+
+	slog.Info("Room deleted", "room", name)
 	return nil
 }
 
@@ -73,8 +134,7 @@ func (m *Manager) JoinRoom(roomName string, client *shared.Client) error {
 		return fmt.Errorf("Room %s does not exist", roomName)
 	}
 
-	room.Register <- client
-	return nil
+	return room.join(client)
 }
 
 func (m *Manager) LeaveRoom(roomName string, client *shared.Client) error {
@@ -83,8 +143,7 @@ func (m *Manager) LeaveRoom(roomName string, client *shared.Client) error {
 		return fmt.Errorf("Room %s does not exist", roomName)
 	}
 
-	room.Unregister <- client
-	return nil
+	return room.leave(client)
 }
 
 func (m *Manager) BroadcastToRoom(roomName string, message shared.Message) error {
@@ -93,8 +152,7 @@ func (m *Manager) BroadcastToRoom(roomName string, message shared.Message) error
 		return fmt.Errorf("Room %s does not exist", roomName)
 	}
 
-	room.Broadcast <- message
-	return nil
+	return room.publish(message)
 }
 
 func (m *Manager) ListRooms() []string {
@@ -108,3 +166,70 @@ func (m *Manager) ListRooms() []string {
 
 	return roomList
 }
+
+func (m *Manager) RoomMembers() map[string]int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	members := make(map[string]int, len(m.rooms))
+	for name, room := range m.rooms {
+		members[name] = room.GetClientCount()
+	}
+
+	return members
+}
+
+func (m *Manager) allRooms() []*Room {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rooms := make([]*Room, 0, len(m.rooms))
+	for _, room := range m.rooms {
+		rooms = append(rooms, room)
+	}
+	return rooms
+}
+
+func (m *Manager) RoomsRunning() bool {
+	for _, room := range m.allRooms() {
+		if !room.Running() {
+			return false
+		}
+	}
+	return true
+}
+
+// ProbeRooms probes every room concurrently and reports the first one whose
+// Run loop did not respond within timeout.
+func (m *Manager) ProbeRooms(timeout time.Duration) error {
+	rooms := m.allRooms()
+	errs := make(chan error, len(rooms))
+
+	for _, room := range rooms {
+		go func(room *Room) {
+			errs <- room.Probe(timeout)
+		}(room)
+	}
+
+	var firstErr error
+	for range rooms {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (m *Manager) RegisterMetrics() {
+	metrics.NewGaugeFunc("chat_rooms", "Number of rooms.", func() float64 {
+		return float64(len(m.ListRooms()))
+	})
+
+	metrics.NewGaugeVecFunc("chat_room_members", "Number of members in each room.", "room", func() map[string]float64 {
+		values := make(map[string]float64)
+		for name, count := range m.RoomMembers() {
+			values[name] = float64(count)
+		}
+		return values
+	})
+}
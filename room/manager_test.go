@@ -0,0 +1,52 @@
+package room
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
+)
+
+func TestDeletedRoomRefusesRequests(t *testing.T) {
+	m := NewManager()
+	r, err := m.CreateRoom("ops")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.DeleteRoom("ops"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A caller that looked the room up before it was deleted still holds
+	// it. Its requests must fail rather than wait for Run.
+	client := &shared.Client{Username: "alice", Rooms: make(map[string]bool), Send: make(chan shared.Message, 1)}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for name, err := range map[string]error{
+			"join":  r.join(client),
+			"leave": r.leave(client),
+		} {
+			if !errors.Is(err, ErrDeleted) {
+				t.Errorf("%s error = %v, want ErrDeleted", name, err)
+			}
+		}
+		for i := 0; i < cap(r.Broadcast)+1; i++ {
+			if err := r.publish(shared.Message{Content: "hello"}); !errors.Is(err, ErrDeleted) {
+				t.Errorf("publish error = %v, want ErrDeleted", err)
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("request to a deleted room blocked")
+	}
+
+	if err := m.JoinRoom("ops", client); err == nil {
+		t.Error("JoinRoom() succeeded after the room was deleted")
+	}
+}
@@ -0,0 +1,29 @@
+package room
+
+import "github.com/imaneimrh/TCP-Chat_Server/shared"
+
+// Membership and topic changes reach clients as server messages whose Content
+// native clients print as it is. Gateways that need the structure back, such
+// as IRC, read their Notice instead.
+
+func JoinedNotice(roomName, username string) shared.Message {
+	return notice(roomName, username+" has joined the room.", shared.Notice{Kind: shared.NoticeJoined, User: username})
+}
+
+func LeftNotice(roomName, username string) shared.Message {
+	return notice(roomName, username+" has left the room.", shared.Notice{Kind: shared.NoticeLeft, User: username})
+}
+
+func TopicNotice(roomName, username, topic string) shared.Message {
+	return notice(roomName, username+" set the topic: "+topic, shared.Notice{Kind: shared.NoticeTopic, User: username, Topic: topic})
+}
+
+func notice(roomName, content string, n shared.Notice) shared.Message {
+	return shared.Message{
+		Type:     shared.TextMessage,
+		Sender:   shared.ServerName,
+		RoomName: roomName,
+		Content:  content,
+		Notice:   &n,
+	}
+}
@@ -1,31 +1,68 @@
 package room
 
 import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/imaneimrh/TCP-Chat_Server/metrics"
 	"github.com/imaneimrh/TCP-Chat_Server/shared"
 )
 
+var (
+	broadcastFanout = metrics.NewHistogram("chat_broadcast_fanout_seconds", "Time taken to hand a message to every member of a room.", metrics.DefaultBuckets)
+	broadcastDrops  = metrics.NewCounterVec("chat_broadcast_drops_total", "Members dropped from a room because their send queue was full.", "room")
+)
+
 type Room struct {
-	Name       string
-	Clients    map[*shared.Client]bool
-	Broadcast  chan shared.Message
-	Register   chan *shared.Client
-	Unregister chan *shared.Client
-	mu         sync.Mutex
+	Name        string
+	Clients     map[*shared.Client]bool
+	Broadcast   chan shared.Message
+	Register    chan *shared.Client
+	Unregister  chan *shared.Client
+	quit        chan struct{}
+	probe       chan chan struct{}
+	running     atomic.Bool
+	slowMode    time.Duration
+	topic       string
+	lastPost    map[string]time.Time
+	observers   func() []Observer
+	newID       func() string
+	history     []Post
+	historySize int
+	mu          sync.Mutex
+}
+
+// Observer is told about every message posted to a room, after it has been
+// delivered to the members, and about edits and deletes as EditMessage and
+// DeleteMessage frames. It is called from the room's Run loop, so it must
+// not block.
+type Observer interface {
+	RoomMessage(room string, message shared.Message)
 }
 
 func NewRoom(name string) *Room {
 	return &Room{
-		Name:       name,
-		Clients:    make(map[*shared.Client]bool),
-		Broadcast:  make(chan shared.Message, 100),
-		Register:   make(chan *shared.Client),
-		Unregister: make(chan *shared.Client),
+		Name:        name,
+		Clients:     make(map[*shared.Client]bool),
+		Broadcast:   make(chan shared.Message, 100),
+		Register:    make(chan *shared.Client),
+		Unregister:  make(chan *shared.Client),
+		quit:        make(chan struct{}),
+		probe:       make(chan chan struct{}),
+		lastPost:    make(map[string]time.Time),
+		historySize: DefaultHistorySize,
 	}
 }
 
 func (r *Room) Run() {
+	r.running.Store(true)
+	defer r.running.Store(false)
+
 	for {
 		select {
 		case client := <-r.Register:
@@ -34,10 +71,96 @@ func (r *Room) Run() {
 			r.unregisterClient(client)
 		case message := <-r.Broadcast:
 			r.broadcastMessage(message)
+		case done := <-r.probe:
+			close(done)
+		case <-r.quit:
+			return
 		}
 	}
 }
 
+func (r *Room) Stop() {
+	close(r.quit)
+}
+
+// ErrDeleted is returned to a caller that reached a room through a
+// reference taken before the room was deleted.
+var ErrDeleted = errors.New("room was deleted")
+
+// join, leave and publish hand a request to Run. Once the room has been
+// stopped nothing drains its channels, so they fail rather than block.
+func (r *Room) join(client *shared.Client) error {
+	if r.stopped() {
+		return ErrDeleted
+	}
+	select {
+	case r.Register <- client:
+		return nil
+	case <-r.quit:
+		return ErrDeleted
+	}
+}
+
+func (r *Room) leave(client *shared.Client) error {
+	if r.stopped() {
+		return ErrDeleted
+	}
+	select {
+	case r.Unregister <- client:
+		return nil
+	case <-r.quit:
+		return ErrDeleted
+	}
+}
+
+func (r *Room) publish(message shared.Message) error {
+	if r.stopped() {
+		return ErrDeleted
+	}
+	select {
+	case r.Broadcast <- message:
+		return nil
+	case <-r.quit:
+		return ErrDeleted
+	}
+}
+
+// stopped is checked first because a select picks at random between a
+// closed quit and a Broadcast buffer with room to spare.
+func (r *Room) stopped() bool {
+	select {
+	case <-r.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+func (r *Room) Running() bool {
+	return r.running.Load()
+}
+
+// Probe checks that Run is still draining its channels by asking it to
+// acknowledge a request within timeout.
+func (r *Room) Probe(timeout time.Duration) error {
+	done := make(chan struct{})
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case r.probe <- done:
+	case <-timer.C:
+		return fmt.Errorf("room %s is not responding", r.Name)
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("room %s is not responding", r.Name)
+	}
+}
+
 func (r *Room) registerClient(client *shared.Client) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -45,14 +168,7 @@ func (r *Room) registerClient(client *shared.Client) {
 	r.Clients[client] = true
 	client.AddRoom(r.Name)
 
-	joinMsg := shared.Message{
-		Type:     shared.TextMessage,
-		Sender:   "Server",
-		RoomName: r.Name,
-		Content:  client.Username + " has joined the room.",
-	}
-
-	r.broadcastToClients(joinMsg)
+	r.broadcastToClients(JoinedNotice(r.Name, client.Username))
 }
 
 func (r *Room) unregisterClient(client *shared.Client) {
@@ -63,37 +179,107 @@ func (r *Room) unregisterClient(client *shared.Client) {
 		delete(r.Clients, client)
 		client.RemoveRoom(r.Name)
 
-		leaveMsg := shared.Message{
-			Type:     shared.TextMessage,
-			Sender:   "Server",
-			RoomName: r.Name,
-			Content:  client.Username + " has left the room.",
-		}
-
-		r.broadcastToClients(leaveMsg)
+		r.broadcastToClients(LeftNotice(r.Name, client.Username))
 	}
 }
 
 func (r *Room) broadcastMessage(message shared.Message) {
 	r.mu.Lock()
-	defer r.mu.Unlock()
-
+	r.record(&message)
 	r.broadcastToClients(message)
+	r.mu.Unlock()
+
+	r.notify(message)
+}
+
+// notify passes a message to the observers. r.mu must not be held.
+func (r *Room) notify(message shared.Message) {
+	if r.observers != nil {
+		for _, observer := range r.observers() {
+			observer.RoomMessage(r.Name, message)
+		}
+	}
 }
 
 func (r *Room) broadcastToClients(message shared.Message) {
+	start := time.Now()
+	defer func() {
+		broadcastFanout.Observe(time.Since(start).Seconds())
+	}()
+
 	for client := range r.Clients {
 		select {
 		case client.Send <- message:
 		default:
 			delete(r.Clients, client)
 			client.RemoveRoom(r.Name)
+			broadcastDrops.With(r.Name).Inc()
+			slog.Warn("Dropped slow client from room", "room", r.Name, "user", client.Username)
 		}
 	}
 }
 
+func (r *Room) Members() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	members := make([]string, 0, len(r.Clients))
+	for client := range r.Clients {
+		members = append(members, client.Username)
+	}
+	sort.Strings(members)
+	return members
+}
+
 func (r *Room) GetClientCount() int {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	return len(r.Clients)
 }
+
+func (r *Room) SetTopic(topic string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.topic = topic
+}
+
+func (r *Room) Topic() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.topic
+}
+
+func (r *Room) SetSlowMode(interval time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.slowMode = interval
+	r.lastPost = make(map[string]time.Time)
+}
+
+func (r *Room) SlowMode() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.slowMode
+}
+
+// AllowPost records a post by username when slow mode permits it, otherwise
+// it returns how long the user still has to wait.
+func (r *Room) AllowPost(username string) (time.Duration, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.slowMode <= 0 {
+		return 0, true
+	}
+
+	now := time.Now()
+	if last, ok := r.lastPost[username]; ok {
+		if wait := r.slowMode - now.Sub(last); wait > 0 {
+			return wait, false
+		}
+	}
+
+	r.lastPost[username] = now
+	return 0, true
+}
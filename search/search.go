@@ -0,0 +1,427 @@
+package search
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
+)
+
+type Config struct {
+	// MaxDocuments caps how many messages the index keeps. The oldest are
+	// forgotten first.
+	MaxDocuments int
+	// PageSize is how many results a page holds.
+	PageSize int
+	// Context is how many messages of the same conversation are returned
+	// before and after each result.
+	Context int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		MaxDocuments: 10000,
+		PageSize:     10,
+		Context:      1,
+	}
+}
+
+// Document is an indexed message. Room is empty for direct messages, which
+// have a To instead.
+type Document struct {
+	ID       string
+	ParentID string
+	Room     string
+	From     string
+	To       string
+	Text     string
+	Time     time.Time
+}
+
+// Index is an inverted index from words to the messages that contain them.
+// It is kept up to date as messages are posted, edited and deleted.
+type Index struct {
+	config Config
+
+	mu    sync.RWMutex
+	seq   uint64
+	docs  map[uint64]*Document
+	ids   map[string]uint64
+	terms map[string]map[uint64]struct{}
+	// order holds document numbers oldest first. Numbers of removed
+	// documents are skipped when the index is trimmed.
+	order []uint64
+}
+
+func NewIndex(config Config) *Index {
+	return &Index{
+		config: config,
+		docs:   make(map[uint64]*Document),
+		ids:    make(map[string]uint64),
+		terms:  make(map[string]map[uint64]struct{}),
+	}
+}
+
+// Add indexes a message.
+func (x *Index) Add(doc Document) {
+	if x == nil {
+		return
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if doc.ID != "" {
+		if _, exists := x.ids[doc.ID]; exists {
+			return
+		}
+	}
+
+	x.seq++
+	n := x.seq
+	x.docs[n] = &doc
+	if doc.ID != "" {
+		x.ids[doc.ID] = n
+	}
+	x.order = append(x.order, n)
+	x.link(n, doc.Text)
+
+	for x.config.MaxDocuments > 0 && len(x.docs) > x.config.MaxDocuments {
+		oldest := x.order[0]
+		x.order = x.order[1:]
+		x.remove(oldest)
+	}
+}
+
+// Edit replaces the text of message id.
+func (x *Index) Edit(id, text string) {
+	if x == nil {
+		return
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	n, exists := x.ids[id]
+	if !exists {
+		return
+	}
+	x.unlink(n, x.docs[n].Text)
+	x.docs[n].Text = text
+	x.link(n, text)
+}
+
+// Remove forgets message id.
+func (x *Index) Remove(id string) {
+	if x == nil {
+		return
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if n, exists := x.ids[id]; exists {
+		x.remove(n)
+	}
+}
+
+// Len returns how many messages are indexed.
+func (x *Index) Len() int {
+	if x == nil {
+		return 0
+	}
+
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return len(x.docs)
+}
+
+// RoomMessage makes the Index a room.Observer so that it follows posts,
+// edits and deletes in every room.
+func (x *Index) RoomMessage(room string, message shared.Message) {
+	if message.ID == "" {
+		return
+	}
+
+	switch message.Type {
+	case shared.TextMessage:
+		x.Add(Document{
+			ID:       message.ID,
+			ParentID: message.ParentID,
+			Room:     room,
+			From:     message.Sender,
+			Text:     message.Content,
+			Time:     time.Now(),
+		})
+	case shared.EditMessage:
+		x.Edit(message.ID, message.Content)
+	case shared.DeleteMessage:
+		x.Remove(message.ID)
+	}
+}
+
+// remove drops document n. x.mu must be held.
+func (x *Index) remove(n uint64) {
+	doc, exists := x.docs[n]
+	if !exists {
+		return
+	}
+	x.unlink(n, doc.Text)
+	delete(x.docs, n)
+	delete(x.ids, doc.ID)
+
+	// Keep order from growing with removed documents.
+	if len(x.order) > 2*len(x.docs)+64 {
+		live := x.order[:0]
+		for _, m := range x.order {
+			if _, ok := x.docs[m]; ok {
+				live = append(live, m)
+			}
+		}
+		x.order = live
+	}
+}
+
+func (x *Index) link(n uint64, text string) {
+	for _, term := range Terms(text) {
+		postings := x.terms[term]
+		if postings == nil {
+			postings = make(map[uint64]struct{})
+			x.terms[term] = postings
+		}
+		postings[n] = struct{}{}
+	}
+}
+
+func (x *Index) unlink(n uint64, text string) {
+	for _, term := range Terms(text) {
+		delete(x.terms[term], n)
+		if len(x.terms[term]) == 0 {
+			delete(x.terms, term)
+		}
+	}
+}
+
+// Terms splits text into the distinct lower-case words it is indexed by.
+func Terms(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	seen := make(map[string]bool, len(words))
+	terms := words[:0]
+	for _, word := range words {
+		if !seen[word] {
+			seen[word] = true
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// maxPage bounds the page: numbers a query can give, which keeps the paging
+// arithmetic from overflowing.
+const maxPage = 1_000_000
+
+// Query is a parsed search. Every term must appear in a result.
+type Query struct {
+	Terms  []string
+	Room   string
+	From   string
+	Before time.Time
+	After  time.Time
+	Page   int
+}
+
+// ParseQuery reads "words [in:<room>] [from:<user>] [before:<date>]
+// [after:<date>] [page:<n>]". Dates are YYYY-MM-DD, meaning before the
+// start or after the end of that day, or RFC 3339 times.
+func ParseQuery(s string) (Query, error) {
+	q := Query{Page: 1}
+	for _, field := range strings.Fields(s) {
+		key, value, ok := strings.Cut(field, ":")
+		if !ok || value == "" {
+			q.Terms = append(q.Terms, Terms(field)...)
+			continue
+		}
+
+		var err error
+		switch strings.ToLower(key) {
+		case "in":
+			q.Room = value
+		case "from":
+			q.From = strings.TrimPrefix(value, "@")
+		case "before":
+			q.Before, err = parseDate(value, false)
+		case "after":
+			q.After, err = parseDate(value, true)
+		case "page":
+			q.Page, err = strconv.Atoi(value)
+			if err != nil || q.Page < 1 || q.Page > maxPage {
+				err = fmt.Errorf("invalid page '%s'", value)
+			}
+		default:
+			q.Terms = append(q.Terms, Terms(field)...)
+		}
+		if err != nil {
+			return Query{}, err
+		}
+	}
+
+	if len(q.Terms) == 0 && q.Room == "" && q.From == "" && q.Before.IsZero() && q.After.IsZero() {
+		return Query{}, fmt.Errorf("nothing to search for")
+	}
+	return q, nil
+}
+
+// parseDate reads a day or an RFC 3339 time. A day used as an after: bound
+// means its end.
+func parseDate(value string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date '%s', use YYYY-MM-DD", value)
+	}
+	if end {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day, nil
+}
+
+// Result is a matching document with up to Config.Context messages of the
+// same conversation from before and after it, oldest first.
+type Result struct {
+	Document
+	Before []Document
+	After  []Document
+}
+
+// Page is one page of results, newest first.
+type Page struct {
+	Results []Result
+	Total   int
+	Page    int
+	Pages   int
+}
+
+// Search returns the page of documents matching q that canRead allows.
+func (x *Index) Search(q Query, canRead func(Document) bool) Page {
+	if x == nil {
+		return Page{Page: q.Page}
+	}
+
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	var matches []uint64
+	for _, n := range x.candidates(q.Terms) {
+		doc := x.docs[n]
+		switch {
+		case q.Room != "" && doc.Room != q.Room,
+			q.From != "" && doc.From != q.From,
+			!q.Before.IsZero() && !doc.Time.Before(q.Before),
+			!q.After.IsZero() && doc.Time.Before(q.After),
+			!canRead(*doc):
+			continue
+		}
+		matches = append(matches, n)
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i] > matches[j] })
+
+	size := x.config.PageSize
+	if size <= 0 {
+		size = len(matches) + 1
+	}
+
+	page := Page{Total: len(matches), Page: q.Page, Pages: (len(matches) + size - 1) / size}
+	if q.Page < 1 || q.Page > page.Pages {
+		return page
+	}
+
+	start := (q.Page - 1) * size
+	for _, n := range matches[start:min(start+size, len(matches))] {
+		result := Result{Document: *x.docs[n]}
+		result.Before, result.After = x.around(n, x.config.Context)
+		page.Results = append(page.Results, result)
+	}
+	return page
+}
+
+// around returns up to count documents of the same conversation as document
+// n from either side of it, oldest first. x.mu must be held.
+func (x *Index) around(n uint64, count int) (before, after []Document) {
+	if count <= 0 {
+		return nil, nil
+	}
+
+	doc := x.docs[n]
+	i, _ := slices.BinarySearch(x.order, n)
+	for j := i - 1; j >= 0 && len(before) < count; j-- {
+		if other, exists := x.docs[x.order[j]]; exists && sameConversation(doc, other) {
+			before = append(before, *other)
+		}
+	}
+	slices.Reverse(before)
+
+	for j := i + 1; j < len(x.order) && len(after) < count; j++ {
+		if other, exists := x.docs[x.order[j]]; exists && sameConversation(doc, other) {
+			after = append(after, *other)
+		}
+	}
+	return before, after
+}
+
+// sameConversation reports whether a and b were posted to the same room or
+// sent between the same two users.
+func sameConversation(a, b *Document) bool {
+	if a.Room != "" || b.Room != "" {
+		return a.Room == b.Room
+	}
+	return a.From == b.From && a.To == b.To || a.From == b.To && a.To == b.From
+}
+
+// candidates returns the documents holding every term, or every document
+// when there are no terms. x.mu must be held.
+func (x *Index) candidates(terms []string) []uint64 {
+	if len(terms) == 0 {
+		all := make([]uint64, 0, len(x.docs))
+		for n := range x.docs {
+			all = append(all, n)
+		}
+		return all
+	}
+
+	// Start from the rarest term so the intersection stays small.
+	sets := make([]map[uint64]struct{}, len(terms))
+	for i, term := range terms {
+		sets[i] = x.terms[term]
+		if len(sets[i]) == 0 {
+			return nil
+		}
+	}
+	sort.Slice(sets, func(i, j int) bool { return len(sets[i]) < len(sets[j]) })
+
+	var found []uint64
+	for n := range sets[0] {
+		inAll := true
+		for _, set := range sets[1:] {
+			if _, ok := set[n]; !ok {
+				inAll = false
+				break
+			}
+		}
+		if inAll {
+			found = append(found, n)
+		}
+	}
+	return found
+}
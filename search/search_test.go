@@ -0,0 +1,263 @@
+package search
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func everything(Document) bool { return true }
+
+func ids(docs []Document) []string {
+	var out []string
+	for _, doc := range docs {
+		out = append(out, doc.ID)
+	}
+	return out
+}
+
+func resultIDs(page Page) []string {
+	var out []string
+	for _, result := range page.Results {
+		out = append(out, result.ID)
+	}
+	return out
+}
+
+// roomIndex indexes n messages in room general, numbered 1 to n and all
+// containing "hello".
+func roomIndex(config Config, n int) *Index {
+	x := NewIndex(config)
+	for i := 1; i <= n; i++ {
+		x.Add(Document{ID: strconv.Itoa(i), Room: "general", From: "alice", Text: fmt.Sprintf("hello %d", i)})
+	}
+	return x
+}
+
+func TestTerms(t *testing.T) {
+	got := Terms("Hello, hello WORLD! It's 2024-01-02.")
+	want := []string{"hello", "world", "it", "s", "2024", "01", "02"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("Terms() = %q, want %q", got, want)
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Query
+		wantErr bool
+	}{
+		{input: "Deploy failed", want: Query{Terms: []string{"deploy", "failed"}, Page: 1}},
+		{input: "in:ops from:@bob", want: Query{Room: "ops", From: "bob", Page: 1}},
+		{input: "deploy page:3", want: Query{Terms: []string{"deploy"}, Page: 3}},
+		{input: "see: https://example.com", want: Query{Terms: []string{"see", "https", "example", "com"}, Page: 1}},
+		{input: "deploy page:0", wantErr: true},
+		{input: "deploy page:-1", wantErr: true},
+		{input: "deploy page:two", wantErr: true},
+		{input: "deploy page:1000001", wantErr: true},
+		{input: "deploy page:" + strconv.Itoa(math.MaxInt), wantErr: true},
+		{input: "deploy page:99999999999999999999", wantErr: true},
+		{input: "deploy before:yesterday", wantErr: true},
+		{input: "page:2", wantErr: true},
+		{input: "   ", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseQuery(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseQuery() = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseQuery() error = %v", err)
+			}
+			if !slices.Equal(got.Terms, tt.want.Terms) || got.Room != tt.want.Room || got.From != tt.want.From || got.Page != tt.want.Page {
+				t.Fatalf("ParseQuery() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseQueryDates(t *testing.T) {
+	q, err := ParseQuery("after:2024-01-02 before:2024-01-05T10:00:00Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 1, 3, 0, 0, 0, 0, time.Local); !q.After.Equal(want) {
+		t.Errorf("After = %v, want the end of the day %v", q.After, want)
+	}
+	if want := time.Date(2024, 1, 5, 10, 0, 0, 0, time.UTC); !q.Before.Equal(want) {
+		t.Errorf("Before = %v, want %v", q.Before, want)
+	}
+}
+
+func TestSearchPaging(t *testing.T) {
+	config := Config{MaxDocuments: 100, PageSize: 3}
+	x := roomIndex(config, 7)
+
+	tests := []struct {
+		page  int
+		want  []string
+		pages int
+	}{
+		{1, []string{"7", "6", "5"}, 3},
+		{2, []string{"4", "3", "2"}, 3},
+		{3, []string{"1"}, 3},
+		{4, nil, 3},
+		{0, nil, 3},
+		{-5, nil, 3},
+		{maxPage, nil, 3},
+		{math.MaxInt, nil, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.page), func(t *testing.T) {
+			page := x.Search(Query{Terms: []string{"hello"}, Page: tt.page}, everything)
+			if got := resultIDs(page); !slices.Equal(got, tt.want) {
+				t.Errorf("results = %q, want %q", got, tt.want)
+			}
+			if page.Total != 7 || page.Pages != tt.pages || page.Page != tt.page {
+				t.Errorf("page = %d of %d with %d results, want %d of %d with 7", page.Page, page.Pages, page.Total, tt.page, tt.pages)
+			}
+		})
+	}
+}
+
+func TestSearchUnpaged(t *testing.T) {
+	x := roomIndex(Config{MaxDocuments: 100}, 4)
+
+	page := x.Search(Query{Terms: []string{"hello"}, Page: 1}, everything)
+	if got := resultIDs(page); !slices.Equal(got, []string{"4", "3", "2", "1"}) || page.Pages != 1 {
+		t.Fatalf("results = %q in %d pages", got, page.Pages)
+	}
+
+	empty := x.Search(Query{Terms: []string{"missing"}, Page: 1}, everything)
+	if len(empty.Results) != 0 || empty.Total != 0 || empty.Pages != 0 {
+		t.Fatalf("search without matches = %+v", empty)
+	}
+}
+
+func TestSearchFilters(t *testing.T) {
+	x := NewIndex(DefaultConfig())
+	day := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	x.Add(Document{ID: "1", Room: "general", From: "alice", Text: "deploy started", Time: day})
+	x.Add(Document{ID: "2", Room: "ops", From: "bob", Text: "deploy failed", Time: day.AddDate(0, 0, 1)})
+	x.Add(Document{ID: "3", From: "bob", To: "carol", Text: "the deploy failed again", Time: day.AddDate(0, 0, 2)})
+	x.Add(Document{ID: "4", Room: "general", From: "bob", Text: "lunch?", Time: day.AddDate(0, 0, 3)})
+
+	tests := []struct {
+		name    string
+		query   Query
+		canRead func(Document) bool
+		want    []string
+	}{
+		{"every term", Query{Terms: []string{"deploy", "failed"}}, everything, []string{"3", "2"}},
+		{"missing term", Query{Terms: []string{"deploy", "rollback"}}, everything, nil},
+		{"room", Query{Terms: []string{"deploy"}, Room: "ops"}, everything, []string{"2"}},
+		{"sender without terms", Query{From: "bob"}, everything, []string{"4", "3", "2"}},
+		{"before", Query{Terms: []string{"deploy"}, Before: day.AddDate(0, 0, 1)}, everything, []string{"1"}},
+		{"after", Query{Terms: []string{"deploy"}, After: day.AddDate(0, 0, 1)}, everything, []string{"3", "2"}},
+		{"unreadable", Query{Terms: []string{"deploy"}}, func(doc Document) bool { return doc.Room == "general" }, []string{"1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.query.Page = 1
+			if got := resultIDs(x.Search(tt.query, tt.canRead)); !slices.Equal(got, tt.want) {
+				t.Fatalf("results = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndexFollowsChanges(t *testing.T) {
+	x := NewIndex(Config{MaxDocuments: 3, PageSize: 10})
+	for i := 1; i <= 3; i++ {
+		x.Add(Document{ID: strconv.Itoa(i), Room: "general", Text: "old text"})
+	}
+	x.Add(Document{ID: "1", Room: "general", Text: "duplicate"})
+
+	x.Edit("2", "new text")
+	x.Remove("3")
+	x.Add(Document{ID: "4", Room: "general", Text: "old text"})
+	x.Add(Document{ID: "5", Room: "general", Text: "old text"})
+
+	if x.Len() != 3 {
+		t.Fatalf("Len() = %d, want 3", x.Len())
+	}
+	if got := resultIDs(x.Search(Query{Terms: []string{"old"}, Page: 1}, everything)); !slices.Equal(got, []string{"5", "4"}) {
+		t.Errorf("old text = %q, want the two newest after trimming", got)
+	}
+	if got := resultIDs(x.Search(Query{Terms: []string{"new"}, Page: 1}, everything)); !slices.Equal(got, []string{"2"}) {
+		t.Errorf("new text = %q, want the edited message", got)
+	}
+	if got := resultIDs(x.Search(Query{Terms: []string{"duplicate"}, Page: 1}, everything)); got != nil {
+		t.Errorf("duplicate ID was indexed: %q", got)
+	}
+}
+
+func TestSearchContext(t *testing.T) {
+	x := NewIndex(Config{MaxDocuments: 100, PageSize: 10, Context: 2})
+	x.Add(Document{ID: "1", Room: "general", From: "alice", Text: "morning"})
+	x.Add(Document{ID: "2", Room: "ops", From: "bob", Text: "pager went off"})
+	x.Add(Document{ID: "3", Room: "general", From: "bob", Text: "coffee?"})
+	x.Add(Document{ID: "4", From: "alice", To: "bob", Text: "are you around"})
+	x.Add(Document{ID: "5", Room: "general", From: "carol", Text: "the build is red"})
+	x.Add(Document{ID: "6", From: "bob", To: "alice", Text: "yes"})
+	x.Add(Document{ID: "7", From: "bob", To: "carol", Text: "hi"})
+	x.Add(Document{ID: "8", Room: "general", From: "alice", Text: "deleted"})
+	x.Add(Document{ID: "9", Room: "general", From: "alice", Text: "fixed it"})
+	x.Remove("8")
+
+	tests := []struct {
+		term          string
+		before, after []string
+	}{
+		{"build", []string{"1", "3"}, []string{"9"}},
+		{"morning", nil, []string{"3", "5"}},
+		{"pager", nil, nil},
+		{"around", nil, []string{"6"}},
+		{"yes", []string{"4"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.term, func(t *testing.T) {
+			page := x.Search(Query{Terms: []string{tt.term}, Page: 1}, everything)
+			if len(page.Results) != 1 {
+				t.Fatalf("results = %q, want one", resultIDs(page))
+			}
+			result := page.Results[0]
+			if got := ids(result.Before); !slices.Equal(got, tt.before) {
+				t.Errorf("Before = %q, want %q", got, tt.before)
+			}
+			if got := ids(result.After); !slices.Equal(got, tt.after) {
+				t.Errorf("After = %q, want %q", got, tt.after)
+			}
+		})
+	}
+
+	x.config.Context = 0
+	if result := x.Search(Query{Terms: []string{"build"}, Page: 1}, everything).Results[0]; result.Before != nil || result.After != nil {
+		t.Errorf("context with Context 0 = %q, %q", ids(result.Before), ids(result.After))
+	}
+}
+
+func TestNilIndex(t *testing.T) {
+	var x *Index
+	x.Add(Document{ID: "1", Text: "hello"})
+	x.Edit("1", "bye")
+	x.Remove("1")
+	if x.Len() != 0 {
+		t.Fatal("nil index has documents")
+	}
+	if page := x.Search(Query{Terms: []string{"hello"}, Page: 2}, everything); page.Page != 2 || len(page.Results) != 0 {
+		t.Fatalf("nil index Search() = %+v", page)
+	}
+}
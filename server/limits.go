@@ -0,0 +1,131 @@
+package server
+
+import (
+	"encoding/json"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/imaneimrh/TCP-Chat_Server/metrics"
+	"github.com/imaneimrh/TCP-Chat_Server/ratelimit"
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
+)
+
+var connectionsRejected = metrics.NewCounter("chat_connections_rejected_total", "Connections refused because a connection limit was reached.")
+
+type Limits struct {
+	MaxConnections      int
+	MaxConnectionsPerIP int
+	AcceptRate          float64
+	AcceptBurst         int
+}
+
+func DefaultLimits() Limits {
+	return Limits{
+		MaxConnections:      1000,
+		MaxConnectionsPerIP: 10,
+		AcceptRate:          20,
+		AcceptBurst:         50,
+	}
+}
+
+// trackedConn releases its connection slot exactly once, however many times
+// the handler's goroutines close it.
+type trackedConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (c *trackedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
+
+func (s *Server) admit(ip string) string {
+	s.acceptsOnce.Do(func() {
+		s.accepts = ratelimit.NewBucket(s.Limits.AcceptRate, s.Limits.AcceptBurst)
+	})
+	if !s.accepts.Allow() {
+		return "Too many connection attempts, please try again later."
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Limits.MaxConnections > 0 && s.active >= s.Limits.MaxConnections {
+		return "Server is full, please try again later."
+	}
+
+	if ip != "" && s.Limits.MaxConnectionsPerIP > 0 && s.perIP[ip] >= s.Limits.MaxConnectionsPerIP {
+		return "Too many connections from your address, please try again later."
+	}
+
+	s.active++
+	if ip != "" {
+		s.perIP[ip]++
+	}
+	return ""
+}
+
+func (s *Server) release(ip string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.active--
+	if ip == "" {
+		return
+	}
+	s.perIP[ip]--
+	if s.perIP[ip] <= 0 {
+		delete(s.perIP, ip)
+	}
+}
+
+func (s *Server) ConnectionCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.active
+}
+
+func (s *Server) ConnectionsByIP() map[string]int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[string]int, len(s.perIP))
+	for ip, n := range s.perIP {
+		counts[ip] = n
+	}
+	return counts
+}
+
+func rejectConn(conn net.Conn, reason string) {
+	defer conn.Close()
+
+	data, err := json.Marshal(shared.Message{
+		Type:    shared.TextMessage,
+		Sender:  "Server",
+		Content: reason,
+	})
+	if err != nil {
+		return
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(2 * time.Second))
+	conn.Write(append(data, '\n'))
+}
+
+func remoteIP(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+func (s *Server) RegisterMetrics() {
+	metrics.NewGaugeFunc("chat_connections_active", "Open client connections.", func() float64 {
+		return float64(s.ConnectionCount())
+	})
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/imaneimrh/TCP-Chat_Server/client"
+)
+
+// Listener is one address the server accepts chat connections on.
+type Listener struct {
+	Network string
+	Address string
+	// Mode sets the permissions of a Unix socket file. Zero keeps the
+	// process umask.
+	Mode os.FileMode
+	// PeerUsers maps the uid of a Unix socket peer, read with SO_PEERCRED,
+	// to the account it is logged in as without a password.
+	PeerUsers map[uint32]string
+}
+
+// ParseListener accepts "tcp://host:port", "unix:///path/to/socket" or a bare
+// "host:port".
+func ParseListener(spec string) (Listener, error) {
+	switch {
+	case strings.HasPrefix(spec, "unix://"):
+		path := strings.TrimPrefix(spec, "unix://")
+		if path == "" {
+			return Listener{}, fmt.Errorf("listener '%s' has no socket path", spec)
+		}
+		return Listener{Network: "unix", Address: path}, nil
+	case strings.HasPrefix(spec, "tcp://"):
+		return Listener{Network: "tcp", Address: strings.TrimPrefix(spec, "tcp://")}, nil
+	case strings.Contains(spec, "://"):
+		return Listener{}, fmt.Errorf("listener '%s' has an unsupported scheme", spec)
+	default:
+		return Listener{Network: "tcp", Address: spec}, nil
+	}
+}
+
+func (l Listener) String() string {
+	return l.Network + "://" + l.Address
+}
+
+func (l Listener) listen() (net.Listener, error) {
+	if l.Network != "unix" {
+		return net.Listen(l.Network, l.Address)
+	}
+
+	if err := removeStaleSocket(l.Address); err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", l.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	if l.Mode != 0 {
+		if err := os.Chmod(l.Address, l.Mode); err != nil {
+			listener.Close()
+			return nil, fmt.Errorf("failed to set socket permissions: %w", err)
+		}
+	}
+	return listener, nil
+}
+
+// removeStaleSocket deletes a socket file left behind by a previous run, but
+// refuses to touch one that another process is still serving.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is already in use", path)
+	}
+	return os.Remove(path)
+}
+
+// peerConn logs a Unix socket session in as the account mapped to its uid.
+type peerConn struct {
+	*client.LineConn
+	username string
+}
+
+func (c *peerConn) PeerUsername() string {
+	return c.username
+}
@@ -1,55 +1,157 @@
 package server
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net"
 	"sync"
+	"sync/atomic"
 
+	"github.com/imaneimrh/TCP-Chat_Server/access"
 	"github.com/imaneimrh/TCP-Chat_Server/auth"
+	"github.com/imaneimrh/TCP-Chat_Server/client"
+	"github.com/imaneimrh/TCP-Chat_Server/ratelimit"
 	"github.com/imaneimrh/TCP-Chat_Server/room"
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
 )
 
+var ErrNotAllowed = errors.New("connection refused: you are not allowed to connect to this server")
+
 type ClientHandler interface {
 	HandleClient(conn net.Conn)
+	ServeConn(conn shared.MessageConn)
 	Run()
 }
 
 type Server struct {
-	Addr        string
+	Listeners   []Listener
 	Handler     ClientHandler
 	RoomManager *room.Manager
 	AuthManager *auth.Manager
+	Access      *access.Control
+	Limits      Limits
+	accepts     *ratelimit.Bucket
+	acceptsOnce sync.Once
+	active      int
+	perIP       map[string]int
+	ready       atomic.Bool
 	mu          sync.RWMutex
 }
 
 func NewServerWithHandler(addr string, handler ClientHandler, roomManager *room.Manager, authManager *auth.Manager) *Server {
 	return &Server{
-		Addr:        addr,
+		Listeners:   []Listener{{Network: "tcp", Address: addr}},
 		Handler:     handler,
 		RoomManager: roomManager,
 		AuthManager: authManager,
+		Limits:      DefaultLimits(),
+		perIP:       make(map[string]int),
 	}
 }
 
+// Ready reports whether the listener is bound and accepting connections.
+func (s *Server) Ready() bool {
+	return s.ready.Load()
+}
+
+// Start binds every listener, failing if any of them cannot be bound, and
+// then serves them all until one stops.
 func (s *Server) Start() error {
-	listener, err := net.Listen("tcp", s.Addr)
-	if err != nil {
-		return err
+	listeners := make([]net.Listener, 0, len(s.Listeners))
+	defer func() {
+		for _, listener := range listeners {
+			listener.Close()
+		}
+	}()
+
+	for _, l := range s.Listeners {
+		listener, err := l.listen()
+		if err != nil {
+			return fmt.Errorf("%s: %w", l, err)
+		}
+		listeners = append(listeners, listener)
 	}
-	defer listener.Close()
+	defer s.ready.Store(false)
 
-	log.Printf("TCP Chat Server started on %s", s.Addr)
-	log.Printf("The server supports authentication, rooms, direct messaging, and file transfers")
+	s.ready.Store(true)
 
+	errs := make(chan error, len(listeners))
+	for i, listener := range listeners {
+		slog.Info("Chat server listening", "addr", s.Listeners[i].String())
+		go func(l Listener, listener net.Listener) {
+			errs <- s.serve(l, listener)
+		}(s.Listeners[i], listener)
+	}
+
+	return <-errs
+}
+
+func (s *Server) serve(l Listener, listener net.Listener) error {
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
-			log.Printf("Error accepting connection: %v", err)
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			slog.Error("Error accepting connection", "addr", l.String(), "error", err)
+			continue
+		}
+
+		release, err := s.Admit(conn.RemoteAddr())
+		if err != nil {
+			go rejectConn(conn, err.Error())
 			continue
 		}
 
-		log.Printf("New connection from: %s", conn.RemoteAddr().String())
+		tracked := &trackedConn{
+			Conn:    conn,
+			release: release,
+		}
+
+		if len(l.PeerUsers) > 0 {
+			uid, err := peerUID(conn)
+			if err != nil {
+				slog.Warn("Could not read peer credentials", "addr", l.String(), "error", err)
+			} else if username, ok := l.PeerUsers[uid]; ok {
+				slog.Info("New connection", "addr", l.String(), "uid", uid, "user", username)
+				go s.Handler.ServeConn(&peerConn{
+					LineConn: client.NewLineConn(tracked),
+					username: username,
+				})
+				continue
+			}
+		}
+
+		slog.Info("New connection", "addr", l.String(), "remote", conn.RemoteAddr().String())
+
+		go s.Handler.HandleClient(tracked)
+	}
+}
+
+// Admit applies the access list and connection limits to a new connection,
+// whichever listener it arrived on. On success the returned release func must
+// be called once when the connection closes.
+func (s *Server) Admit(addr net.Addr) (func(), error) {
+	// Unix socket peers have no address, so IP rules and per-IP limits
+	// do not apply to them; the global limits still do.
+	ip := ""
+	if addr.Network() != "unix" {
+		ip = remoteIP(addr)
+	}
 
-		go s.Handler.HandleClient(conn)
+	if s.Access != nil && ip != "" {
+		if err := s.Access.CheckIP(net.ParseIP(ip)); err != nil {
+			slog.Warn("Refused connection", "remote", addr.String(), "error", err)
+			return nil, ErrNotAllowed
+		}
 	}
+
+	if reason := s.admit(ip); reason != "" {
+		connectionsRejected.Inc()
+		slog.Warn("Rejected connection", "remote", addr.String(), "reason", reason)
+		return nil, errors.New(reason)
+	}
+
+	return func() { s.release(ip) }, nil
 }
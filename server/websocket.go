@@ -0,0 +1,81 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
+	"github.com/imaneimrh/TCP-Chat_Server/websocket"
+)
+
+// trackedMessageConn is trackedConn for connections that are already framed.
+type trackedMessageConn struct {
+	shared.MessageConn
+	once    sync.Once
+	release func()
+}
+
+func (c *trackedMessageConn) Close() error {
+	err := c.MessageConn.Close()
+	c.once.Do(c.release)
+	return err
+}
+
+// WebSocketHandler upgrades HTTP requests to WebSocket connections served by
+// the same client handler, rooms and limits as the TCP listener. An empty
+// origins list accepts any Origin.
+func (s *Server) WebSocketHandler(origins []string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !originAllowed(r.Header.Get("Origin"), origins) {
+			slog.Warn("Refused WebSocket origin", "remote", r.RemoteAddr, "origin", r.Header.Get("Origin"))
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+
+		addr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr)
+		if err != nil {
+			http.Error(w, "invalid remote address", http.StatusBadRequest)
+			return
+		}
+
+		release, err := s.Admit(addr)
+		if err != nil {
+			status := http.StatusServiceUnavailable
+			if errors.Is(err, ErrNotAllowed) {
+				status = http.StatusForbidden
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		conn, err := websocket.Upgrade(w, r)
+		if err != nil {
+			release()
+			slog.Warn("WebSocket handshake failed", "remote", r.RemoteAddr, "error", err)
+			return
+		}
+
+		slog.Info("New WebSocket connection", "remote", r.RemoteAddr)
+
+		s.Handler.ServeConn(&trackedMessageConn{
+			MessageConn: conn,
+			release:     release,
+		})
+	})
+}
+
+func originAllowed(origin string, origins []string) bool {
+	if len(origins) == 0 {
+		return true
+	}
+	for _, allowed := range origins {
+		if strings.EqualFold(origin, allowed) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package shared
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+var ErrFrameTooLarge = errors.New("frame exceeds maximum size")
+
+// MessageConn carries whole protocol frames, each one JSON Message or command
+// line, so the handler does not depend on how a client is connected. A
+// trailing newline on a written frame is optional; transports that delimit
+// frames themselves drop it.
+type MessageConn interface {
+	ReadFrame(maxSize int) ([]byte, error)
+	WriteFrame(frame []byte) error
+	Close() error
+	RemoteAddr() net.Addr
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+}
+
+// LoginObserver is implemented by connections that translate another chat
+// protocol and must react to the outcome of a login, such as the IRC gateway.
+type LoginObserver interface {
+	LoginSucceeded(username string)
+	LoginFailed(reason string)
+}
+
+// PeerIdentifier is implemented by connections whose peer the operating
+// system has already identified, such as Unix socket peers matched by uid.
+// The session is logged in as PeerUsername without a password.
+type PeerIdentifier interface {
+	PeerUsername() string
+}
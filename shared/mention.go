@@ -0,0 +1,49 @@
+package shared
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ParseMentions finds the users a message mentions and whether it mentions
+// @room or @here. A mention starts with @ at the start of a word and runs to
+// the next space, less trailing punctuation. The server notifies mentioned
+// users and webhooks filter on mentions by this one set of rules.
+func ParseMentions(text string) (users []string, everyone, here bool) {
+	seen := make(map[string]bool)
+	for i, r := range text {
+		if r != '@' || (i > 0 && isMentionRune(lastRune(text[:i]))) {
+			continue
+		}
+
+		name := text[i+1:]
+		if end := strings.IndexFunc(name, unicode.IsSpace); end >= 0 {
+			name = name[:end]
+		}
+		name = strings.TrimRight(name, ".,:;!?)]}'\"")
+
+		switch {
+		case name == "" || seen[name]:
+		case name == "room":
+			everyone = true
+		case name == "here":
+			here = true
+		default:
+			users = append(users, name)
+		}
+		seen[name] = true
+	}
+	return users, everyone, here
+}
+
+// isMentionRune reports whether r can come right before an @ without it
+// starting a mention, as in an email address.
+func isMentionRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.'
+}
+
+func lastRune(s string) rune {
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return r
+}
@@ -0,0 +1,35 @@
+package shared
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		text     string
+		users    []string
+		everyone bool
+		here     bool
+	}{
+		{"hello @alice", []string{"alice"}, false, false},
+		{"@alice, @bob: look", []string{"alice", "bob"}, false, false},
+		{"@alice @alice", []string{"alice"}, false, false},
+		{"(@alice) and @bob!", []string{"alice", "bob"}, false, false},
+		{"ask @alice.", []string{"alice"}, false, false},
+		{"@Alice", []string{"Alice"}, false, false},
+		{"mail bob@example.com", nil, false, false},
+		{"see.@alice", nil, false, false},
+		{"@room deploy", nil, true, false},
+		{"@here and @alice", []string{"alice"}, false, true},
+		{"@ alone", nil, false, false},
+		{"no mentions", nil, false, false},
+	}
+
+	for _, tt := range tests {
+		users, everyone, here := ParseMentions(tt.text)
+		if !slices.Equal(users, tt.users) || everyone != tt.everyone || here != tt.here {
+			t.Errorf("ParseMentions(%q) = %q, %v, %v, want %q, %v, %v", tt.text, users, everyone, here, tt.users, tt.everyone, tt.here)
+		}
+	}
+}
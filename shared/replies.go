@@ -0,0 +1,99 @@
+package shared
+
+// Formats of the server's replies to commands and of the notices clients act
+// on. The server builds those messages from these formats and clients such as
+// chatclient recognise them by the same formats, so rewording one here
+// changes both sides together.
+const (
+	ReplyLoginRequired    = "You must login first. Use /login <username> <password> or register with /register <username> <password>"
+	ReplyLoginRequiredFor = "You must be logged in to use %s"
+	ReplyUsage            = "Usage: %s"
+	ReplyNotInRoom        = "You are not in room %s. Join it first with /join %s"
+	ReplyNotSent          = "Message not sent: %v"
+	ReplySlowMode         = "Room %s is in slow mode. You can post again in %d seconds."
+	ReplyProtocolError    = "Protocol error: %s. Disconnecting."
+	ReplyMuted            = "You have been muted for %s for flooding."
+
+	// Sent just before the server closes the connection. A kick may carry
+	// ": reason" after the format.
+	ReplyFlooding      = "You have been disconnected for flooding."
+	ReplyKicked        = "You have been %s by %s"
+	ReplyAdminKicked   = "You have been disconnected by an administrator"
+	ReplyAdminBanned   = "You have been banned by an administrator"
+	ReplyAddressBanned = "Your address has been banned by an administrator"
+
+	ReplyRegistered = "╔═════════════════════════════════════════╗\n" +
+		"║ Registration Successful!                 ║\n" +
+		"╠═════════════════════════════════════════╣\n" +
+		"║ Username: %-30s ║\n" +
+		"║                                         ║\n" +
+		"║ You can now login with:                 ║\n" +
+		"║ /login %s <password>                   ║\n" +
+		"╚═════════════════════════════════════════╝"
+	ReplyRegisterFailed     = "Registration failed: %v"
+	ReplyRegistrationClosed = "Registration is disabled on this server. Log in with your existing account."
+	ReplyUsernameTooShort   = "Username must be at least 3 characters long"
+	ReplyPasswordTooShort   = "Password must be at least 4 characters long"
+
+	ReplyLoggedIn = "╔═════════════════════════════════════════╗\n" +
+		"║           Login Successful!               ║\n" +
+		"╠═════════════════════════════════════════╣\n" +
+		"║ Welcome back, %-27s ║\n" +
+		"║                                         ║\n" +
+		"║ You've been added to the 'general' room  ║\n" +
+		"║ Type /help to see available commands     ║\n" +
+		"╚═════════════════════════════════════════╝"
+	ReplyLoginFailed     = "Login failed: %v"
+	ReplyAlreadyLoggedIn = "User '%s' is already logged in"
+
+	ReplyRoomCreated   = "Room created: %s"
+	ReplyCreateFailed  = "Error creating room: %v"
+	ReplyJoined        = "You have joined room: %s"
+	ReplyAlreadyInRoom = "You are already in room: %s"
+	ReplyJoinFailed    = "Error joining room: %v"
+	ReplyLeft          = "You have left room: %s"
+	ReplyNotMember     = "You are not in room: %s"
+	ReplyLeaveFailed   = "Error leaving room: %v"
+
+	ReplyDirectSent = "(To %s): %s"
+	ReplyNotOnline  = "User %s is not online."
+
+	ReplyEditFailed     = "Error editing message: %v"
+	ReplyDeleted        = "Message %s deleted from %s"
+	ReplyDeleteFailed   = "Error deleting message: %v"
+	ReplyReactFailed    = "Error reacting: %v"
+	ReplyUnreactFailed  = "Error removing reaction: %v"
+	ReplyReplyFailed    = "Error replying: %v"
+	ReplyThread         = "Thread [%s] in %s: %d %s"
+	ReplyThreadFailed   = "Error fetching thread: %v"
+	ReplyThreadActivity = "%s replied to thread [%s] in %s: %s"
+
+	ReplyAllRead    = "All conversations marked read"
+	ReplyReadFailed = "Error marking read: %v"
+	ReplyNoUnread   = "No unread messages"
+	ReplyUnread     = "Unread: %s"
+
+	ReplySearchDisabled = "Search is not enabled on this server"
+	ReplySearchFailed   = "Error searching: %v"
+	ReplyNoResults      = "Search: no results"
+	ReplySearchPage     = "Search: %d %s, page %d of %d%s"
+
+	ReplyFileReceived = "╔════════════════════════════════════════════════════╗\n" +
+		"║           File Transfer Complete                   ║\n" +
+		"╠════════════════════════════════════════════════════╣\n" +
+		"║ File: %-43s ║\n" +
+		"║ Size: %-7.2f KB                                 ║\n" +
+		"║ From: %-43s ║\n" +
+		"║                                                ║\n" +
+		"║ File saved to: downloads/%-27s ║\n" +
+		"╚════════════════════════════════════════════════════╝"
+	ReplyFileSent = "╔════════════════════════════════════════════════════╗\n" +
+		"║           File Transfer Complete                   ║\n" +
+		"╠════════════════════════════════════════════════════╣\n" +
+		"║ File: %-43s ║\n" +
+		"║ Size: %-7.2f KB                                 ║\n" +
+		"║ To:   %-43s ║\n" +
+		"║                                                ║\n" +
+		"║ File successfully transferred                   ║\n" +
+		"╚════════════════════════════════════════════════════╝"
+)
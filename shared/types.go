@@ -1,10 +1,14 @@
 package shared
 
 import (
-	"net"
+	"log/slog"
 	"sync"
 )
 
+// ServerName is the sender of the server's own messages. It is reserved, so
+// no user, bot or plugin can send under it.
+const ServerName = "Server"
+
 type MessageType int
 
 const (
@@ -17,8 +21,69 @@ const (
 	FileTransferRequest
 	FileTransferData
 	FileTransferComplete
+	PingMessage
+	PongMessage
+	EditMessage
+	DeleteMessage
+	HistoryMessage
+	ReactionMessage
+	MentionMessage
+	ReadMessage
 )
 
+func (t MessageType) String() string {
+	switch t {
+	case TextMessage:
+		return "text"
+	case JoinRoomMessage:
+		return "join_room"
+	case LeaveRoomMessage:
+		return "leave_room"
+	case CreateRoomMessage:
+		return "create_room"
+	case ListRoomsMessage:
+		return "list_rooms"
+	case DirectMessage:
+		return "direct"
+	case FileTransferRequest:
+		return "file_request"
+	case FileTransferData:
+		return "file_data"
+	case FileTransferComplete:
+		return "file_complete"
+	case PingMessage:
+		return "ping"
+	case PongMessage:
+		return "pong"
+	case EditMessage:
+		return "edit"
+	case DeleteMessage:
+		return "delete"
+	case HistoryMessage:
+		return "history"
+	case ReactionMessage:
+		return "reaction"
+	case MentionMessage:
+		return "mention"
+	case ReadMessage:
+		return "read"
+	default:
+		return "unknown"
+	}
+}
+
+// Message is one protocol frame. ID is assigned by the server to messages
+// posted to a room; EditMessage and DeleteMessage events carry the ID of the
+// message they change. ParentID marks a reply with the ID of the message
+// that started its thread. HistoryMessage frames replay stored messages in
+// answer to a request and are not new posts. Reactions counts the reactions
+// on message ID in ReactionMessage and HistoryMessage frames. A
+// MentionMessage tells Recipient that room message ID mentioned them. A
+// ReadMessage moves the read marker of RoomName, or of the direct
+// conversation with Recipient, to ID and counts the Unread messages after it.
+// Notice gives the meaning of a server notice about RoomName whose Content is
+// only meant to be displayed. Context marks a HistoryMessage sent around a
+// search result with the ID of that result.
 type Message struct {
 	Type       MessageType
 	Sender     string
@@ -29,22 +94,46 @@ type Message struct {
 	FileName   string
 	FileSize   int
 	FileOffset int
+	ID         string         `json:",omitempty"`
+	ParentID   string         `json:",omitempty"`
+	Reactions  map[string]int `json:",omitempty"`
+	Unread     int            `json:",omitempty"`
+	Notice     *Notice        `json:",omitempty"`
+	Context    string         `json:",omitempty"`
+}
+
+type NoticeKind string
+
+const (
+	NoticeJoined NoticeKind = "joined"
+	NoticeLeft   NoticeKind = "left"
+	NoticeTopic  NoticeKind = "topic"
+)
+
+// Notice is a membership or topic change in a room. Gateways and libraries
+// read it rather than parsing the display text of the message it comes with.
+type Notice struct {
+	Kind  NoticeKind
+	User  string
+	Topic string `json:",omitempty"`
 }
 
 type Client struct {
-	Conn     net.Conn
+	Conn     MessageConn
 	Username string
 	Rooms    map[string]bool
 	Send     chan Message
+	Log      *slog.Logger
 	mu       sync.Mutex
 }
 
-func NewClient(conn net.Conn) *Client {
+func NewClient(conn MessageConn) *Client {
 	return &Client{
 		Conn:     conn,
 		Username: "",
 		Rooms:    make(map[string]bool),
 		Send:     make(chan Message, 100),
+		Log:      slog.Default().With("remote", conn.RemoteAddr().String()),
 	}
 }
 
@@ -65,3 +154,14 @@ func (c *Client) IsInRoom(roomName string) bool {
 	defer c.mu.Unlock()
 	return c.Rooms[roomName]
 }
+
+func (c *Client) RoomList() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rooms := make([]string, 0, len(c.Rooms))
+	for roomName := range c.Rooms {
+		rooms = append(rooms, roomName)
+	}
+	return rooms
+}
@@ -2,9 +2,12 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"net"
 	"os"
+
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
 )
 
 func main() {
@@ -21,6 +24,19 @@ func main() {
 	go func() {
 		scanner := bufio.NewScanner(conn)
 		for scanner.Scan() {
+			// Answer pings so that the server does not drop the connection.
+			var msg shared.Message
+			if json.Unmarshal(scanner.Bytes(), &msg) == nil {
+				switch msg.Type {
+				case shared.PingMessage:
+					pong, _ := json.Marshal(shared.Message{Type: shared.PongMessage})
+					conn.Write(append(pong, '\n'))
+					continue
+				case shared.PongMessage:
+					continue
+				}
+			}
+
 			fmt.Println("Server:", scanner.Text())
 		}
 		if err := scanner.Err(); err != nil {
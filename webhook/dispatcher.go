@@ -0,0 +1,268 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/imaneimrh/TCP-Chat_Server/metrics"
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
+)
+
+var (
+	webhookDeliveries = metrics.NewCounterVec("chat_webhook_deliveries_total", "Webhook deliveries by outcome (delivered or failed).", "hook", "result")
+	webhookRetries    = metrics.NewCounterVec("chat_webhook_retries_total", "Webhook delivery attempts that were retried.", "hook")
+	webhookDropped    = metrics.NewCounterVec("chat_webhook_dropped_total", "Webhook deliveries dropped because the hook's queue was full.", "hook")
+)
+
+// Signature headers. The signature is the hex HMAC-SHA256, keyed with the
+// hook's secret, of the timestamp, a dot and the request body.
+const (
+	HeaderHook      = "X-Chat-Hook"
+	HeaderDelivery  = "X-Chat-Delivery"
+	HeaderTimestamp = "X-Chat-Timestamp"
+	HeaderSignature = "X-Chat-Signature"
+)
+
+type Config struct {
+	// QueueSize is how many deliveries may wait for one hook before new
+	// ones are dropped.
+	QueueSize int
+	// Timeout bounds a single HTTP request.
+	Timeout time.Duration
+	// MaxAttempts is how often a delivery is tried before it is given up.
+	MaxAttempts   int
+	RetryMinDelay time.Duration
+	RetryMaxDelay time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		QueueSize:     256,
+		Timeout:       10 * time.Second,
+		MaxAttempts:   5,
+		RetryMinDelay: time.Second,
+		RetryMaxDelay: time.Minute,
+	}
+}
+
+// Payload is the JSON body posted for a message. ID stays the same across
+// retries so receivers can discard duplicates.
+type Payload struct {
+	ID        string    `json:"id"`
+	Hook      string    `json:"hook"`
+	Room      string    `json:"room"`
+	MessageID string    `json:"message_id,omitempty"`
+	ParentID  string    `json:"parent_id,omitempty"`
+	Sender    string    `json:"sender"`
+	Text      string    `json:"text"`
+	Time      time.Time `json:"time"`
+}
+
+// Sign returns the signature of body for the given timestamp.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatcher sends room messages to the hooks that match them. It is a
+// room.Observer: matching happens on the room's goroutine, but each hook
+// has its own queue and worker, so a slow or failing endpoint only delays
+// its own deliveries.
+type Dispatcher struct {
+	config  Config
+	client  *http.Client
+	workers []*worker
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type worker struct {
+	hook  Hook
+	queue chan Payload
+	log   *slog.Logger
+}
+
+func NewDispatcher(hooks []Hook, config Config) (*Dispatcher, error) {
+	hooks = append([]Hook(nil), hooks...)
+	if err := prepare(hooks); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	for _, hook := range hooks {
+		d.workers = append(d.workers, &worker{
+			hook:  hook,
+			queue: make(chan Payload, config.QueueSize),
+			log:   slog.Default().With("webhook", hook.Name),
+		})
+	}
+	return d, nil
+}
+
+func (d *Dispatcher) Start() {
+	for _, w := range d.workers {
+		d.wg.Add(1)
+		go d.run(w)
+	}
+}
+
+// Stop abandons queued deliveries and waits up to timeout for requests in
+// flight to return.
+func (d *Dispatcher) Stop(timeout time.Duration) {
+	d.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		slog.Warn("Webhook deliveries did not stop in time")
+	}
+}
+
+// RoomMessage queues a message for every hook it matches. Server notices
+// and anything but text are ignored.
+func (d *Dispatcher) RoomMessage(room string, message shared.Message) {
+	if message.Type != shared.TextMessage || message.Sender == shared.ServerName || message.Content == "" {
+		return
+	}
+
+	for _, w := range d.workers {
+		if !w.hook.Matches(room, message.Content) {
+			continue
+		}
+
+		payload := Payload{
+			ID:        newDeliveryID(),
+			Hook:      w.hook.Name,
+			Room:      room,
+			MessageID: message.ID,
+			ParentID:  message.ParentID,
+			Sender:    message.Sender,
+			Text:      message.Content,
+			Time:      time.Now().UTC(),
+		}
+
+		select {
+		case w.queue <- payload:
+		default:
+			webhookDropped.With(w.hook.Name).Inc()
+			w.log.Warn("Webhook queue full, dropping delivery", "room", room)
+		}
+	}
+}
+
+func (d *Dispatcher) run(w *worker) {
+	defer d.wg.Done()
+	for {
+		select {
+		case payload := <-w.queue:
+			d.deliver(w, payload)
+		case <-d.ctx.Done():
+			return
+		}
+	}
+}
+
+// deliver posts payload, retrying with exponential backoff on network
+// errors, 429 and 5xx responses.
+func (d *Dispatcher) deliver(w *worker, payload Payload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		w.log.Error("Failed to encode webhook payload", "error", err)
+		return
+	}
+
+	delay := d.config.RetryMinDelay
+	for attempt := 1; ; attempt++ {
+		retry, err := d.post(w.hook, payload.ID, body)
+		if err == nil {
+			webhookDeliveries.With(w.hook.Name, "delivered").Inc()
+			return
+		}
+
+		if !retry || attempt >= d.config.MaxAttempts {
+			webhookDeliveries.With(w.hook.Name, "failed").Inc()
+			w.log.Warn("Webhook delivery failed", "delivery", payload.ID, "attempts", attempt, "error", err)
+			return
+		}
+
+		webhookRetries.With(w.hook.Name).Inc()
+		w.log.Debug("Retrying webhook delivery", "delivery", payload.ID, "attempt", attempt, "delay", delay, "error", err)
+
+		select {
+		case <-time.After(delay):
+		case <-d.ctx.Done():
+			return
+		}
+
+		delay *= 2
+		if delay > d.config.RetryMaxDelay {
+			delay = d.config.RetryMaxDelay
+		}
+	}
+}
+
+// post makes one attempt and reports whether a failure is worth retrying.
+func (d *Dispatcher) post(hook Hook, id string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderHook, hook.Name)
+	req.Header.Set(HeaderDelivery, id)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("endpoint returned %s", resp.Status)
+	default:
+		return false, fmt.Errorf("endpoint returned %s", resp.Status)
+	}
+}
+
+func newDeliveryID() string {
+	var id [16]byte
+	rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
@@ -0,0 +1,178 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
+)
+
+type delivery struct {
+	header  http.Header
+	body    []byte
+	payload Payload
+}
+
+// endpoint records deliveries and answers each with the next status in
+// statuses, then with 200.
+func endpoint(t *testing.T, statuses ...int) (*httptest.Server, <-chan delivery, *atomic.Int32) {
+	t.Helper()
+
+	deliveries := make(chan delivery, 10)
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(attempts.Add(1))
+
+		body, _ := io.ReadAll(r.Body)
+		var payload Payload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("invalid payload %q: %v", body, err)
+		}
+		deliveries <- delivery{header: r.Header, body: body, payload: payload}
+
+		if n <= len(statuses) {
+			w.WriteHeader(statuses[n-1])
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, deliveries, &attempts
+}
+
+func startDispatcher(t *testing.T, hooks ...Hook) *Dispatcher {
+	t.Helper()
+
+	config := DefaultConfig()
+	config.MaxAttempts = 3
+	config.RetryMinDelay = time.Millisecond
+	config.RetryMaxDelay = 5 * time.Millisecond
+
+	d, err := NewDispatcher(hooks, config)
+	if err != nil {
+		t.Fatalf("NewDispatcher() error = %v", err)
+	}
+	d.Start()
+	t.Cleanup(func() { d.Stop(time.Second) })
+	return d
+}
+
+func textMessage(sender, text string) shared.Message {
+	return shared.Message{Type: shared.TextMessage, Sender: sender, Content: text, ID: "7"}
+}
+
+func next(t *testing.T, deliveries <-chan delivery) delivery {
+	t.Helper()
+	select {
+	case d := <-deliveries:
+		return d
+	case <-time.After(2 * time.Second):
+		t.Fatal("no delivery arrived")
+		return delivery{}
+	}
+}
+
+func expectNone(t *testing.T, deliveries <-chan delivery) {
+	t.Helper()
+	select {
+	case d := <-deliveries:
+		t.Fatalf("unexpected delivery %s", d.body)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestDeliveryIsSigned(t *testing.T) {
+	server, deliveries, _ := endpoint(t)
+	d := startDispatcher(t, Hook{Name: "ci", Room: "ops", URL: server.URL, Secret: "s3cret"})
+
+	d.RoomMessage("ops", textMessage("alice", "hello"))
+	got := next(t, deliveries)
+
+	if got.payload.Hook != "ci" || got.payload.Room != "ops" || got.payload.Sender != "alice" ||
+		got.payload.Text != "hello" || got.payload.MessageID != "7" || got.payload.ID == "" {
+		t.Fatalf("payload = %+v", got.payload)
+	}
+	if got.header.Get(HeaderHook) != "ci" || got.header.Get(HeaderDelivery) != got.payload.ID {
+		t.Fatalf("headers = %v", got.header)
+	}
+
+	timestamp := got.header.Get(HeaderTimestamp)
+	if want := Sign("s3cret", timestamp, got.body); got.header.Get(HeaderSignature) != want {
+		t.Fatalf("signature = %q, want %q", got.header.Get(HeaderSignature), want)
+	}
+	if Sign("other", timestamp, got.body) == got.header.Get(HeaderSignature) {
+		t.Fatal("signature does not depend on the secret")
+	}
+}
+
+func TestDeliveryRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		attempts int
+	}{
+		{"success", nil, 1},
+		{"server errors then success", []int{500, 503}, 3},
+		{"rate limited", []int{429}, 2},
+		{"gives up", []int{500, 500, 500, 500}, 3},
+		{"client error is not retried", []int{400}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, deliveries, attempts := endpoint(t, tt.statuses...)
+			d := startDispatcher(t, Hook{Name: "ci", Room: "ops", URL: server.URL, Secret: "s3cret"})
+
+			d.RoomMessage("ops", textMessage("alice", "hello"))
+
+			first := next(t, deliveries)
+			for i := 1; i < tt.attempts; i++ {
+				if retry := next(t, deliveries); retry.payload.ID != first.payload.ID {
+					t.Fatalf("retry has delivery ID %s, want %s", retry.payload.ID, first.payload.ID)
+				}
+			}
+			expectNone(t, deliveries)
+
+			if got := int(attempts.Load()); got != tt.attempts {
+				t.Fatalf("endpoint saw %d attempts, want %d", got, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestDeliveryFilters(t *testing.T) {
+	server, deliveries, _ := endpoint(t)
+	d := startDispatcher(t,
+		Hook{Name: "deploys", Room: "ops", URL: server.URL, Secret: "a", Prefix: "!deploy"},
+		Hook{Name: "support", Room: "general", URL: server.URL, Secret: "b", Mention: "support"},
+	)
+
+	d.RoomMessage("ops", textMessage("alice", "!deploy api"))
+	if got := next(t, deliveries); got.payload.Hook != "deploys" {
+		t.Fatalf("delivered to %s, want deploys", got.payload.Hook)
+	}
+
+	d.RoomMessage("general", textMessage("bob", "@support the build is red"))
+	if got := next(t, deliveries); got.payload.Hook != "support" {
+		t.Fatalf("delivered to %s, want support", got.payload.Hook)
+	}
+
+	skipped := []struct {
+		room    string
+		message shared.Message
+	}{
+		{"ops", textMessage("alice", "deploy api")},
+		{"general", textMessage("alice", "!deploy api")},
+		{"general", textMessage("bob", "write to help@support.example.com")},
+		{"ops", textMessage(shared.ServerName, "!deploy api")},
+		{"ops", shared.Message{Type: shared.EditMessage, Sender: "alice", Content: "!deploy api"}},
+		{"ops", textMessage("alice", "")},
+	}
+	for _, s := range skipped {
+		d.RoomMessage(s.room, s.message)
+	}
+	expectNone(t, deliveries)
+}
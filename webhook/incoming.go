@@ -0,0 +1,263 @@
+package webhook
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/imaneimrh/TCP-Chat_Server/auth"
+	"github.com/imaneimrh/TCP-Chat_Server/metrics"
+	"github.com/imaneimrh/TCP-Chat_Server/ratelimit"
+)
+
+var incomingPosts = metrics.NewCounterVec("chat_incoming_webhook_posts_total", "Incoming webhook requests by outcome.", "hook", "result")
+
+// minTokenLength keeps incoming hooks from being configured with guessable
+// tokens.
+const minTokenLength = 16
+
+// IncomingHook lets an HTTP client post into one room. Requests must carry
+// "Authorization: Bearer <Token>".
+type IncomingHook struct {
+	Name  string `json:"name"`
+	Room  string `json:"room"`
+	Token string `json:"token"`
+	// BotName is the sender shown in the room, the hook's name by default.
+	// The server reserves it so that no user can register or log in as it.
+	BotName string `json:"bot_name,omitempty"`
+}
+
+// Poster is the part of the server incoming hooks post through.
+// client.Handler implements it.
+type Poster interface {
+	PostAs(sender, room, text string) error
+	Rooms() []string
+}
+
+type ReceiverConfig struct {
+	// MaxBodySize caps a request body in bytes.
+	MaxBodySize int64
+	// MaxTextSize caps the text of the posted message, attachments
+	// included, in bytes.
+	MaxTextSize    int
+	MaxAttachments int
+	// PostsPerSecond and PostBurst limit each hook (0 disables).
+	PostsPerSecond float64
+	PostBurst      int
+}
+
+func DefaultReceiverConfig() ReceiverConfig {
+	return ReceiverConfig{
+		MaxBodySize:    16 * 1024,
+		MaxTextSize:    4096,
+		MaxAttachments: 5,
+		PostsPerSecond: 1,
+		PostBurst:      5,
+	}
+}
+
+// Attachment is rendered below the message text as a titled link with an
+// optional description.
+type Attachment struct {
+	Title string `json:"title"`
+	URL   string `json:"url,omitempty"`
+	Text  string `json:"text,omitempty"`
+}
+
+// IncomingPost is the JSON body of an incoming webhook request.
+type IncomingPost struct {
+	Text        string       `json:"text"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// LoadIncomingFile reads a JSON array of incoming hooks.
+func LoadIncomingFile(path string) ([]IncomingHook, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read incoming webhooks: %w", err)
+	}
+
+	var hooks []IncomingHook
+	if err := json.Unmarshal(data, &hooks); err != nil {
+		return nil, fmt.Errorf("failed to parse incoming webhooks: %w", err)
+	}
+	return hooks, nil
+}
+
+// Receiver serves incoming webhooks at POST /hooks/{name}.
+type Receiver struct {
+	config ReceiverConfig
+	poster Poster
+	hooks  map[string]*incoming
+}
+
+type incoming struct {
+	IncomingHook
+	limit *ratelimit.Bucket
+}
+
+func NewReceiver(hooks []IncomingHook, poster Poster, config ReceiverConfig) (*Receiver, error) {
+	r := &Receiver{
+		config: config,
+		poster: poster,
+		hooks:  make(map[string]*incoming),
+	}
+
+	for _, hook := range hooks {
+		switch {
+		case hook.Name == "" || strings.ContainsAny(hook.Name, "/ \t"):
+			return nil, fmt.Errorf("invalid incoming webhook name '%s'", hook.Name)
+		case hook.Room == "":
+			return nil, fmt.Errorf("incoming webhook %s has no room", hook.Name)
+		case len(hook.Token) < minTokenLength:
+			return nil, fmt.Errorf("incoming webhook %s needs a token of at least %d characters", hook.Name, minTokenLength)
+		case r.hooks[hook.Name] != nil:
+			return nil, fmt.Errorf("duplicate incoming webhook name '%s'", hook.Name)
+		}
+
+		if hook.BotName == "" {
+			hook.BotName = hook.Name
+		}
+		if auth.IsReserved(hook.BotName) {
+			return nil, fmt.Errorf("incoming webhook %s cannot post as '%s'", hook.Name, hook.BotName)
+		}
+		r.hooks[hook.Name] = &incoming{
+			IncomingHook: hook,
+			limit:        ratelimit.NewBucket(config.PostsPerSecond, config.PostBurst),
+		}
+	}
+	return r, nil
+}
+
+// BotNames lists the names the hooks post as.
+func (r *Receiver) BotNames() []string {
+	names := make([]string, 0, len(r.hooks))
+	for _, hook := range r.hooks {
+		names = append(names, hook.BotName)
+	}
+	return names
+}
+
+func (r *Receiver) Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /hooks/{name}", r.post)
+}
+
+func (r *Receiver) post(w http.ResponseWriter, req *http.Request) {
+	hook := r.hooks[req.PathValue("name")]
+
+	token, _ := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if hook == nil || subtle.ConstantTimeCompare([]byte(token), []byte(hook.Token)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="chat-hooks"`)
+		writeError(w, http.StatusUnauthorized, "invalid or missing bearer token")
+		return
+	}
+
+	if !hook.limit.Allow() {
+		incomingPosts.With(hook.Name, "limited").Inc()
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(1/r.config.PostsPerSecond))))
+		writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+		return
+	}
+
+	var post IncomingPost
+	req.Body = http.MaxBytesReader(w, req.Body, r.config.MaxBodySize)
+	if err := json.NewDecoder(req.Body).Decode(&post); err != nil {
+		incomingPosts.With(hook.Name, "rejected").Inc()
+
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("body exceeds %d bytes", r.config.MaxBodySize))
+			return
+		}
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+
+	content, err := r.render(post)
+	if err != nil {
+		incomingPosts.With(hook.Name, "rejected").Inc()
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !slices.Contains(r.poster.Rooms(), hook.Room) {
+		incomingPosts.With(hook.Name, "rejected").Inc()
+		writeError(w, http.StatusNotFound, fmt.Sprintf("room %s does not exist", hook.Room))
+		return
+	}
+
+	if err := r.poster.PostAs(hook.BotName, hook.Room, content); err != nil {
+		incomingPosts.With(hook.Name, "rejected").Inc()
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	incomingPosts.With(hook.Name, "posted").Inc()
+	slog.Info("Incoming webhook posted", "webhook", hook.Name, "room", hook.Room)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "posted"})
+}
+
+// render turns a post into message content, with each attachment on lines
+// of its own below the text.
+func (r *Receiver) render(post IncomingPost) (string, error) {
+	if len(post.Attachments) > r.config.MaxAttachments {
+		return "", fmt.Errorf("at most %d attachments are allowed", r.config.MaxAttachments)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(strings.TrimSpace(post.Text))
+
+	for _, a := range post.Attachments {
+		a.Title = strings.TrimSpace(a.Title)
+		if a.Title == "" && a.URL == "" {
+			return "", fmt.Errorf("attachments need a title or url")
+		}
+		if a.URL != "" && !strings.HasPrefix(a.URL, "http://") && !strings.HasPrefix(a.URL, "https://") {
+			return "", fmt.Errorf("attachment url must be http or https")
+		}
+
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString("• ")
+		sb.WriteString(a.Title)
+		if a.URL != "" {
+			if a.Title != "" {
+				sb.WriteString(" ")
+			}
+			sb.WriteString("<" + a.URL + ">")
+		}
+		for _, line := range strings.Split(strings.TrimSpace(a.Text), "\n") {
+			if line != "" {
+				sb.WriteString("\n  " + line)
+			}
+		}
+	}
+
+	content := sb.String()
+	if content == "" {
+		return "", fmt.Errorf("text or attachments are required")
+	}
+	if len(content) > r.config.MaxTextSize {
+		return "", fmt.Errorf("message exceeds %d bytes", r.config.MaxTextSize)
+	}
+	return content, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
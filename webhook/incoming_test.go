@@ -0,0 +1,143 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testToken = "0123456789abcdef"
+
+type post struct {
+	sender, room, text string
+}
+
+// fakePoster records posts and refuses them with err.
+type fakePoster struct {
+	rooms []string
+	err   error
+	posts []post
+}
+
+func (p *fakePoster) PostAs(sender, room, text string) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.posts = append(p.posts, post{sender, room, text})
+	return nil
+}
+
+func (p *fakePoster) Rooms() []string { return p.rooms }
+
+func newTestReceiver(t *testing.T, poster *fakePoster, hooks ...IncomingHook) *http.ServeMux {
+	t.Helper()
+
+	r, err := NewReceiver(hooks, poster, DefaultReceiverConfig())
+	if err != nil {
+		t.Fatalf("NewReceiver() error = %v", err)
+	}
+	mux := http.NewServeMux()
+	r.Register(mux)
+	return mux
+}
+
+func postHook(mux *http.ServeMux, name, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/hooks/"+name, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestNewReceiverValidates(t *testing.T) {
+	tests := []struct {
+		name string
+		hook IncomingHook
+	}{
+		{"no name", IncomingHook{Room: "ops", Token: testToken}},
+		{"name with a slash", IncomingHook{Name: "a/b", Room: "ops", Token: testToken}},
+		{"no room", IncomingHook{Name: "ci", Token: testToken}},
+		{"short token", IncomingHook{Name: "ci", Room: "ops", Token: "short"}},
+		{"server name", IncomingHook{Name: "ci", Room: "ops", Token: testToken, BotName: "server"}},
+	}
+
+	for _, tt := range tests {
+		if _, err := NewReceiver([]IncomingHook{tt.hook}, &fakePoster{}, DefaultReceiverConfig()); err == nil {
+			t.Errorf("%s: NewReceiver() accepted %+v", tt.name, tt.hook)
+		}
+	}
+
+	hook := IncomingHook{Name: "ci", Room: "ops", Token: testToken}
+	if _, err := NewReceiver([]IncomingHook{hook, hook}, &fakePoster{}, DefaultReceiverConfig()); err == nil {
+		t.Error("NewReceiver() accepted two hooks with the same name")
+	}
+}
+
+func TestBotNames(t *testing.T) {
+	r, err := NewReceiver([]IncomingHook{
+		{Name: "ci", Room: "ops", Token: testToken},
+		{Name: "alerts", Room: "ops", Token: testToken, BotName: "pager"},
+	}, &fakePoster{}, DefaultReceiverConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := strings.Join(r.BotNames(), ",")
+	if names != "ci,pager" && names != "pager,ci" {
+		t.Fatalf("BotNames() = %q", names)
+	}
+}
+
+func TestIncomingPost(t *testing.T) {
+	poster := &fakePoster{rooms: []string{"general", "ops"}}
+	mux := newTestReceiver(t, poster, IncomingHook{Name: "ci", Room: "ops", Token: testToken, BotName: "ci-bot"})
+
+	rec := postHook(mux, "ci", testToken, `{"text": "build passed", "attachments": [{"title": "Log", "url": "https://ci.example.com/1"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	want := post{"ci-bot", "ops", "build passed\n• Log <https://ci.example.com/1>"}
+	if len(poster.posts) != 1 || poster.posts[0] != want {
+		t.Fatalf("posts = %+v, want %+v", poster.posts, want)
+	}
+}
+
+func TestIncomingPostRefused(t *testing.T) {
+	tests := []struct {
+		name   string
+		hook   string
+		token  string
+		body   string
+		poster fakePoster
+		status int
+	}{
+		{"no token", "ci", "", `{"text": "hi"}`, fakePoster{rooms: []string{"ops"}}, http.StatusUnauthorized},
+		{"wrong token", "ci", "fedcba9876543210", `{"text": "hi"}`, fakePoster{rooms: []string{"ops"}}, http.StatusUnauthorized},
+		{"unknown hook", "other", testToken, `{"text": "hi"}`, fakePoster{rooms: []string{"ops"}}, http.StatusUnauthorized},
+		{"invalid JSON", "ci", testToken, `{"text":`, fakePoster{rooms: []string{"ops"}}, http.StatusBadRequest},
+		{"empty", "ci", testToken, `{"text": "  "}`, fakePoster{rooms: []string{"ops"}}, http.StatusBadRequest},
+		{"bad attachment url", "ci", testToken, `{"attachments": [{"url": "javascript:x"}]}`, fakePoster{rooms: []string{"ops"}}, http.StatusBadRequest},
+		{"missing room", "ci", testToken, `{"text": "hi"}`, fakePoster{}, http.StatusNotFound},
+		{"refused mention", "ci", testToken, `{"text": "@room hi"}`, fakePoster{rooms: []string{"ops"}, err: errors.New("only moderators can use @room")}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			poster := tt.poster
+			mux := newTestReceiver(t, &poster, IncomingHook{Name: "ci", Room: "ops", Token: testToken})
+
+			rec := postHook(mux, tt.hook, tt.token, tt.body)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.status, rec.Body)
+			}
+			if len(poster.posts) != 0 {
+				t.Fatalf("refused request was posted: %+v", poster.posts)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
+)
+
+// Hook posts messages from one room to a URL. Prefix, Mention and Pattern
+// filter which messages are sent; when more than one is set a message must
+// match all of them, and when none is set every message is sent.
+type Hook struct {
+	Name   string `json:"name"`
+	Room   string `json:"room"`
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+
+	// Prefix matches messages that start with it, e.g. "!deploy".
+	Prefix string `json:"prefix,omitempty"`
+	// Mention matches messages that mention @Mention.
+	Mention string `json:"mention,omitempty"`
+	// Pattern is a regular expression searched for in the message.
+	Pattern string `json:"pattern,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// LoadFile reads a JSON array of hooks.
+func LoadFile(path string) ([]Hook, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhooks: %w", err)
+	}
+
+	var hooks []Hook
+	if err := json.Unmarshal(data, &hooks); err != nil {
+		return nil, fmt.Errorf("failed to parse webhooks: %w", err)
+	}
+
+	if err := prepare(hooks); err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+// prepare validates hooks and compiles their patterns in place.
+func prepare(hooks []Hook) error {
+	names := make(map[string]bool)
+	for i := range hooks {
+		if err := hooks[i].compile(); err != nil {
+			return err
+		}
+		if names[hooks[i].Name] {
+			return fmt.Errorf("duplicate webhook name '%s'", hooks[i].Name)
+		}
+		names[hooks[i].Name] = true
+	}
+	return nil
+}
+
+func (h *Hook) compile() error {
+	if h.Name == "" {
+		return fmt.Errorf("webhook without a name")
+	}
+	if h.Room == "" {
+		return fmt.Errorf("webhook %s has no room", h.Name)
+	}
+
+	// An unsigned request could have been sent by anyone.
+	if h.Secret == "" {
+		return fmt.Errorf("webhook %s has no secret", h.Name)
+	}
+
+	target, err := url.Parse(h.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return fmt.Errorf("webhook %s has an invalid url '%s'", h.Name, h.URL)
+	}
+
+	// A mention the parser would read differently, such as @here, could
+	// never match.
+	if h.Mention != "" {
+		if users, _, _ := shared.ParseMentions("@" + h.Mention); !slices.Equal(users, []string{h.Mention}) {
+			return fmt.Errorf("webhook %s has an invalid mention '%s'", h.Name, h.Mention)
+		}
+	}
+
+	if h.Pattern != "" {
+		h.pattern, err = regexp.Compile(h.Pattern)
+		if err != nil {
+			return fmt.Errorf("webhook %s has an invalid pattern: %w", h.Name, err)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether a message posted to room should be sent. Pattern
+// is only honoured once the hook has been loaded or given to a Dispatcher.
+func (h *Hook) Matches(room, text string) bool {
+	if room != h.Room {
+		return false
+	}
+	if h.Prefix != "" && !strings.HasPrefix(text, h.Prefix) {
+		return false
+	}
+	if h.Mention != "" && !mentions(text, h.Mention) {
+		return false
+	}
+	if h.pattern != nil && !h.pattern.MatchString(text) {
+		return false
+	}
+	return true
+}
+
+// mentions reports whether text mentions @name, by the rules the server
+// uses to notify mentioned users.
+func mentions(text, name string) bool {
+	users, _, _ := shared.ParseMentions(text)
+	return slices.Contains(users, name)
+}
@@ -0,0 +1,92 @@
+package webhook
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	valid := Hook{Name: "ci", Room: "ops", URL: "https://ci.example.com/hook", Secret: "s3cret"}
+
+	tests := []struct {
+		name    string
+		change  func(*Hook)
+		wantErr string
+	}{
+		{"valid", func(*Hook) {}, ""},
+		{"no name", func(h *Hook) { h.Name = "" }, "without a name"},
+		{"no room", func(h *Hook) { h.Room = "" }, "has no room"},
+		{"no secret", func(h *Hook) { h.Secret = "" }, "has no secret"},
+		{"not http", func(h *Hook) { h.URL = "ftp://ci.example.com" }, "invalid url"},
+		{"no host", func(h *Hook) { h.URL = "https:///hook" }, "invalid url"},
+		{"mention", func(h *Hook) { h.Mention = "support" }, ""},
+		{"mention with an @", func(h *Hook) { h.Mention = "@support" }, "invalid mention"},
+		{"mention of everyone", func(h *Hook) { h.Mention = "here" }, "invalid mention"},
+		{"mention with a space", func(h *Hook) { h.Mention = "support team" }, "invalid mention"},
+		{"bad pattern", func(h *Hook) { h.Pattern = "(" }, "invalid pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := valid
+			tt.change(&hook)
+
+			err := hook.compile()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("compile() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("compile() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDuplicateNames(t *testing.T) {
+	hook := Hook{Name: "ci", Room: "ops", URL: "https://ci.example.com/hook", Secret: "s3cret"}
+	if err := prepare([]Hook{hook, hook}); err == nil {
+		t.Fatal("prepare() accepted two hooks with the same name")
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		name string
+		hook Hook
+		room string
+		text string
+		want bool
+	}{
+		{"no filters", Hook{Room: "ops"}, "ops", "anything", true},
+		{"other room", Hook{Room: "ops"}, "general", "anything", false},
+		{"prefix", Hook{Room: "ops", Prefix: "!deploy"}, "ops", "!deploy api", true},
+		{"prefix later in the text", Hook{Room: "ops", Prefix: "!deploy"}, "ops", "please !deploy", false},
+		{"mention", Hook{Room: "ops", Mention: "support"}, "ops", "help @support", true},
+		{"mention in another case", Hook{Room: "ops", Mention: "support"}, "ops", "@Support: help", false},
+		{"mention before punctuation", Hook{Room: "ops", Mention: "support"}, "ops", "ask @support.", true},
+		{"mention after a dot", Hook{Room: "ops", Mention: "support"}, "ops", "see.@support", false},
+		{"mention at the end", Hook{Room: "ops", Mention: "support"}, "ops", "thanks @support", true},
+		{"longer name", Hook{Room: "ops", Mention: "support"}, "ops", "@supporters", false},
+		{"email address", Hook{Room: "ops", Mention: "support"}, "ops", "mail team@support.example.com", false},
+		{"email then mention", Hook{Room: "ops", Mention: "support"}, "ops", "a@support.com or @support", true},
+		{"no mention", Hook{Room: "ops", Mention: "support"}, "ops", "support", false},
+		{"all filters", Hook{Room: "ops", Prefix: "!", Mention: "ci", Pattern: "fail"}, "ops", "! @ci build failed", true},
+		{"one filter misses", Hook{Room: "ops", Prefix: "!", Mention: "ci", Pattern: "fail"}, "ops", "! @ci build passed", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := tt.hook
+			hook.Name, hook.URL, hook.Secret = "test", "https://example.com", "s3cret"
+			if err := hook.compile(); err != nil {
+				t.Fatal(err)
+			}
+			if got := hook.Matches(tt.room, tt.text); got != tt.want {
+				t.Fatalf("Matches(%q, %q) = %v, want %v", tt.room, tt.text, got, tt.want)
+			}
+		})
+	}
+}
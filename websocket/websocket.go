@@ -0,0 +1,325 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
+)
+
+// acceptGUID is the fixed key suffix from RFC 6455 section 1.3.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+const (
+	closeNormal        = 1000
+	closeProtocolError = 1002
+	closeTooLarge      = 1009
+)
+
+// maxControlPayload is the largest payload RFC 6455 allows on a control frame.
+const maxControlPayload = 125
+
+// maxMessageSize caps messages when ReadFrame is given no limit, so that a
+// frame header cannot make the server allocate whatever length it claims.
+const maxMessageSize = 1 << 20
+
+var ErrProtocol = errors.New("websocket protocol error")
+
+// Conn is a server-side WebSocket connection. Each text or binary message is
+// one frame of the chat protocol.
+type Conn struct {
+	conn      net.Conn
+	reader    *bufio.Reader
+	writeMu   sync.Mutex
+	closeOnce sync.Once
+}
+
+// Upgrade performs the opening handshake and takes over the underlying
+// connection. On failure it has already written an HTTP error response.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, fmt.Errorf("handshake: method %s", r.Method)
+	}
+
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "expected a WebSocket upgrade", http.StatusBadRequest)
+		return nil, fmt.Errorf("handshake: not an upgrade request")
+	}
+
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil, fmt.Errorf("handshake: unsupported version %q", r.Header.Get("Sec-WebSocket-Version"))
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		http.Error(w, "invalid Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, fmt.Errorf("handshake: invalid key")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "connection cannot be upgraded", http.StatusInternalServerError)
+		return nil, fmt.Errorf("handshake: response does not support hijacking")
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("handshake: %w", err)
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+
+	conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("handshake: %w", err)
+	}
+	conn.SetWriteDeadline(time.Time{})
+
+	return &Conn{
+		conn:   conn,
+		reader: rw.Reader,
+	}, nil
+}
+
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type frameHeader struct {
+	fin    bool
+	opcode byte
+	length uint64
+	mask   [4]byte
+}
+
+func (c *Conn) readHeader() (frameHeader, error) {
+	var h frameHeader
+
+	var b [8]byte
+	if _, err := io.ReadFull(c.reader, b[:2]); err != nil {
+		return h, err
+	}
+
+	h.fin = b[0]&0x80 != 0
+	h.opcode = b[0] & 0x0F
+	if b[0]&0x70 != 0 {
+		return h, fmt.Errorf("%w: reserved bits set", ErrProtocol)
+	}
+
+	// Browsers must mask every frame they send (RFC 6455 section 5.1).
+	if b[1]&0x80 == 0 {
+		return h, fmt.Errorf("%w: unmasked client frame", ErrProtocol)
+	}
+
+	h.length = uint64(b[1] & 0x7F)
+	switch h.length {
+	case 126:
+		if _, err := io.ReadFull(c.reader, b[:2]); err != nil {
+			return h, err
+		}
+		h.length = uint64(binary.BigEndian.Uint16(b[:2]))
+	case 127:
+		if _, err := io.ReadFull(c.reader, b[:8]); err != nil {
+			return h, err
+		}
+		h.length = binary.BigEndian.Uint64(b[:8])
+		if h.length>>63 != 0 {
+			return h, fmt.Errorf("%w: invalid payload length", ErrProtocol)
+		}
+	}
+
+	if _, err := io.ReadFull(c.reader, h.mask[:]); err != nil {
+		return h, err
+	}
+
+	if h.opcode >= opClose && (!h.fin || h.length > maxControlPayload) {
+		return h, fmt.Errorf("%w: invalid control frame", ErrProtocol)
+	}
+
+	return h, nil
+}
+
+func (c *Conn) readPayload(h frameHeader) ([]byte, error) {
+	payload := make([]byte, h.length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return nil, err
+	}
+	for i := range payload {
+		payload[i] ^= h.mask[i%4]
+	}
+	return payload, nil
+}
+
+// ReadFrame returns the next complete text or binary message, answering pings
+// and close frames along the way. Messages longer than maxSize, or than
+// maxMessageSize when maxSize is not positive, are refused with
+// shared.ErrFrameTooLarge before their payload is read.
+func (c *Conn) ReadFrame(maxSize int) ([]byte, error) {
+	if maxSize <= 0 {
+		maxSize = maxMessageSize
+	}
+
+	var message []byte
+	started := false
+
+	for {
+		h, err := c.readHeader()
+		if err != nil {
+			if errors.Is(err, ErrProtocol) {
+				c.closeWith(closeProtocolError)
+			}
+			return nil, err
+		}
+
+		switch h.opcode {
+		case opPing:
+			payload, err := c.readPayload(h)
+			if err != nil {
+				return nil, err
+			}
+			if err := c.write(opPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+
+		case opPong:
+			if _, err := c.readPayload(h); err != nil {
+				return nil, err
+			}
+			continue
+
+		case opClose:
+			c.readPayload(h)
+			c.closeWith(closeNormal)
+			return nil, io.EOF
+
+		case opText, opBinary:
+			if started {
+				c.closeWith(closeProtocolError)
+				return nil, fmt.Errorf("%w: new message before previous one finished", ErrProtocol)
+			}
+			started = true
+
+		case opContinuation:
+			if !started {
+				c.closeWith(closeProtocolError)
+				return nil, fmt.Errorf("%w: continuation without a message", ErrProtocol)
+			}
+
+		default:
+			c.closeWith(closeProtocolError)
+			return nil, fmt.Errorf("%w: unknown opcode %d", ErrProtocol, h.opcode)
+		}
+
+		if uint64(len(message))+h.length > uint64(maxSize) {
+			c.closeWith(closeTooLarge)
+			return nil, shared.ErrFrameTooLarge
+		}
+
+		payload, err := c.readPayload(h)
+		if err != nil {
+			return nil, err
+		}
+		message = append(message, payload...)
+
+		if h.fin {
+			return message, nil
+		}
+	}
+}
+
+// WriteFrame sends frame as a single text message.
+func (c *Conn) WriteFrame(frame []byte) error {
+	return c.write(opText, bytes.TrimSuffix(frame, []byte("\n")))
+}
+
+func (c *Conn) write(opcode byte, payload []byte) error {
+	header := make([]byte, 2, 10)
+	header[0] = 0x80 | opcode
+
+	switch {
+	case len(payload) < 126:
+		header[1] = byte(len(payload))
+	case len(payload) <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(len(payload)))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(len(payload)))
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	_, err := c.conn.Write(append(header, payload...))
+	return err
+}
+
+// closeWith sends a close frame with the given status, at most once, and
+// closes the underlying connection.
+func (c *Conn) closeWith(code uint16) error {
+	var err error
+	c.closeOnce.Do(func() {
+		payload := binary.BigEndian.AppendUint16(nil, code)
+
+		c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+		c.write(opClose, payload)
+		err = c.conn.Close()
+	})
+	return err
+}
+
+func (c *Conn) Close() error {
+	return c.closeWith(closeNormal)
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
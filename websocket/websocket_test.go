@@ -0,0 +1,286 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/imaneimrh/TCP-Chat_Server/shared"
+)
+
+// clientFrame builds a masked frame as a browser would send it.
+func clientFrame(fin bool, opcode byte, payload []byte) []byte {
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	frame := []byte{first}
+
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, 0x80|byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+
+	mask := []byte{0x12, 0x34, 0x56, 0x78}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	return frame
+}
+
+// serverFrame is a frame as the server writes it, unmasked.
+func serverFrame(opcode byte, payload []byte) []byte {
+	return append([]byte{0x80 | opcode, byte(len(payload))}, payload...)
+}
+
+func closePayload(code uint16) []byte {
+	return binary.BigEndian.AppendUint16(nil, code)
+}
+
+// readFrom feeds input to a server-side Conn, reads one message with maxSize
+// and returns it along with everything the server wrote back.
+func readFrom(t *testing.T, input []byte, maxSize int) ([]byte, []byte, error) {
+	t.Helper()
+
+	server, client := net.Pipe()
+	t.Cleanup(func() { client.Close() })
+	server.SetDeadline(time.Now().Add(2 * time.Second))
+	client.SetDeadline(time.Now().Add(2 * time.Second))
+
+	go client.Write(input)
+
+	written := make(chan []byte, 1)
+	go func() {
+		data, _ := io.ReadAll(client)
+		written <- data
+	}()
+
+	c := &Conn{conn: server, reader: bufio.NewReader(server)}
+	message, err := c.ReadFrame(maxSize)
+	server.Close()
+
+	return message, <-written, err
+}
+
+func TestReadFrame(t *testing.T) {
+	long := bytes.Repeat([]byte("x"), 300)
+	huge := bytes.Repeat([]byte("y"), 70000)
+
+	tests := []struct {
+		name    string
+		input   []byte
+		maxSize int
+		want    []byte
+		wantErr error
+		written []byte
+	}{
+		{
+			name:  "text",
+			input: clientFrame(true, opText, []byte("hello")),
+			want:  []byte("hello"),
+		},
+		{
+			name:  "binary",
+			input: clientFrame(true, opBinary, []byte{0, 1, 2}),
+			want:  []byte{0, 1, 2},
+		},
+		{
+			name:  "empty",
+			input: clientFrame(true, opText, nil),
+			want:  nil,
+		},
+		{
+			name:  "16-bit length",
+			input: clientFrame(true, opText, long),
+			want:  long,
+		},
+		{
+			name:    "64-bit length",
+			input:   clientFrame(true, opText, huge),
+			maxSize: 100000,
+			want:    huge,
+		},
+		{
+			name: "fragmented",
+			input: concat(
+				clientFrame(false, opText, []byte("hel")),
+				clientFrame(false, opContinuation, []byte("lo ")),
+				clientFrame(true, opContinuation, []byte("world")),
+			),
+			want: []byte("hello world"),
+		},
+		{
+			name: "ping between fragments",
+			input: concat(
+				clientFrame(false, opText, []byte("a")),
+				clientFrame(true, opPing, []byte("are you there")),
+				clientFrame(true, opContinuation, []byte("b")),
+			),
+			want:    []byte("ab"),
+			written: serverFrame(opPong, []byte("are you there")),
+		},
+		{
+			name: "pong is ignored",
+			input: concat(
+				clientFrame(true, opPong, []byte("late")),
+				clientFrame(true, opText, []byte("hi")),
+			),
+			want: []byte("hi"),
+		},
+		{
+			name:    "close",
+			input:   clientFrame(true, opClose, closePayload(closeNormal)),
+			wantErr: io.EOF,
+			written: serverFrame(opClose, closePayload(closeNormal)),
+		},
+		{
+			name:    "unmasked",
+			input:   []byte{0x80 | opText, 2, 'h', 'i'},
+			wantErr: ErrProtocol,
+			written: serverFrame(opClose, closePayload(closeProtocolError)),
+		},
+		{
+			name:    "reserved bits",
+			input:   append([]byte{0xC0 | opText}, clientFrame(true, opText, []byte("hi"))[1:]...),
+			wantErr: ErrProtocol,
+			written: serverFrame(opClose, closePayload(closeProtocolError)),
+		},
+		{
+			name:    "continuation without a message",
+			input:   clientFrame(true, opContinuation, []byte("x")),
+			wantErr: ErrProtocol,
+			written: serverFrame(opClose, closePayload(closeProtocolError)),
+		},
+		{
+			name: "message before previous one finished",
+			input: concat(
+				clientFrame(false, opText, []byte("a")),
+				clientFrame(true, opText, []byte("b")),
+			),
+			wantErr: ErrProtocol,
+			written: serverFrame(opClose, closePayload(closeProtocolError)),
+		},
+		{
+			name:    "fragmented control frame",
+			input:   clientFrame(false, opPing, []byte("x")),
+			wantErr: ErrProtocol,
+			written: serverFrame(opClose, closePayload(closeProtocolError)),
+		},
+		{
+			name:    "oversized control frame",
+			input:   clientFrame(true, opPing, long[:126]),
+			wantErr: ErrProtocol,
+			written: serverFrame(opClose, closePayload(closeProtocolError)),
+		},
+		{
+			name:    "unknown opcode",
+			input:   clientFrame(true, 0x3, []byte("x")),
+			wantErr: ErrProtocol,
+			written: serverFrame(opClose, closePayload(closeProtocolError)),
+		},
+		{
+			name:    "over maxSize",
+			input:   clientFrame(true, opText, long),
+			maxSize: 100,
+			wantErr: shared.ErrFrameTooLarge,
+			written: serverFrame(opClose, closePayload(closeTooLarge)),
+		},
+		{
+			name: "fragments over maxSize",
+			input: concat(
+				clientFrame(false, opText, long[:60]),
+				clientFrame(true, opContinuation, long[:60]),
+			),
+			maxSize: 100,
+			wantErr: shared.ErrFrameTooLarge,
+			written: serverFrame(opClose, closePayload(closeTooLarge)),
+		},
+		{
+			name:    "claimed length over the default cap",
+			input:   concat([]byte{0x80 | opText, 0x80 | 127}, binary.BigEndian.AppendUint64(nil, 1<<40), []byte{1, 2, 3, 4}),
+			wantErr: shared.ErrFrameTooLarge,
+			written: serverFrame(opClose, closePayload(closeTooLarge)),
+		},
+		{
+			name:    "length with the top bit set",
+			input:   concat([]byte{0x80 | opText, 0x80 | 127}, binary.BigEndian.AppendUint64(nil, 1<<63), []byte{1, 2, 3, 4}),
+			wantErr: ErrProtocol,
+			written: serverFrame(opClose, closePayload(closeProtocolError)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message, written, err := readFrom(t, tt.input, tt.maxSize)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ReadFrame() error = %v, want %v", err, tt.wantErr)
+			}
+			if !bytes.Equal(message, tt.want) {
+				t.Fatalf("ReadFrame() = %q, want %q", message, tt.want)
+			}
+			if tt.written != nil && !bytes.Equal(written, tt.written) {
+				t.Fatalf("server wrote % x, want % x", written, tt.written)
+			}
+		})
+	}
+}
+
+func TestWriteFrame(t *testing.T) {
+	for _, n := range []int{0, 125, 126, 0xFFFF, 0x10000} {
+		server, client := net.Pipe()
+		c := &Conn{conn: server, reader: bufio.NewReader(server)}
+
+		payload := bytes.Repeat([]byte("z"), n)
+		go func() {
+			c.WriteFrame(append(payload, '\n'))
+			server.Close()
+		}()
+
+		written, _ := io.ReadAll(client)
+		client.Close()
+
+		// Mask the frame so that it can be read back like a client's.
+		if len(written) < 2 || written[0] != 0x80|opText {
+			t.Fatalf("%d bytes: unexpected header % x", n, written[:min(len(written), 2)])
+		}
+		header := 2
+		switch written[1] {
+		case 126:
+			header += 2
+		case 127:
+			header += 8
+		}
+		echo := append([]byte{written[0], written[1] | 0x80}, written[2:header]...)
+		echo = append(echo, 0, 0, 0, 0)
+		echo = append(echo, written[header:]...)
+
+		message, _, err := readFrom(t, echo, 0x20000)
+		if err != nil || !bytes.Equal(message, payload) {
+			t.Fatalf("%d bytes: read back %d bytes, err %v", n, len(message), err)
+		}
+	}
+}
+
+func TestAcceptKey(t *testing.T) {
+	// The example from RFC 6455 section 1.3.
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("acceptKey() = %q", got)
+	}
+}
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}